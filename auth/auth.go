@@ -0,0 +1,63 @@
+package auth
+
+import (
+	"context"
+	"errors"
+)
+
+var (
+	ErrUnauthenticated = errors.New("authentication required")
+	ErrInvalidToken    = errors.New("invalid token")
+	ErrForbidden       = errors.New("forbidden")
+)
+
+// Роли из claim roles, дающие доступ к чужим постам и комментариям
+const (
+	RoleModerator = "moderator"
+	RoleAdmin     = "admin"
+)
+
+// User - пользователь, от имени которого выполняется запрос
+type User struct {
+	// Идентификатор пользователя (claim sub), используется как автор постов и комментариев
+	ID    string
+	Name  string
+	Roles []string
+}
+
+// HasRole сообщает, есть ли у пользователя хотя бы одна из ролей roles
+func (u *User) HasRole(roles ...string) bool {
+	for _, have := range u.Roles {
+		for _, want := range roles {
+			if have == want {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+type contextKey struct {
+	name string
+}
+
+var userKey = &contextKey{"user"}
+
+func WithUser(ctx context.Context, user *User) context.Context {
+	return context.WithValue(ctx, userKey, user)
+}
+
+// ForContext возвращает пользователя запроса или nil, если запрос анонимный
+func ForContext(ctx context.Context) *User {
+	user, _ := ctx.Value(userKey).(*User)
+	return user
+}
+
+// RequireUser возвращает пользователя запроса или ErrUnauthenticated
+func RequireUser(ctx context.Context) (*User, error) {
+	user := ForContext(ctx)
+	if user == nil {
+		return nil, ErrUnauthenticated
+	}
+	return user, nil
+}
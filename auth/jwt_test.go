@@ -0,0 +1,118 @@
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+var testSecret = []byte("secret")
+
+func signHS256(t *testing.T, c jwt.MapClaims) string {
+	token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, c).SignedString(testSecret)
+	if err != nil {
+		t.Fatalf("sign token: %s", err)
+	}
+	return token
+}
+
+func TestVerifyHS256(t *testing.T) {
+	v, err := NewVerifier(VerifierConfig{Secret: testSecret})
+	if err != nil {
+		t.Fatalf("error was not expected while creating verifier: %s", err)
+	}
+
+	exp := time.Now().Add(time.Hour).Unix()
+	user, err := v.Verify(signHS256(t, jwt.MapClaims{"sub": "alice", "name": "Alice", "roles": []string{RoleModerator}, "exp": exp}))
+	if err != nil {
+		t.Fatalf("error was not expected while verifying token: %s", err)
+	}
+	if user.ID != "alice" || user.Name != "Alice" || !user.HasRole(RoleAdmin, RoleModerator) || user.HasRole(RoleAdmin) {
+		t.Errorf("unexpected user: %+v", user)
+	}
+
+	tests := map[string]string{
+		"expired":     signHS256(t, jwt.MapClaims{"sub": "alice", "exp": time.Now().Add(-time.Hour).Unix()}),
+		"without exp": signHS256(t, jwt.MapClaims{"sub": "alice"}),
+		"without sub": signHS256(t, jwt.MapClaims{"exp": exp}),
+		"malformed":   "not.a.token",
+	}
+	for name, token := range tests {
+		if _, err := v.Verify(token); !errors.Is(err, ErrInvalidToken) {
+			t.Errorf("%s: expected ErrInvalidToken, got %v", name, err)
+		}
+	}
+}
+
+func TestVerifyRS256WithJWKS(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("generate key: %s", err)
+	}
+
+	path := filepath.Join(t.TempDir(), "jwks.json")
+	jwk := fmt.Sprintf(`{"keys":[{"kty":"RSA","kid":"k1","use":"sig","n":%q,"e":%q},{"kty":"EC","kid":"k2"}]}`,
+		base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+		base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()))
+	if err := os.WriteFile(path, []byte(jwk), 0o600); err != nil {
+		t.Fatalf("write jwks: %s", err)
+	}
+
+	keys, err := LoadJWKS(path)
+	if err != nil {
+		t.Fatalf("error was not expected while loading jwks: %s", err)
+	}
+	if len(keys) != 1 {
+		t.Fatalf("expected 1 key, got %d", len(keys))
+	}
+
+	v, err := NewVerifier(VerifierConfig{Keys: keys, Issuer: "issuer"})
+	if err != nil {
+		t.Fatalf("error was not expected while creating verifier: %s", err)
+	}
+
+	sign := func(kid, iss string) string {
+		token := jwt.NewWithClaims(jwt.SigningMethodRS256, jwt.MapClaims{"sub": "bob", "iss": iss, "exp": time.Now().Add(time.Hour).Unix()})
+		token.Header["kid"] = kid
+		s, err := token.SignedString(key)
+		if err != nil {
+			t.Fatalf("sign token: %s", err)
+		}
+		return s
+	}
+
+	user, err := v.Verify(sign("k1", "issuer"))
+	if err != nil {
+		t.Fatalf("error was not expected while verifying token: %s", err)
+	}
+	if user.ID != "bob" {
+		t.Errorf("unexpected user: %+v", user)
+	}
+
+	if _, err := v.Verify(sign("unknown", "issuer")); !errors.Is(err, ErrInvalidToken) {
+		t.Errorf("expected ErrInvalidToken for an unknown kid, got %v", err)
+	}
+	if _, err := v.Verify(sign("k1", "other")); !errors.Is(err, ErrInvalidToken) {
+		t.Errorf("expected ErrInvalidToken for a wrong issuer, got %v", err)
+	}
+
+	// HS256 не разрешён, если секрет не настроен
+	if _, err := v.Verify(signHS256(t, jwt.MapClaims{"sub": "bob", "iss": "issuer", "exp": time.Now().Add(time.Hour).Unix()})); !errors.Is(err, ErrInvalidToken) {
+		t.Errorf("expected ErrInvalidToken for HS256 token, got %v", err)
+	}
+}
+
+func TestNewVerifierRequiresKeys(t *testing.T) {
+	if _, err := NewVerifier(VerifierConfig{}); err == nil {
+		t.Error("expected an error without secret and keys")
+	}
+}
@@ -0,0 +1,83 @@
+package auth
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// Пользователь, от имени которого выполняются запросы без токена в анонимном режиме
+var Anonymous = &User{ID: "anonymous", Name: "Anonymous"}
+
+// Authenticator проверяет токен запроса и кладёт пользователя в контекст.
+// Запросы без токена пропускаются без пользователя, а в анонимном режиме -
+// от имени Anonymous.
+type Authenticator struct {
+	verifier  *Verifier
+	anonymous bool
+}
+
+// NewAuthenticator создаёт Authenticator. verifier может быть nil только в анонимном режиме,
+// тогда любой переданный токен отклоняется.
+func NewAuthenticator(verifier *Verifier, anonymous bool) *Authenticator {
+	return &Authenticator{
+		verifier:  verifier,
+		anonymous: anonymous,
+	}
+}
+
+// Authenticate проверяет токен, переданный в заголовке Authorization
+// или в connection_init, с префиксом "Bearer " или без него
+func (a *Authenticator) Authenticate(ctx context.Context, token string) (context.Context, error) {
+	token = strings.TrimSpace(token)
+	if len(token) > 7 && strings.EqualFold(token[:7], "bearer ") {
+		token = strings.TrimSpace(token[7:])
+	}
+
+	if token == "" {
+		if a.anonymous && ForContext(ctx) == nil {
+			return WithUser(ctx, Anonymous), nil
+		}
+		return ctx, nil
+	}
+
+	if a.verifier == nil {
+		return nil, fmt.Errorf("%w: authentication is not configured", ErrInvalidToken)
+	}
+
+	user, err := a.verifier.Verify(token)
+	if err != nil {
+		return nil, err
+	}
+
+	return WithUser(ctx, user), nil
+}
+
+// Middleware аутентифицирует HTTP-запросы по заголовку Authorization.
+// Запрос с недействительным токеном отклоняется с кодом 401.
+func (a *Authenticator) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx, err := a.Authenticate(r.Context(), r.Header.Get("Authorization"))
+		if err != nil {
+			writeUnauthorized(w, err)
+			return
+		}
+
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+func writeUnauthorized(w http.ResponseWriter, err error) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("WWW-Authenticate", `Bearer error="invalid_token"`)
+	w.WriteHeader(http.StatusUnauthorized)
+
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"errors": []map[string]interface{}{{
+			"message":    err.Error(),
+			"extensions": map[string]string{"code": "UNAUTHENTICATED"},
+		}},
+	})
+}
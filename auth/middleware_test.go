@@ -0,0 +1,79 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+func TestMiddleware(t *testing.T) {
+	v, _ := NewVerifier(VerifierConfig{Secret: testSecret})
+	a := NewAuthenticator(v, false)
+
+	var got *User
+	handler := a.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = ForContext(r.Context())
+	}))
+
+	token := signHS256(t, jwt.MapClaims{"sub": "alice", "exp": time.Now().Add(time.Hour).Unix()})
+	tests := []struct {
+		header string
+		status int
+		user   string
+	}{
+		{"", http.StatusOK, ""},
+		{"Bearer " + token, http.StatusOK, "alice"},
+		{"Bearer broken", http.StatusUnauthorized, ""},
+	}
+
+	for _, tt := range tests {
+		got = nil
+		req := httptest.NewRequest(http.MethodPost, "/query", nil)
+		if tt.header != "" {
+			req.Header.Set("Authorization", tt.header)
+		}
+
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+
+		if rec.Code != tt.status {
+			t.Errorf("%q: expected status %d, got %d", tt.header, tt.status, rec.Code)
+		}
+		if tt.user == "" && got != nil || tt.user != "" && (got == nil || got.ID != tt.user) {
+			t.Errorf("%q: expected user %q, got %+v", tt.header, tt.user, got)
+		}
+	}
+}
+
+func TestAuthenticateAnonymous(t *testing.T) {
+	a := NewAuthenticator(nil, true)
+
+	ctx, err := a.Authenticate(context.Background(), "")
+	if err != nil {
+		t.Fatalf("error was not expected: %s", err)
+	}
+	if ForContext(ctx) != Anonymous {
+		t.Errorf("expected anonymous user, got %+v", ForContext(ctx))
+	}
+
+	// Без настроенной проверки подписи токены не принимаются даже в анонимном режиме
+	if _, err := a.Authenticate(context.Background(), "Bearer token"); !errors.Is(err, ErrInvalidToken) {
+		t.Errorf("expected ErrInvalidToken, got %v", err)
+	}
+}
+
+func TestRequireUser(t *testing.T) {
+	if _, err := RequireUser(context.Background()); !errors.Is(err, ErrUnauthenticated) {
+		t.Errorf("expected ErrUnauthenticated, got %v", err)
+	}
+
+	user := &User{ID: "alice"}
+	if got, err := RequireUser(WithUser(context.Background(), user)); err != nil || got != user {
+		t.Errorf("expected %+v, got %+v, %v", user, got, err)
+	}
+}
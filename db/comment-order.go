@@ -0,0 +1,164 @@
+package db
+
+import (
+	"PostCommentService/graph/model"
+	"fmt"
+	"math"
+)
+
+// commentOrder задаёт порядок комментариев на странице. В ранжированных
+// сортировках комментарии с равным ключом идут по возрастанию ID, поэтому
+// курсор из ключа и ID однозначно указывает место в списке.
+type commentOrder struct {
+	// Ключ ранжирования, комментарии идут по его убыванию. nil - порядок только по ID.
+	key func(c *model.Comment) float64
+	// SQL-выражение того же ключа
+	column string
+	// Для порядка по ID: сначала новые
+	newestFirst bool
+}
+
+var commentOrders = map[model.CommentSort]commentOrder{
+	model.CommentSortOld: {},
+	model.CommentSortNew: {newestFirst: true},
+	model.CommentSortTop: {
+		key:    func(c *model.Comment) float64 { return float64(c.Score) },
+		column: "(upvotes - downvotes)",
+	},
+	model.CommentSortBest: {
+		key:    func(c *model.Comment) float64 { return wilson(c.Upvotes, c.Downvotes) },
+		column: "best",
+	},
+	model.CommentSortControversial: {
+		key:    func(c *model.Comment) float64 { return controversy(c.Upvotes, c.Downvotes) },
+		column: "controversy",
+	},
+}
+
+func orderFor(sort model.CommentSort) (commentOrder, error) {
+	order, ok := commentOrders[sort]
+	if !ok {
+		return commentOrder{}, fmt.Errorf("%w: unknown comment sort %s", ErrInvalidArgument, sort)
+	}
+	return order, nil
+}
+
+// cursor возвращает курсор, указывающий на комментарий c
+func (o commentOrder) cursor(c *model.Comment) string {
+	if o.key == nil {
+		return encodeCursor(c.ID)
+	}
+	return encodeKeyCursor(position{id: c.ID, key: o.key(c)})
+}
+
+// decodeCursor разбирает курсор этого порядка, nil - страница с начала списка
+func (o commentOrder) decodeCursor(cursor string) (*position, error) {
+	if cursor == "" {
+		return nil, nil
+	}
+	if o.key == nil {
+		id, err := decodeCursor(cursor)
+		if err != nil {
+			return nil, err
+		}
+		return &position{id: id}, nil
+	}
+	return decodeKeyCursor(cursor)
+}
+
+// less сообщает, идёт ли комментарий a раньше b
+func (o commentOrder) less(a, b *model.Comment) bool {
+	return o.before(o.position(a), o.position(b))
+}
+
+// after сообщает, идёт ли комментарий c после позиции pos
+func (o commentOrder) after(c *model.Comment, pos position) bool {
+	return o.before(pos, o.position(c))
+}
+
+func (o commentOrder) position(c *model.Comment) position {
+	pos := position{id: c.ID}
+	if o.key != nil {
+		pos.key = o.key(c)
+	}
+	return pos
+}
+
+func (o commentOrder) before(a, b position) bool {
+	switch {
+	case o.key != nil:
+		return rankedBefore(a, b)
+	case o.newestFirst:
+		return a.id > b.id
+	default:
+		return a.id < b.id
+	}
+}
+
+// orderBy возвращает SQL-выражение ORDER BY для этого порядка
+func (o commentOrder) orderBy() string {
+	switch {
+	case o.key != nil:
+		return o.column + " DESC, id ASC"
+	case o.newestFirst:
+		return "id DESC"
+	default:
+		return "id ASC"
+	}
+}
+
+// afterCondition возвращает SQL-условие "комментарий идёт после pos" и его аргументы,
+// n - номер первого свободного параметра запроса
+func (o commentOrder) afterCondition(pos *position, n int) (string, []interface{}) {
+	switch {
+	case pos == nil:
+		return "TRUE", nil
+	case o.key != nil:
+		return keyCondition(o.column, *pos, n)
+	case o.newestFirst:
+		return fmt.Sprintf("id < $%d", n), []interface{}{pos.id}
+	default:
+		return fmt.Sprintf("id > $%d", n), []interface{}{pos.id}
+	}
+}
+
+// wilson возвращает нижнюю границу доверительного интервала Уилсона (95%)
+// для доли голосов за. Комментарий с парой голосов за не обгоняет
+// комментарий с сотней голосов за и несколькими против.
+func wilson(up, down int) float64 {
+	n := float64(up + down)
+	if n == 0 {
+		return 0
+	}
+
+	const z = 1.96
+	p := float64(up) / n
+	return (p + z*z/(2*n) - z*math.Sqrt((p*(1-p)+z*z/(4*n))/n)) / (1 + z*z/n)
+}
+
+// controversy тем больше, чем больше голосов и чем ближе число голосов за к числу голосов против
+func controversy(up, down int) float64 {
+	if up <= 0 || down <= 0 {
+		return 0
+	}
+
+	balance := float64(min(up, down)) / float64(max(up, down))
+	return math.Pow(float64(up+down), balance)
+}
+
+// applyVote пересчитывает число голосов за и против после замены голоса old на value
+func applyVote(up, down, old, value int) (int, int) {
+	switch old {
+	case 1:
+		up--
+	case -1:
+		down--
+	}
+	switch value {
+	case 1:
+		up++
+	case -1:
+		down++
+	}
+	return up, down
+}
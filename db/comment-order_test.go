@@ -0,0 +1,53 @@
+package db
+
+import (
+	"PostCommentService/graph/model"
+	"errors"
+	"testing"
+)
+
+func TestWilson(t *testing.T) {
+	if wilson(0, 0) != 0 {
+		t.Error("comment without votes should have zero rank")
+	}
+	// Пара голосов за весит меньше, чем сотня голосов за при нескольких против
+	if wilson(2, 0) >= wilson(100, 5) {
+		t.Errorf("expected wilson(2, 0) = %f < wilson(100, 5) = %f", wilson(2, 0), wilson(100, 5))
+	}
+	if wilson(10, 10) >= wilson(10, 1) {
+		t.Error("more downvotes should lower the rank")
+	}
+}
+
+func TestControversy(t *testing.T) {
+	if controversy(10, 0) != 0 {
+		t.Error("one-sided votes are not controversial")
+	}
+	if controversy(10, 10) <= controversy(10, 2) {
+		t.Error("balanced votes should be more controversial")
+	}
+	if controversy(50, 50) <= controversy(5, 5) {
+		t.Error("more votes should be more controversial")
+	}
+}
+
+func TestCommentOrderCursor(t *testing.T) {
+	order, _ := orderFor(model.CommentSortBest)
+	comment := &model.Comment{ID: 7, Upvotes: 3, Downvotes: 1}
+
+	pos, err := order.decodeCursor(order.cursor(comment))
+	if err != nil {
+		t.Fatalf("error was not expected while decoding cursor: %s", err)
+	}
+	if pos.id != 7 || pos.key != wilson(3, 1) {
+		t.Errorf("unexpected position: %+v", pos)
+	}
+
+	// Курсор по ID к ранжированной сортировке не подходит
+	if _, err := order.decodeCursor(encodeCursor(7)); !errors.Is(err, ErrInvalidCursor) {
+		t.Errorf("expected %v, got %v", ErrInvalidCursor, err)
+	}
+	if _, err := orderFor("RANDOM"); !errors.Is(err, ErrInvalidArgument) {
+		t.Errorf("expected %v, got %v", ErrInvalidArgument, err)
+	}
+}
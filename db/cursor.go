@@ -0,0 +1,235 @@
+package db
+
+import (
+	"PostCommentService/graph/model"
+	"encoding/base64"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+const (
+	cursorPrefix = "cursor:"
+	// Больше MaxPageSize элементов за одну страницу не возвращается
+	MaxPageSize = 100
+)
+
+// Курсор - непрозрачная для клиента строка, за которой скрыт ID последнего
+// полученного элемента. Страницы строятся по ID, поэтому курсор остаётся
+// корректным, даже если между запросами появились новые элементы.
+func encodeCursor(id int) string {
+	return base64.StdEncoding.EncodeToString([]byte(cursorPrefix + strconv.Itoa(id)))
+}
+
+func decodeCursor(cursor string) (int, error) {
+	if cursor == "" {
+		return 0, nil
+	}
+
+	raw, err := base64.StdEncoding.DecodeString(cursor)
+	if err != nil {
+		return 0, ErrInvalidCursor
+	}
+
+	id, err := strconv.Atoi(strings.TrimPrefix(string(raw), cursorPrefix))
+	if err != nil || !strings.HasPrefix(string(raw), cursorPrefix) {
+		return 0, ErrInvalidCursor
+	}
+
+	return id, nil
+}
+
+// position - место в ранжированном списке, после которого начинается страница.
+// Элементы идут по убыванию ключа, а при равном ключе - по возрастанию ID.
+type position struct {
+	id  int
+	key float64
+}
+
+// rankedBefore сообщает, идёт ли позиция a раньше b в ранжированном списке
+func rankedBefore(a, b position) bool {
+	if a.key != b.key {
+		return a.key > b.key
+	}
+	return a.id < b.id
+}
+
+// keyCondition возвращает SQL-условие "элемент ранжированного списка идёт после pos"
+// для ключа column и его аргументы, n - номер первого свободного параметра запроса
+func keyCondition(column string, pos position, n int) (string, []interface{}) {
+	return fmt.Sprintf("(%[1]s < $%[2]d OR %[1]s = $%[2]d AND id > $%[3]d)", column, n, n+1), []interface{}{pos.key, pos.id}
+}
+
+// encodeKeyCursor кодирует курсор ранжированного списка. Кроме ID в нём хранится
+// ключ ранжирования, иначе по одному ID нельзя найти место элемента в списке.
+func encodeKeyCursor(pos position) string {
+	raw := cursorPrefix + strconv.Itoa(pos.id) + ":" + strconv.FormatFloat(pos.key, 'g', -1, 64)
+	return base64.StdEncoding.EncodeToString([]byte(raw))
+}
+
+// decodeKeyCursor разбирает курсор ранжированного списка, nil - страница с начала списка
+func decodeKeyCursor(cursor string) (*position, error) {
+	if cursor == "" {
+		return nil, nil
+	}
+
+	raw, err := base64.StdEncoding.DecodeString(cursor)
+	if err != nil || !strings.HasPrefix(string(raw), cursorPrefix) {
+		return nil, ErrInvalidCursor
+	}
+
+	id, key, ok := strings.Cut(strings.TrimPrefix(string(raw), cursorPrefix), ":")
+	if !ok {
+		return nil, ErrInvalidCursor
+	}
+
+	var pos position
+	if pos.id, err = strconv.Atoi(id); err != nil {
+		return nil, ErrInvalidCursor
+	}
+	if pos.key, err = strconv.ParseFloat(key, 64); err != nil {
+		return nil, ErrInvalidCursor
+	}
+
+	return &pos, nil
+}
+
+func pageSize(first int) (int, error) {
+	if first < 0 {
+		return 0, fmt.Errorf("%w: first must not be negative", ErrInvalidArgument)
+	}
+	if first > MaxPageSize {
+		return MaxPageSize, nil
+	}
+	return first, nil
+}
+
+func newCommentConnection(comments []*model.Comment, totalCount int, hasNextPage bool, order commentOrder) *model.CommentConnection {
+	conn := &model.CommentConnection{
+		Edges:      make([]*model.CommentEdge, 0, len(comments)),
+		PageInfo:   &model.PageInfo{HasNextPage: hasNextPage},
+		TotalCount: totalCount,
+	}
+
+	for _, comment := range comments {
+		conn.Edges = append(conn.Edges, &model.CommentEdge{
+			Cursor: order.cursor(comment),
+			Node:   comment,
+		})
+	}
+
+	if len(conn.Edges) > 0 {
+		conn.PageInfo.EndCursor = &conn.Edges[len(conn.Edges)-1].Cursor
+	}
+
+	return conn
+}
+
+func newPostConnection(posts []*model.Post, totalCount int, hasNextPage bool) *model.PostConnection {
+	conn := &model.PostConnection{
+		Edges:      make([]*model.PostEdge, 0, len(posts)),
+		PageInfo:   &model.PageInfo{HasNextPage: hasNextPage},
+		TotalCount: totalCount,
+	}
+
+	for _, post := range posts {
+		conn.Edges = append(conn.Edges, &model.PostEdge{
+			Cursor: encodeCursor(post.ID),
+			Node:   post,
+		})
+	}
+
+	if len(conn.Edges) > 0 {
+		conn.PageInfo.EndCursor = &conn.Edges[len(conn.Edges)-1].Cursor
+	}
+
+	return conn
+}
+
+func newRevisionConnection(revisions []*model.Revision, totalCount int, hasNextPage bool) *model.RevisionConnection {
+	conn := &model.RevisionConnection{
+		Edges:      make([]*model.RevisionEdge, 0, len(revisions)),
+		PageInfo:   &model.PageInfo{HasNextPage: hasNextPage},
+		TotalCount: totalCount,
+	}
+
+	for _, revision := range revisions {
+		conn.Edges = append(conn.Edges, &model.RevisionEdge{
+			Cursor: encodeCursor(revision.Number),
+			Node:   revision,
+		})
+	}
+
+	if len(conn.Edges) > 0 {
+		conn.PageInfo.EndCursor = &conn.Edges[len(conn.Edges)-1].Cursor
+	}
+
+	return conn
+}
+
+func newReportConnection(reports []*model.Report, totalCount int, hasNextPage bool) *model.ReportConnection {
+	conn := &model.ReportConnection{
+		Edges:      make([]*model.ReportEdge, 0, len(reports)),
+		PageInfo:   &model.PageInfo{HasNextPage: hasNextPage},
+		TotalCount: totalCount,
+	}
+
+	for _, report := range reports {
+		conn.Edges = append(conn.Edges, &model.ReportEdge{
+			Cursor: encodeCursor(report.ID),
+			Node:   report,
+		})
+	}
+
+	if len(conn.Edges) > 0 {
+		conn.PageInfo.EndCursor = &conn.Edges[len(conn.Edges)-1].Cursor
+	}
+
+	return conn
+}
+
+func newPostSearchConnection(posts []*model.Post, hits []position, snippets []string, totalCount int, hasNextPage bool) *model.PostSearchConnection {
+	conn := &model.PostSearchConnection{
+		Edges:      make([]*model.PostSearchEdge, 0, len(posts)),
+		PageInfo:   &model.PageInfo{HasNextPage: hasNextPage},
+		TotalCount: totalCount,
+	}
+
+	for i, post := range posts {
+		conn.Edges = append(conn.Edges, &model.PostSearchEdge{
+			Cursor:  encodeKeyCursor(hits[i]),
+			Node:    post,
+			Rank:    hits[i].key,
+			Snippet: snippets[i],
+		})
+	}
+
+	if len(conn.Edges) > 0 {
+		conn.PageInfo.EndCursor = &conn.Edges[len(conn.Edges)-1].Cursor
+	}
+
+	return conn
+}
+
+func newCommentSearchConnection(comments []*model.Comment, hits []position, snippets []string, totalCount int, hasNextPage bool) *model.CommentSearchConnection {
+	conn := &model.CommentSearchConnection{
+		Edges:      make([]*model.CommentSearchEdge, 0, len(comments)),
+		PageInfo:   &model.PageInfo{HasNextPage: hasNextPage},
+		TotalCount: totalCount,
+	}
+
+	for i, comment := range comments {
+		conn.Edges = append(conn.Edges, &model.CommentSearchEdge{
+			Cursor:  encodeKeyCursor(hits[i]),
+			Node:    comment,
+			Rank:    hits[i].key,
+			Snippet: snippets[i],
+		})
+	}
+
+	if len(conn.Edges) > 0 {
+		conn.PageInfo.EndCursor = &conn.Edges[len(conn.Edges)-1].Cursor
+	}
+
+	return conn
+}
@@ -6,6 +6,10 @@ import (
 	"fmt"
 	"log"
 	"os"
+	"sort"
+	"strings"
+	"time"
+	"unicode/utf8"
 
 	_ "github.com/lib/pq"
 
@@ -13,37 +17,220 @@ import (
 )
 
 type Store interface {
-	GetPosts() ([]*model.Post, error)
-	GetPost(id, offset, limit int) (*model.Post, error)
-	GetComments(postID, offset, limit int) ([]*model.Comment, error)
+	// GetPosts возвращает опубликованные посты, а также неопубликованные посты автора viewer
+	GetPosts(first int, after string, sort model.PostSort, filter *model.PostFilter, viewer string) (*model.PostConnection, error)
+	GetPost(id int) (*model.Post, error)
+	// GetComments и GetReplies возвращают страницы комментариев постов и ответов на комментарии.
+	// Скрытые модератором комментарии попадают в них только при withHidden.
+	GetComments(postIDs []int, first int, after string, sort model.CommentSort, withHidden bool) (map[int]*model.CommentConnection, error)
+	GetReplies(parentIDs []int, first int, after string, sort model.CommentSort, withHidden bool) (map[int]*model.CommentConnection, error)
 	GetComment(id int) (*model.Comment, error)
-	CreatePost(title, content, author string) (*model.Post, error)
+	// CreatePost создаёт пост с тегами tags, теги нормализуются.
+	// Отложенному посту (SCHEDULED) нужно время публикации publishAt в будущем.
+	CreatePost(title, content, author string, tags []string, status model.PostStatus, publishAt *time.Time) (*model.Post, error)
+	// PublishPost публикует черновик или отложенный пост: сразу, если publishAt
+	// не задан или уже наступил, иначе откладывает публикацию до publishAt
+	PublishPost(id int, publishAt *time.Time) (*model.Post, error)
+	// PublishScheduledPosts публикует отложенные посты, время публикации которых
+	// наступило к моменту now, и возвращает их ID
+	PublishScheduledPosts(now time.Time) ([]int, error)
 	CreateComment(postID int, author, content string, parentId *int) (*model.Comment, error)
-	UpdatePost(id int, title, content string) (*model.Post, error)
-	UpdateComment(id int, content string) (*model.Comment, error)
-	DisableComments(postID int) error
+	// UpdatePost и UpdateComment сохраняют прежний текст в истории правок от имени editor.
+	// UpdatePost заменяет теги поста на tags, nil оставляет прежние теги.
+	UpdatePost(id int, title, content string, tags []string, editor string) (*model.Post, error)
+	UpdateComment(id int, content, editor string) (*model.Comment, error)
+	// GetPostRevisions и GetCommentRevisions возвращают страницу истории правок в порядке их внесения
+	GetPostRevisions(postID int, first int, after string) (*model.RevisionConnection, error)
+	GetCommentRevisions(commentID int, first int, after string) (*model.RevisionConnection, error)
+	// GetPostRevision и GetCommentRevision возвращают правку с номером number
+	GetPostRevision(postID, number int) (*model.Revision, error)
+	GetCommentRevision(commentID, number int) (*model.Revision, error)
+	// SetCommentsEnabled открывает или закрывает комментарии к посту.
+	// Открытие также снимает правило автоматического закрытия.
+	SetCommentsEnabled(postID int, enabled bool) (*model.Post, error)
+	// SetAutoLock задаёт, через сколько дней после создания закрыть комментарии, nil - никогда
+	SetAutoLock(postID int, afterDays *int) (*model.Post, error)
+	// LockExpiredPosts закрывает комментарии к постам, срок которых по правилу
+	// автоматического закрытия истёк к моменту now, и возвращает их ID
+	LockExpiredPosts(now time.Time) ([]int, error)
+	// DeletePost скрывает пост вместе с комментариями
+	DeletePost(id int) error
+	// DeleteComment удаляет текст и автора комментария. Комментарий с ответами
+	// остаётся в дереве как удалённый, без ответов - пропадает из списков.
+	DeleteComment(id int) error
+	// PurgePost безвозвратно удаляет пост со всеми комментариями
+	PurgePost(id int) error
+	// PurgeComment безвозвратно удаляет комментарий со всеми ответами
+	PurgeComment(id int) error
+	// Vote сохраняет голос userID за пост или комментарий: 1 - за, -1 - против, 0 - отозвать голос
+	Vote(target model.VoteTarget, targetID int, userID string, value int) (*model.VoteResult, error)
+	// GetVotes возвращает голоса userID за цели ids. Цели, за которые он не голосовал, в ответ не попадают.
+	GetVotes(target model.VoteTarget, ids []int, userID string) (map[int]int, error)
+	// AddReaction и RemoveReaction ставят и снимают реакцию emoji пользователя userID
+	// на комментарий и возвращают его реакции. Повторная постановка или снятие ничего не меняют.
+	AddReaction(commentID int, userID, emoji string) ([]*model.Reaction, error)
+	RemoveReaction(commentID int, userID, emoji string) ([]*model.Reaction, error)
+	// GetReactions возвращает реакции на комментарии ids в порядке появления,
+	// reactedByMe отмечает реакции userID. Комментарии без реакций в ответ не попадают.
+	GetReactions(commentIDs []int, userID string) (map[int][]*model.Reaction, error)
+	// SearchPosts и SearchComments ищут слова запроса в постах и комментариях.
+	// Результаты идут по убыванию релевантности, postID ограничивает поиск комментариями одного поста.
+	SearchPosts(query string, first int, after string) (*model.PostSearchConnection, error)
+	SearchComments(postID *int, query string, first int, after string) (*model.CommentSearchConnection, error)
+	// GetPostTags возвращает теги постов ids по алфавиту. Посты без тегов в ответ не попадают.
+	GetPostTags(postIDs []int) (map[int][]string, error)
+	// GetTags возвращает first самых популярных тегов с числом постов, отмеченных ими
+	GetTags(first int) ([]*model.Tag, error)
+	// ReportComment сохраняет жалобу reporter на комментарий. Повторная жалоба
+	// того же пользователя на тот же комментарий - ErrInvalidArgument.
+	ReportComment(commentID int, reporter, reason string) (*model.Report, error)
+	// GetReports возвращает страницу жалоб со статусом status в порядке их поступления
+	GetReports(first int, after string, status model.ReportStatus) (*model.ReportConnection, error)
+	// ResolveReport применяет action к комментарию открытой жалобы id и закрывает
+	// от имени moderator все открытые жалобы на этот комментарий
+	ResolveReport(id int, action model.ReportAction, moderator string) (*model.Report, error)
 }
 
-func NewStore(useMemory bool) Store {
+func validateAutoLock(afterDays *int) error {
+	if afterDays != nil && *afterDays <= 0 {
+		return fmt.Errorf("%w: afterDays must be positive", ErrInvalidArgument)
+	}
+	return nil
+}
+
+func validateVote(value int) error {
+	if value < -1 || value > 1 {
+		return fmt.Errorf("%w: vote must be -1, 0 or 1", ErrInvalidArgument)
+	}
+	return nil
+}
+
+// Максимальная длина причины жалобы в символах
+const maxReportReasonLength = 500
+
+func validateReportReason(reason string) error {
+	if strings.TrimSpace(reason) == "" {
+		return fmt.Errorf("%w: report reason must not be empty", ErrInvalidArgument)
+	}
+	if utf8.RuneCountInString(reason) > maxReportReasonLength {
+		return fmt.Errorf("%w: report reason is longer than %d characters", ErrInvalidArgument, maxReportReasonLength)
+	}
+	return nil
+}
+
+// reportStatusFor возвращает статус, который получает жалоба после решения action
+func reportStatusFor(action model.ReportAction) (model.ReportStatus, error) {
+	switch action {
+	case model.ReportActionDismiss:
+		return model.ReportStatusDismissed, nil
+	case model.ReportActionHideComment, model.ReportActionDeleteComment, model.ReportActionLockPost:
+		return model.ReportStatusResolved, nil
+	default:
+		return "", fmt.Errorf("%w: unknown report action %s", ErrInvalidArgument, action)
+	}
+}
+
+// publication проверяет статус нового поста и возвращает время его публикации
+func publication(status model.PostStatus, publishAt *time.Time, now time.Time) (*time.Time, error) {
+	switch status {
+	case model.PostStatusPublished:
+		if publishAt != nil {
+			return nil, fmt.Errorf("%w: publishAt is only allowed for scheduled posts", ErrInvalidArgument)
+		}
+		return &now, nil
+	case model.PostStatusScheduled:
+		if publishAt == nil || !publishAt.After(now) {
+			return nil, fmt.Errorf("%w: scheduled post needs publishAt in the future", ErrInvalidArgument)
+		}
+		return publishAt, nil
+	case model.PostStatusDraft:
+		if publishAt != nil {
+			return nil, fmt.Errorf("%w: publishAt is only allowed for scheduled posts", ErrInvalidArgument)
+		}
+		return nil, nil
+	default:
+		return nil, fmt.Errorf("%w: unknown post status %s", ErrInvalidArgument, status)
+	}
+}
+
+// checkPublished запрещает действия с неопубликованным постом. Автор получает
+// ErrPostNotPublished, остальным пост не виден, поэтому они получают notFound.
+func checkPublished(status model.PostStatus, author, userID string, notFound error) error {
+	if status == model.PostStatusPublished {
+		return nil
+	}
+	if author != userID {
+		return notFound
+	}
+	return ErrPostNotPublished
+}
+
+// Ограничения на теги поста
+const (
+	maxTags      = 10
+	maxTagLength = 50
+)
+
+// normalizeTag приводит тег к нижнему регистру и убирает лишние пробелы
+func normalizeTag(tag string) string {
+	return strings.ToLower(strings.Join(strings.Fields(tag), " "))
+}
+
+// tagSet нормализует теги и возвращает различные из них по алфавиту, пустые теги отбрасываются
+func tagSet(tags []string) []string {
+	seen := make(map[string]bool, len(tags))
+	set := make([]string, 0, len(tags))
+	for _, tag := range tags {
+		if tag = normalizeTag(tag); tag != "" && !seen[tag] {
+			seen[tag] = true
+			set = append(set, tag)
+		}
+	}
+	sort.Strings(set)
+	return set
+}
+
+// normalizeTags нормализует теги поста и проверяет ограничения на них. nil остаётся nil.
+func normalizeTags(tags []string) ([]string, error) {
+	if tags == nil {
+		return nil, nil
+	}
+
+	set := tagSet(tags)
+	if len(set) > maxTags {
+		return nil, fmt.Errorf("%w: a post can have at most %d tags", ErrInvalidArgument, maxTags)
+	}
+	for _, tag := range set {
+		if utf8.RuneCountInString(tag) > maxTagLength {
+			return nil, fmt.Errorf("%w: tag %q is longer than %d characters", ErrInvalidArgument, tag, maxTagLength)
+		}
+	}
+
+	return set, nil
+}
+
+func NewStore(useMemory, autoMigrate bool) Store {
 	if useMemory {
 		return NewMemoryStore()
 	}
 
-	err := godotenv.Load()
-	if err != nil {
-		log.Fatal("Error loading .env file")
-	}
+	db := Open()
 
-	host := os.Getenv("DB_HOST")
-	port := os.Getenv("DB_PORT")
-	user := os.Getenv("DB_USER")
-	password := os.Getenv("DB_PASSWORD")
-	dbname := os.Getenv("DB_NAME")
+	if autoMigrate {
+		applied, err := MigrateUp(db)
+		if err != nil {
+			log.Fatal(err)
+		}
+		for _, m := range applied {
+			log.Printf("Applied migration %04d_%s", m.Version, m.Name)
+		}
+	}
 
-	psqlInfo := fmt.Sprintf("host=%s port=%s user=%s password=%s dbname=%s sslmode=disable",
-		host, port, user, password, dbname)
+	return NewPostgresStore(db)
+}
 
-	db, err := sql.Open("postgres", psqlInfo)
+func Open() *sql.DB {
+	db, err := sql.Open("postgres", ConnString())
 	if err != nil {
 		log.Fatal(err)
 	}
@@ -54,5 +241,22 @@ func NewStore(useMemory bool) Store {
 	}
 
 	log.Println("Successfully connected!")
-	return NewPostgresStore(db)
+	return db
+}
+
+// ConnString собирает строку подключения к PostgreSQL из переменных окружения (.env)
+func ConnString() string {
+	err := godotenv.Load()
+	if err != nil {
+		log.Fatal("Error loading .env file")
+	}
+
+	host := os.Getenv("DB_HOST")
+	port := os.Getenv("DB_PORT")
+	user := os.Getenv("DB_USER")
+	password := os.Getenv("DB_PASSWORD")
+	dbname := os.Getenv("DB_NAME")
+
+	return fmt.Sprintf("host=%s port=%s user=%s password=%s dbname=%s sslmode=disable",
+		host, port, user, password, dbname)
 }
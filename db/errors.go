@@ -0,0 +1,46 @@
+package db
+
+import (
+	"errors"
+	"fmt"
+)
+
+var (
+	ErrNotFound         = errors.New("not found")
+	ErrCommentsDisabled = errors.New("comments are disabled for this post")
+	ErrPostNotPublished = errors.New("post is not published")
+	ErrInvalidParent    = errors.New("parent comment does not belong to this post")
+	ErrInvalidArgument  = errors.New("invalid argument")
+	ErrInvalidCursor    = fmt.Errorf("%w: invalid cursor", ErrInvalidArgument)
+)
+
+// NotFoundError сообщает, какая именно сущность не найдена.
+// errors.Is(err, ErrNotFound) для неё возвращает true.
+type NotFoundError struct {
+	Entity string
+	ID     int
+}
+
+func (e *NotFoundError) Error() string {
+	return fmt.Sprintf("%s %d not found", e.Entity, e.ID)
+}
+
+func (e *NotFoundError) Is(target error) bool {
+	return target == ErrNotFound
+}
+
+func postNotFound(id int) error {
+	return &NotFoundError{Entity: "post", ID: id}
+}
+
+func commentNotFound(id int) error {
+	return &NotFoundError{Entity: "comment", ID: id}
+}
+
+func revisionNotFound(number int) error {
+	return &NotFoundError{Entity: "revision", ID: number}
+}
+
+func reportNotFound(id int) error {
+	return &NotFoundError{Entity: "report", ID: id}
+}
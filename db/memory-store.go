@@ -2,191 +2,1180 @@ package db
 
 import (
 	"PostCommentService/graph/model"
-	"errors"
+	"fmt"
+	"sort"
 	"sync"
+	"time"
 )
 
+type voteKey struct {
+	target model.VoteTarget
+	id     int
+}
+
+// reaction - пользователи, поставившие комментарию реакцию emoji
+type reaction struct {
+	emoji string
+	users map[string]bool
+}
+
 type MemoryStore struct {
 	posts    map[int]*model.Post
 	comments map[int]*model.Comment
-	mu       sync.RWMutex
+	// ID постов в порядке создания
+	postIDs []int
+	// ID комментариев верхнего уровня каждого поста в порядке создания
+	postComments map[int][]int
+	// ID прямых ответов на каждый комментарий в порядке создания
+	replies map[int][]int
+	// Удалённые посты, недоступные для чтения
+	deletedPosts map[int]*model.Post
+	// История правок постов и комментариев, правка с номером n хранится под индексом n-1
+	postRevisions    map[int][]*model.Revision
+	commentRevisions map[int][]*model.Revision
+	// Голоса пользователей за каждый пост и комментарий
+	votes map[voteKey]map[string]int
+	// Реакции на каждый комментарий в порядке появления
+	reactions map[int][]*reaction
+	// Теги каждого поста по алфавиту
+	postTags map[int][]string
+	// Жалобы на комментарии, ID жалоб в порядке поступления и ID жалоб на каждый комментарий
+	reports        map[int]*model.Report
+	reportIDs      []int
+	commentReports map[int][]int
+	// Обратные индексы для полнотекстового поиска
+	postIndex    *searchIndex
+	commentIndex *searchIndex
+	// Последние выданные ID, удаление не должно приводить к их повторному использованию
+	lastPostID    int
+	lastCommentID int
+	lastReportID  int
+	now           func() time.Time
+	mu            sync.RWMutex
 }
 
 func NewMemoryStore() *MemoryStore {
 	return &MemoryStore{
-		posts:    make(map[int]*model.Post),
-		comments: make(map[int]*model.Comment),
+		posts:        make(map[int]*model.Post),
+		comments:     make(map[int]*model.Comment),
+		postComments: make(map[int][]int),
+		replies:      make(map[int][]int),
+		deletedPosts: make(map[int]*model.Post),
+
+		postRevisions:    make(map[int][]*model.Revision),
+		commentRevisions: make(map[int][]*model.Revision),
+		votes:            make(map[voteKey]map[string]int),
+		reactions:        make(map[int][]*reaction),
+		postTags:         make(map[int][]string),
+		reports:          make(map[int]*model.Report),
+		commentReports:   make(map[int][]int),
+		postIndex:        newSearchIndex(),
+		commentIndex:     newSearchIndex(),
+
+		now: time.Now,
 	}
 }
 
-func (s *MemoryStore) GetPosts() ([]*model.Post, error) {
+func (s *MemoryStore) GetPosts(first int, after string, sort model.PostSort, filter *model.PostFilter, viewer string) (*model.PostConnection, error) {
 	s.mu.RLock()
 	defer s.mu.RUnlock()
 
+	first, err := pageSize(first)
+	if err != nil {
+		return nil, err
+	}
+
+	afterID, err := decodeCursor(after)
+	if err != nil {
+		return nil, err
+	}
+
+	// Теги фильтра нормализуются один раз, а не для каждого поста
+	if filter != nil && filter.Tags != nil {
+		f := *filter
+		f.Tags = tagSet(filter.Tags)
+		filter = &f
+	}
+
+	// postIDs отсортирован по возрастанию, для NEWEST обходим его с конца
+	start, step := 0, 1
+	if sort == model.PostSortNewest {
+		start, step = len(s.postIDs)-1, -1
+	}
+
 	var posts []*model.Post
-	for _, post := range s.posts {
-		posts = append(posts, post)
+	total := 0
+	hasNextPage := false
+	for i := start; i >= 0 && i < len(s.postIDs); i += step {
+		post := s.posts[s.postIDs[i]]
+		if !visibleTo(post, viewer) || !matchPostFilter(post, s.postTags[post.ID], filter) {
+			continue
+		}
+		total++
+
+		// Пропускаем посты до курсора включительно
+		if afterID != 0 && (step > 0 && post.ID <= afterID || step < 0 && post.ID >= afterID) {
+			continue
+		}
+
+		if len(posts) == first {
+			hasNextPage = true
+			continue
+		}
+		p := *post
+		posts = append(posts, &p)
 	}
 
-	return posts, nil
+	return newPostConnection(posts, total, hasNextPage), nil
+}
+
+// visibleTo сообщает, виден ли пост пользователю viewer: неопубликованные посты видит только автор
+func visibleTo(post *model.Post, viewer string) bool {
+	return post.Status == model.PostStatusPublished || viewer != "" && post.Author == viewer
+}
+
+func matchPostFilter(post *model.Post, tags []string, filter *model.PostFilter) bool {
+	if filter == nil {
+		return true
+	}
+	if filter.Author != nil && post.Author != *filter.Author {
+		return false
+	}
+	if filter.CommentsEnabled != nil && post.CommentsEnabled != *filter.CommentsEnabled {
+		return false
+	}
+	for _, tag := range filter.Tags {
+		i := sort.SearchStrings(tags, tag)
+		if i == len(tags) || tags[i] != tag {
+			return false
+		}
+	}
+	return true
 }
 
-func (s *MemoryStore) GetPost(id, offset, limit int) (*model.Post, error) {
+func (s *MemoryStore) GetPost(id int) (*model.Post, error) {
 	s.mu.RLock()
 	defer s.mu.RUnlock()
 
 	post, ok := s.posts[id]
 	if !ok {
-		return nil, errors.New("post not found")
+		return nil, postNotFound(id)
+	}
+
+	// Возвращаем копию, чтобы не изменять пост, хранящийся в памяти
+	p := *post
+	return &p, nil
+}
+
+func (s *MemoryStore) GetComments(postIDs []int, first int, after string, sort model.CommentSort, withHidden bool) (map[int]*model.CommentConnection, error) {
+	return s.commentPages(s.postComments, postIDs, first, after, sort, withHidden)
+}
+
+func (s *MemoryStore) GetReplies(parentIDs []int, first int, after string, sort model.CommentSort, withHidden bool) (map[int]*model.CommentConnection, error) {
+	return s.commentPages(s.replies, parentIDs, first, after, sort, withHidden)
+}
+
+func (s *MemoryStore) commentPages(index map[int][]int, ids []int, first int, after string, sort model.CommentSort, withHidden bool) (map[int]*model.CommentConnection, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	first, err := pageSize(first)
+	if err != nil {
+		return nil, err
+	}
+
+	order, err := orderFor(sort)
+	if err != nil {
+		return nil, err
 	}
 
-	comments, err := s.GetComments(id, offset, limit)
+	pos, err := order.decodeCursor(after)
 	if err != nil {
 		return nil, err
 	}
 
-	post.Comments = comments
+	result := make(map[int]*model.CommentConnection, len(ids))
+	for _, id := range ids {
+		commentIDs := index[id]
+		if !withHidden {
+			commentIDs = s.visibleComments(commentIDs)
+		}
+		result[id] = s.commentPage(commentIDs, first, pos, order)
+	}
 
-	return post, nil
+	return result, nil
 }
 
-func (s *MemoryStore) GetComments(postID, offset, limit int) ([]*model.Comment, error) {
-	s.mu.RLock()
-	defer s.mu.RUnlock()
+// visibleComments возвращает комментарии ids, не скрытые модератором
+func (s *MemoryStore) visibleComments(ids []int) []int {
+	visible := make([]int, 0, len(ids))
+	for _, id := range ids {
+		if !s.comments[id].IsHidden {
+			visible = append(visible, id)
+		}
+	}
+	return visible
+}
 
-	var comments []*model.Comment
-	for _, comment := range s.comments {
-		if comment.PostID == postID && comment.ParentID == nil {
-			comments = append(comments, comment)
+// inHiddenThread сообщает, скрыт ли комментарий или один из его предков:
+// ответы скрытого комментария обычные пользователи тоже не видят
+func (s *MemoryStore) inHiddenThread(c *model.Comment) bool {
+	for {
+		if c.IsHidden {
+			return true
 		}
+		if c.ParentID == nil {
+			return false
+		}
+		c = s.comments[*c.ParentID]
+	}
+}
+
+// commentPage строит страницу из first комментариев ids, следующих за позицией pos.
+// ids должны быть отсортированы по возрастанию.
+func (s *MemoryStore) commentPage(ids []int, first int, pos *position, order commentOrder) *model.CommentConnection {
+	// В индексе ID уже идут по возрастанию, остальные порядки требуют сортировки
+	if order.key != nil || order.newestFirst {
+		ids = append([]int(nil), ids...)
+		sort.SliceStable(ids, func(i, j int) bool {
+			return order.less(s.comments[ids[i]], s.comments[ids[j]])
+		})
 	}
 
-	// Если количество комментариев меньше offset, возвращаем пустой список
-	if len(comments) <= offset {
-		return []*model.Comment{}, nil
+	start := 0
+	if pos != nil {
+		start = sort.Search(len(ids), func(i int) bool {
+			return order.after(s.comments[ids[i]], *pos)
+		})
 	}
+	end := min(start+first, len(ids))
 
-	// Вычисляем конечный индекс для среза комментариев
-	end := offset + limit
-	if end > len(comments) {
-		end = len(comments)
+	comments := make([]*model.Comment, 0, end-start)
+	for _, id := range ids[start:end] {
+		comments = append(comments, s.comment(id))
 	}
 
-	return comments[offset:end], nil
+	return newCommentConnection(comments, len(ids), end < len(ids), order)
+}
+
+// comment возвращает копию комментария с актуальным числом ответов
+func (s *MemoryStore) comment(id int) *model.Comment {
+	c := *s.comments[id]
+	c.ReplyCount = len(s.replies[id])
+	c.HiddenReplyCount = 0
+	for _, replyID := range s.replies[id] {
+		if s.comments[replyID].IsHidden {
+			c.HiddenReplyCount++
+		}
+	}
+	return &c
 }
 
 func (s *MemoryStore) GetComment(id int) (*model.Comment, error) {
 	s.mu.RLock()
 	defer s.mu.RUnlock()
 
+	if _, ok := s.commentOf(id); !ok {
+		return nil, commentNotFound(id)
+	}
+
+	return s.comment(id), nil
+}
+
+// commentOf возвращает комментарий, если его пост не удалён. Комментарии удалённого
+// поста остаются в s.comments, пока пост не стёрт PurgePost.
+func (s *MemoryStore) commentOf(id int) (*model.Comment, bool) {
 	comment, ok := s.comments[id]
 	if !ok {
-		return nil, errors.New("comment not found")
+		return nil, false
 	}
-
-	return comment, nil
+	if _, ok := s.posts[comment.PostID]; !ok {
+		return nil, false
+	}
+	return comment, true
 }
 
-func (s *MemoryStore) CreatePost(title, content, author string) (*model.Post, error) {
+func (s *MemoryStore) CreatePost(title, content, author string, tags []string, status model.PostStatus, publishAt *time.Time) (*model.Post, error) {
+	tags, err := normalizeTags(tags)
+	if err != nil {
+		return nil, err
+	}
+
+	now := s.now()
+	publishAt, err = publication(status, publishAt, now)
+	if err != nil {
+		return nil, err
+	}
+
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
-	id := len(s.posts) + 1
+	s.lastPostID++
+	id := s.lastPostID
 	post := &model.Post{
 		ID:              id,
 		Title:           title,
 		Content:         content,
 		CommentsEnabled: true,
 		Author:          author,
+		Status:          status,
+		PublishAt:       publishAt,
+		CreatedAt:       now,
+		UpdatedAt:       now,
 	}
 	s.posts[id] = post
+	s.postIDs = append(s.postIDs, id)
+	s.postIndex.set(id, title, content)
+	if len(tags) > 0 {
+		s.postTags[id] = tags
+	}
 
-	return post, nil
+	p := *post
+	return &p, nil
 }
 
 func (s *MemoryStore) CreateComment(postID int, author, content string, parentID *int) (*model.Comment, error) {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
-	if len(content) > 2000 {
-		return nil, errors.New("comment is too long")
-	}
-
 	post, ok := s.posts[postID]
 	if !ok {
-		return nil, errors.New("post not found")
+		return nil, postNotFound(postID)
+	}
+
+	if err := checkPublished(post.Status, post.Author, author, postNotFound(postID)); err != nil {
+		return nil, err
 	}
 
 	if !post.CommentsEnabled {
-		return nil, errors.New("comments are disabled for this post")
+		return nil, ErrCommentsDisabled
 	}
 
-	id := len(s.comments) + 1
+	// Ответ в скрытой ветке увидели бы подписчики commentAdded, поэтому он запрещён
+	if parentID != nil {
+		parent, ok := s.comments[*parentID]
+		if !ok || parent.PostID != postID || parent.IsDeleted || s.inHiddenThread(parent) {
+			return nil, ErrInvalidParent
+		}
+	}
+
+	s.lastCommentID++
+	id := s.lastCommentID
+	now := s.now()
 	comment := &model.Comment{
-		ID:       id,
-		PostID:   postID,
-		Author:   author,
-		Content:  content,
-		ParentID: parentID,
+		ID:        id,
+		PostID:    postID,
+		Author:    author,
+		Content:   content,
+		ParentID:  parentID,
+		CreatedAt: now,
+		UpdatedAt: now,
 	}
 	s.comments[id] = comment
+	s.commentIndex.set(id, "", content)
 
 	if parentID != nil {
-		parentComment, ok := s.comments[*parentID]
-		if !ok {
-			return nil, errors.New("parent comment not found")
-		}
-		parentComment.Child = append(parentComment.Child, comment)
+		s.replies[*parentID] = append(s.replies[*parentID], id)
 	} else {
-		post.Comments = append(post.Comments, comment)
+		s.postComments[postID] = append(s.postComments[postID], id)
 	}
 
-	return comment, nil
+	return s.comment(id), nil
 }
 
-func (s *MemoryStore) UpdatePost(id int, title, content string) (*model.Post, error) {
+func (s *MemoryStore) UpdatePost(id int, title, content string, tags []string, editor string) (*model.Post, error) {
+	tags, err := normalizeTags(tags)
+	if err != nil {
+		return nil, err
+	}
+
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
 	post, ok := s.posts[id]
 	if !ok {
-		return nil, errors.New("post not found")
+		return nil, postNotFound(id)
 	}
 
+	now := s.now()
+	oldTitle := post.Title
+	s.postRevisions[id] = appendRevision(s.postRevisions[id], &oldTitle, post.Content, editor, now)
+
 	post.Title = title
 	post.Content = content
+	post.EditedAt = &now
+	post.UpdatedAt = now
+	s.postIndex.set(id, title, content)
+	if len(tags) > 0 {
+		s.postTags[id] = tags
+	} else if tags != nil {
+		delete(s.postTags, id)
+	}
 
-	return post, nil
+	p := *post
+	return &p, nil
 }
 
-func (s *MemoryStore) UpdateComment(id int, content string) (*model.Comment, error) {
+func (s *MemoryStore) UpdateComment(id int, content, editor string) (*model.Comment, error) {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
-	if len(content) > 2000 {
-		return nil, errors.New("comment is too long")
+	comment, ok := s.commentOf(id)
+	if !ok || comment.IsDeleted {
+		return nil, commentNotFound(id)
 	}
 
-	comment, ok := s.comments[id]
+	now := s.now()
+	s.commentRevisions[id] = appendRevision(s.commentRevisions[id], nil, comment.Content, editor, now)
+
+	comment.Content = content
+	comment.EditedAt = &now
+	comment.UpdatedAt = now
+	s.commentIndex.set(id, "", content)
+
+	return s.comment(id), nil
+}
+
+func (s *MemoryStore) SetCommentsEnabled(postID int, enabled bool) (*model.Post, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	post, ok := s.posts[postID]
 	if !ok {
-		return nil, errors.New("comment not found")
+		return nil, postNotFound(postID)
 	}
 
-	comment.Content = content
+	s.setCommentsEnabled(post, enabled)
 
-	return comment, nil
+	p := *post
+	return &p, nil
 }
 
-func (s *MemoryStore) DisableComments(postID int) error {
+func (s *MemoryStore) setCommentsEnabled(post *model.Post, enabled bool) {
+	post.CommentsEnabled = enabled
+	if enabled {
+		post.CommentsLockReason = nil
+		post.AutoLockAfterDays = nil
+	} else {
+		reason := model.CommentLockReasonManual
+		post.CommentsLockReason = &reason
+	}
+	post.UpdatedAt = s.now()
+}
+
+func (s *MemoryStore) SetAutoLock(postID int, afterDays *int) (*model.Post, error) {
+	if err := validateAutoLock(afterDays); err != nil {
+		return nil, err
+	}
+
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
 	post, ok := s.posts[postID]
 	if !ok {
-		return errors.New("post not found")
+		return nil, postNotFound(postID)
+	}
+
+	post.AutoLockAfterDays = afterDays
+	post.UpdatedAt = s.now()
+
+	p := *post
+	return &p, nil
+}
+
+func (s *MemoryStore) LockExpiredPosts(now time.Time) ([]int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	locked := []int{}
+	for _, id := range s.postIDs {
+		post := s.posts[id]
+		// Срок считается с момента публикации
+		if post.Status != model.PostStatusPublished || !post.CommentsEnabled || post.AutoLockAfterDays == nil {
+			continue
+		}
+		if now.Before(post.PublishAt.AddDate(0, 0, *post.AutoLockAfterDays)) {
+			continue
+		}
+
+		reason := model.CommentLockReasonAuto
+		post.CommentsEnabled = false
+		post.CommentsLockReason = &reason
+		post.UpdatedAt = now
+		locked = append(locked, id)
+	}
+
+	return locked, nil
+}
+
+func (s *MemoryStore) PublishPost(id int, publishAt *time.Time) (*model.Post, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	post, ok := s.posts[id]
+	if !ok {
+		return nil, postNotFound(id)
+	}
+	if post.Status == model.PostStatusPublished {
+		return nil, fmt.Errorf("%w: post %d is already published", ErrInvalidArgument, id)
+	}
+
+	now := s.now()
+	if publishAt == nil || !publishAt.After(now) {
+		post.Status, post.PublishAt = model.PostStatusPublished, &now
+	} else {
+		post.Status, post.PublishAt = model.PostStatusScheduled, publishAt
+	}
+	post.UpdatedAt = now
+
+	p := *post
+	return &p, nil
+}
+
+func (s *MemoryStore) PublishScheduledPosts(now time.Time) ([]int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	published := []int{}
+	for _, id := range s.postIDs {
+		post := s.posts[id]
+		if post.Status != model.PostStatusScheduled || post.PublishAt.After(now) {
+			continue
+		}
+
+		post.Status = model.PostStatusPublished
+		post.UpdatedAt = now
+		published = append(published, id)
+	}
+
+	return published, nil
+}
+
+func (s *MemoryStore) DeletePost(id int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	post, ok := s.posts[id]
+	if !ok {
+		return postNotFound(id)
 	}
 
-	post.CommentsEnabled = false
+	delete(s.posts, id)
+	s.removePostID(id)
+	s.deletedPosts[id] = post
+	s.postIndex.remove(id)
 
 	return nil
 }
+
+func (s *MemoryStore) DeleteComment(id int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.deleteComment(id)
+}
+
+func (s *MemoryStore) deleteComment(id int) error {
+	comment, ok := s.commentOf(id)
+	if !ok || comment.IsDeleted {
+		return commentNotFound(id)
+	}
+
+	comment.IsDeleted = true
+	comment.Author = ""
+	comment.Content = ""
+	comment.UpdatedAt = s.now()
+	// История правок хранит прежний текст, поэтому удаляется вместе с ним
+	delete(s.commentRevisions, id)
+	delete(s.reactions, id)
+	s.commentIndex.remove(id)
+	s.prune(id)
+
+	return nil
+}
+
+func (s *MemoryStore) PurgePost(id int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.posts[id]; ok {
+		s.removePostID(id)
+	} else if _, ok := s.deletedPosts[id]; !ok {
+		return postNotFound(id)
+	}
+
+	for _, commentID := range s.postComments[id] {
+		s.removeSubtree(commentID)
+	}
+
+	delete(s.posts, id)
+	delete(s.deletedPosts, id)
+	delete(s.postComments, id)
+	delete(s.postTags, id)
+	s.postIndex.remove(id)
+	delete(s.postRevisions, id)
+	delete(s.votes, voteKey{model.VoteTargetPost, id})
+
+	return nil
+}
+
+func (s *MemoryStore) PurgeComment(id int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	comment, ok := s.comments[id]
+	if !ok {
+		return commentNotFound(id)
+	}
+
+	s.unlink(comment)
+	s.removeSubtree(id)
+	if comment.ParentID != nil {
+		s.prune(*comment.ParentID)
+	}
+
+	return nil
+}
+
+func (s *MemoryStore) removePostID(id int) {
+	i := sort.SearchInts(s.postIDs, id)
+	if i < len(s.postIDs) && s.postIDs[i] == id {
+		s.postIDs = append(s.postIDs[:i], s.postIDs[i+1:]...)
+	}
+}
+
+// prune убирает из дерева удалённый комментарий id, если ответов у него не осталось,
+// и затем так же проверяет его родителя
+func (s *MemoryStore) prune(id int) {
+	for {
+		comment := s.comments[id]
+		if !comment.IsDeleted || len(s.replies[id]) > 0 {
+			return
+		}
+
+		s.unlink(comment)
+		delete(s.comments, id)
+		delete(s.replies, id)
+		delete(s.votes, voteKey{model.VoteTargetComment, id})
+		delete(s.reactions, id)
+		s.commentIndex.remove(id)
+
+		if comment.ParentID == nil {
+			return
+		}
+		id = *comment.ParentID
+	}
+}
+
+// unlink убирает комментарий из индекса родителя или поста
+func (s *MemoryStore) unlink(comment *model.Comment) {
+	if comment.ParentID != nil {
+		s.replies[*comment.ParentID] = removeID(s.replies[*comment.ParentID], comment.ID)
+	} else {
+		s.postComments[comment.PostID] = removeID(s.postComments[comment.PostID], comment.ID)
+	}
+}
+
+func (s *MemoryStore) removeSubtree(id int) {
+	for _, reply := range s.replies[id] {
+		s.removeSubtree(reply)
+	}
+	delete(s.replies, id)
+	delete(s.comments, id)
+	delete(s.commentRevisions, id)
+	delete(s.votes, voteKey{model.VoteTargetComment, id})
+	delete(s.reactions, id)
+	s.commentIndex.remove(id)
+	for _, reportID := range s.commentReports[id] {
+		delete(s.reports, reportID)
+		s.reportIDs = removeID(s.reportIDs, reportID)
+	}
+	delete(s.commentReports, id)
+}
+
+func removeID(ids []int, id int) []int {
+	i := sort.SearchInts(ids, id)
+	if i < len(ids) && ids[i] == id {
+		return append(ids[:i:i], ids[i+1:]...)
+	}
+	return ids
+}
+
+func (s *MemoryStore) GetPostRevisions(postID int, first int, after string) (*model.RevisionConnection, error) {
+	return s.revisionPage(s.postRevisions, postID, first, after)
+}
+
+func (s *MemoryStore) GetCommentRevisions(commentID int, first int, after string) (*model.RevisionConnection, error) {
+	return s.revisionPage(s.commentRevisions, commentID, first, after)
+}
+
+func (s *MemoryStore) revisionPage(index map[int][]*model.Revision, id int, first int, after string) (*model.RevisionConnection, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	first, err := pageSize(first)
+	if err != nil {
+		return nil, err
+	}
+
+	afterNumber, err := decodeCursor(after)
+	if err != nil {
+		return nil, err
+	}
+
+	// Номера правок идут подряд с единицы, поэтому начало страницы совпадает с номером из курсора
+	revisions := index[id]
+	start := min(afterNumber, len(revisions))
+	end := min(start+first, len(revisions))
+
+	return newRevisionConnection(revisions[start:end], len(revisions), end < len(revisions)), nil
+}
+
+func (s *MemoryStore) GetPostRevision(postID, number int) (*model.Revision, error) {
+	return s.revision(s.postRevisions, postID, number)
+}
+
+func (s *MemoryStore) GetCommentRevision(commentID, number int) (*model.Revision, error) {
+	return s.revision(s.commentRevisions, commentID, number)
+}
+
+func (s *MemoryStore) revision(index map[int][]*model.Revision, id, number int) (*model.Revision, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	revisions := index[id]
+	if number < 1 || number > len(revisions) {
+		return nil, revisionNotFound(number)
+	}
+	return revisions[number-1], nil
+}
+
+func appendRevision(revisions []*model.Revision, title *string, content, editor string, editedAt time.Time) []*model.Revision {
+	return append(revisions, &model.Revision{
+		Number:   len(revisions) + 1,
+		Title:    title,
+		Content:  content,
+		Editor:   editor,
+		EditedAt: editedAt,
+	})
+}
+
+func (s *MemoryStore) Vote(target model.VoteTarget, targetID int, userID string, value int) (*model.VoteResult, error) {
+	if err := validateVote(value); err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// Счётчики голосов хранятся прямо в посте или комментарии
+	var up, down, score *int
+	switch target {
+	case model.VoteTargetPost:
+		post, ok := s.posts[targetID]
+		if !ok {
+			return nil, postNotFound(targetID)
+		}
+		if err := checkPublished(post.Status, post.Author, userID, postNotFound(targetID)); err != nil {
+			return nil, err
+		}
+		up, down, score = &post.Upvotes, &post.Downvotes, &post.Score
+	case model.VoteTargetComment:
+		comment, err := s.commentOfPublished(targetID, userID)
+		if err != nil {
+			return nil, err
+		}
+		up, down, score = &comment.Upvotes, &comment.Downvotes, &comment.Score
+	default:
+		return nil, fmt.Errorf("%w: unknown vote target %s", ErrInvalidArgument, target)
+	}
+
+	key := voteKey{target, targetID}
+	votes := s.votes[key]
+	if votes == nil {
+		votes = make(map[string]int)
+		s.votes[key] = votes
+	}
+
+	*up, *down = applyVote(*up, *down, votes[userID], value)
+	*score = *up - *down
+	if value == 0 {
+		delete(votes, userID)
+	} else {
+		votes[userID] = value
+	}
+
+	return &model.VoteResult{
+		TargetType: target,
+		TargetID:   targetID,
+		Score:      *score,
+		Upvotes:    *up,
+		Downvotes:  *down,
+		MyVote:     value,
+	}, nil
+}
+
+func (s *MemoryStore) GetVotes(target model.VoteTarget, ids []int, userID string) (map[int]int, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	result := make(map[int]int, len(ids))
+	for _, id := range ids {
+		if value, ok := s.votes[voteKey{target, id}][userID]; ok {
+			result[id] = value
+		}
+	}
+
+	return result, nil
+}
+
+// commentOfPublished возвращает неудалённый комментарий, если пост, к которому он
+// написан, опубликован
+func (s *MemoryStore) commentOfPublished(id int, userID string) (*model.Comment, error) {
+	comment, ok := s.commentOf(id)
+	if !ok || comment.IsDeleted {
+		return nil, commentNotFound(id)
+	}
+	post := s.posts[comment.PostID]
+	if err := checkPublished(post.Status, post.Author, userID, commentNotFound(id)); err != nil {
+		return nil, err
+	}
+	return comment, nil
+}
+
+func (s *MemoryStore) AddReaction(commentID int, userID, emoji string) ([]*model.Reaction, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, err := s.commentOfPublished(commentID, userID); err != nil {
+		return nil, err
+	}
+
+	var r *reaction
+	for _, existing := range s.reactions[commentID] {
+		if existing.emoji == emoji {
+			r = existing
+			break
+		}
+	}
+	if r == nil {
+		r = &reaction{emoji: emoji, users: make(map[string]bool)}
+		s.reactions[commentID] = append(s.reactions[commentID], r)
+	}
+	r.users[userID] = true
+
+	return reactionsFor(s.reactions[commentID], userID), nil
+}
+
+func (s *MemoryStore) RemoveReaction(commentID int, userID, emoji string) ([]*model.Reaction, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, err := s.commentOfPublished(commentID, userID); err != nil {
+		return nil, err
+	}
+
+	reactions := s.reactions[commentID]
+	for i, r := range reactions {
+		if r.emoji != emoji {
+			continue
+		}
+		delete(r.users, userID)
+		// Реакция, которую сняли все, при повторной постановке окажется в конце
+		if len(r.users) == 0 {
+			reactions = append(reactions[:i:i], reactions[i+1:]...)
+		}
+		break
+	}
+
+	if len(reactions) == 0 {
+		delete(s.reactions, commentID)
+	} else {
+		s.reactions[commentID] = reactions
+	}
+
+	return reactionsFor(reactions, userID), nil
+}
+
+func (s *MemoryStore) GetReactions(commentIDs []int, userID string) (map[int][]*model.Reaction, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	result := make(map[int][]*model.Reaction, len(commentIDs))
+	for _, id := range commentIDs {
+		if reactions, ok := s.reactions[id]; ok {
+			result[id] = reactionsFor(reactions, userID)
+		}
+	}
+
+	return result, nil
+}
+
+func reactionsFor(reactions []*reaction, userID string) []*model.Reaction {
+	result := make([]*model.Reaction, len(reactions))
+	for i, r := range reactions {
+		result[i] = &model.Reaction{
+			Emoji:       r.emoji,
+			Count:       len(r.users),
+			ReactedByMe: r.users[userID],
+		}
+	}
+	return result
+}
+
+func (s *MemoryStore) SearchPosts(query string, first int, after string) (*model.PostSearchConnection, error) {
+	terms, err := searchTerms(query)
+	if err != nil {
+		return nil, err
+	}
+
+	first, err = pageSize(first)
+	if err != nil {
+		return nil, err
+	}
+
+	pos, err := decodeKeyCursor(after)
+	if err != nil {
+		return nil, err
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	// Удалённые посты убираются из индекса, неопубликованные в поиск не попадают
+	hits := s.postIndex.search(terms)
+	visible := hits[:0]
+	for _, hit := range hits {
+		if s.posts[hit.id].Status == model.PostStatusPublished {
+			visible = append(visible, hit)
+		}
+	}
+	page, hasNext := searchPage(visible, first, pos)
+
+	posts := make([]*model.Post, len(page))
+	snippets := make([]string, len(page))
+	for i, hit := range page {
+		p := *s.posts[hit.id]
+		posts[i] = &p
+		snippets[i] = snippet(p.Content, terms)
+	}
+
+	return newPostSearchConnection(posts, page, snippets, len(visible), hasNext), nil
+}
+
+func (s *MemoryStore) SearchComments(postID *int, query string, first int, after string) (*model.CommentSearchConnection, error) {
+	terms, err := searchTerms(query)
+	if err != nil {
+		return nil, err
+	}
+
+	first, err = pageSize(first)
+	if err != nil {
+		return nil, err
+	}
+
+	pos, err := decodeKeyCursor(after)
+	if err != nil {
+		return nil, err
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	// Комментарии удалённых постов остаются в индексе, но в выдачу не попадают
+	hits := s.commentIndex.search(terms)
+	visible := hits[:0]
+	for _, hit := range hits {
+		comment := s.comments[hit.id]
+		if _, ok := s.posts[comment.PostID]; !ok || s.inHiddenThread(comment) || postID != nil && comment.PostID != *postID {
+			continue
+		}
+		visible = append(visible, hit)
+	}
+	page, hasNext := searchPage(visible, first, pos)
+
+	comments := make([]*model.Comment, len(page))
+	snippets := make([]string, len(page))
+	for i, hit := range page {
+		comments[i] = s.comment(hit.id)
+		snippets[i] = snippet(comments[i].Content, terms)
+	}
+
+	return newCommentSearchConnection(comments, page, snippets, len(visible), hasNext), nil
+}
+
+func (s *MemoryStore) GetPostTags(postIDs []int) (map[int][]string, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	result := make(map[int][]string, len(postIDs))
+	for _, id := range postIDs {
+		if tags, ok := s.postTags[id]; ok {
+			result[id] = append([]string(nil), tags...)
+		}
+	}
+
+	return result, nil
+}
+
+func (s *MemoryStore) GetTags(first int) ([]*model.Tag, error) {
+	first, err := pageSize(first)
+	if err != nil {
+		return nil, err
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	// Учитываются только опубликованные посты
+	counts := make(map[string]int)
+	for id, tags := range s.postTags {
+		if post, ok := s.posts[id]; !ok || post.Status != model.PostStatusPublished {
+			continue
+		}
+		for _, tag := range tags {
+			counts[tag]++
+		}
+	}
+
+	tags := make([]*model.Tag, 0, len(counts))
+	for name, count := range counts {
+		tags = append(tags, &model.Tag{Name: name, Count: count})
+	}
+	sortTags(tags)
+
+	return tags[:min(first, len(tags))], nil
+}
+
+// sortTags упорядочивает теги по убыванию популярности, а при равной популярности - по алфавиту
+func sortTags(tags []*model.Tag) {
+	sort.Slice(tags, func(i, j int) bool {
+		if tags[i].Count != tags[j].Count {
+			return tags[i].Count > tags[j].Count
+		}
+		return tags[i].Name < tags[j].Name
+	})
+}
+
+func (s *MemoryStore) ReportComment(commentID int, reporter, reason string) (*model.Report, error) {
+	if err := validateReportReason(reason); err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	comment, ok := s.commentOf(commentID)
+	if !ok || comment.IsDeleted {
+		return nil, commentNotFound(commentID)
+	}
+	for _, id := range s.commentReports[commentID] {
+		if s.reports[id].Reporter == reporter {
+			return nil, fmt.Errorf("%w: comment %d is already reported by %s", ErrInvalidArgument, commentID, reporter)
+		}
+	}
+
+	s.lastReportID++
+	report := &model.Report{
+		ID:        s.lastReportID,
+		CommentID: commentID,
+		Reporter:  reporter,
+		Reason:    reason,
+		Status:    model.ReportStatusOpen,
+		CreatedAt: s.now(),
+	}
+	s.reports[report.ID] = report
+	s.reportIDs = append(s.reportIDs, report.ID)
+	s.commentReports[commentID] = append(s.commentReports[commentID], report.ID)
+
+	r := *report
+	return &r, nil
+}
+
+func (s *MemoryStore) GetReports(first int, after string, status model.ReportStatus) (*model.ReportConnection, error) {
+	first, err := pageSize(first)
+	if err != nil {
+		return nil, err
+	}
+
+	afterID, err := decodeCursor(after)
+	if err != nil {
+		return nil, err
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	total := 0
+	reports := []*model.Report{}
+	hasNextPage := false
+	for _, id := range s.reportIDs {
+		report := s.reports[id]
+		if report.Status != status {
+			continue
+		}
+		total++
+		if id <= afterID {
+			continue
+		}
+		if len(reports) == first {
+			hasNextPage = true
+			continue
+		}
+		r := *report
+		reports = append(reports, &r)
+	}
+
+	return newReportConnection(reports, total, hasNextPage), nil
+}
+
+func (s *MemoryStore) ResolveReport(id int, action model.ReportAction, moderator string) (*model.Report, error) {
+	status, err := reportStatusFor(action)
+	if err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	report, ok := s.reports[id]
+	if !ok {
+		return nil, reportNotFound(id)
+	}
+	if report.Status != model.ReportStatusOpen {
+		return nil, fmt.Errorf("%w: report %d is already resolved", ErrInvalidArgument, id)
+	}
+
+	comment, ok := s.comments[report.CommentID]
+	if action != model.ReportActionDismiss && (!ok || comment.IsDeleted) {
+		return nil, commentNotFound(report.CommentID)
+	}
+
+	switch action {
+	case model.ReportActionHideComment:
+		comment.IsHidden = true
+	case model.ReportActionDeleteComment:
+		if err := s.deleteComment(comment.ID); err != nil {
+			return nil, err
+		}
+	case model.ReportActionLockPost:
+		post, ok := s.posts[comment.PostID]
+		if !ok {
+			return nil, postNotFound(comment.PostID)
+		}
+		s.setCommentsEnabled(post, false)
+	}
+
+	now := s.now()
+	for _, reportID := range s.commentReports[report.CommentID] {
+		r := s.reports[reportID]
+		if r.Status != model.ReportStatusOpen {
+			continue
+		}
+		r.Status, r.Action = status, &action
+		r.ResolvedBy, r.ResolvedAt = &moderator, &now
+	}
+
+	r := *report
+	return &r, nil
+}
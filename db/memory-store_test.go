@@ -2,35 +2,82 @@ package db
 
 import (
 	"PostCommentService/graph/model"
+	"errors"
+	"fmt"
+	"strings"
 	"testing"
+	"time"
 )
 
 func TestGetPostsMemory(t *testing.T) {
 	store := NewMemoryStore()
 
-	post1 := &model.Post{ID: 1, Title: "Post 1"}
-	post2 := &model.Post{ID: 2, Title: "Post 2"}
+	post1 := &model.Post{ID: 1, Title: "Post 1", Status: model.PostStatusPublished}
+	post2 := &model.Post{ID: 2, Title: "Post 2", Status: model.PostStatusPublished}
 
 	store.posts[1] = post1
 	store.posts[2] = post2
+	store.postIDs = []int{1, 2}
 
-	posts, err := store.GetPosts()
+	posts, err := store.GetPosts(10, "", model.PostSortID, nil, "")
 	if err != nil {
 		t.Errorf("error was not expected while getting posts: %s", err)
 	}
 
-	if len(posts) != 2 {
-		t.Errorf("expected 2 posts, got %d", len(posts))
+	if len(posts.Edges) != 2 || posts.TotalCount != 2 {
+		t.Errorf("expected 2 posts, got %d of %d", len(posts.Edges), posts.TotalCount)
+	}
+}
+
+func TestGetPostsPaginationMemory(t *testing.T) {
+	store := NewMemoryStore()
+
+	for i := 0; i < 5; i++ {
+		author := "Author"
+		if i%2 == 1 {
+			author = "Other"
+		}
+		store.CreatePost("Title", "Content", author, nil, model.PostStatusPublished, nil)
+	}
+	store.SetCommentsEnabled(5, false)
+
+	posts, err := store.GetPosts(2, "", model.PostSortNewest, nil, "")
+	if err != nil {
+		t.Fatalf("error was not expected while getting posts: %s", err)
+	}
+
+	if len(posts.Edges) != 2 || posts.Edges[0].Node.ID != 5 || posts.Edges[1].Node.ID != 4 || !posts.PageInfo.HasNextPage {
+		t.Errorf("unexpected first page: %+v", posts.Edges)
+	}
+
+	posts, err = store.GetPosts(2, *posts.PageInfo.EndCursor, model.PostSortNewest, nil, "")
+	if err != nil {
+		t.Fatalf("error was not expected while getting posts: %s", err)
+	}
+
+	if len(posts.Edges) != 2 || posts.Edges[0].Node.ID != 3 || posts.Edges[1].Node.ID != 2 {
+		t.Errorf("unexpected second page: %+v", posts.Edges)
+	}
+
+	author := "Author"
+	enabled := true
+	posts, err = store.GetPosts(10, "", model.PostSortID, &model.PostFilter{Author: &author, CommentsEnabled: &enabled}, "")
+	if err != nil {
+		t.Fatalf("error was not expected while getting posts: %s", err)
+	}
+
+	if posts.TotalCount != 2 || len(posts.Edges) != 2 || posts.Edges[0].Node.ID != 1 || posts.Edges[1].Node.ID != 3 || posts.PageInfo.HasNextPage {
+		t.Errorf("unexpected filtered posts: total %d, %+v", posts.TotalCount, posts.Edges)
 	}
 }
 
 func TestGetPostMemory(t *testing.T) {
 	store := NewMemoryStore()
 
-	post := &model.Post{ID: 1, Title: "Post 1"}
+	post := &model.Post{ID: 1, Title: "Post 1", Status: model.PostStatusPublished}
 	store.posts[1] = post
 
-	gotPost, err := store.GetPost(1, 0, 10)
+	gotPost, err := store.GetPost(1)
 	if err != nil {
 		t.Errorf("error was not expected while getting post: %s", err)
 	}
@@ -53,20 +100,145 @@ func TestGetCommentsMemory(t *testing.T) {
 
 	store.comments[1] = comment1
 	store.comments[2] = comment2
+	store.postComments[1] = []int{1, 2}
+
+	pages, err := store.GetComments([]int{1}, 10, "", model.CommentSortOld, false)
+	if err != nil {
+		t.Fatalf("error was not expected while getting comments: %s", err)
+	}
+
+	comments := pages[1]
+	if len(comments.Edges) != 2 || comments.TotalCount != 2 {
+		t.Errorf("expected 2 comments, got %d of %d", len(comments.Edges), comments.TotalCount)
+	}
+
+	if comments.Edges[0].Node.ID != 1 || comments.Edges[1].Node.ID != 2 {
+		t.Errorf("unexpected comment order: %d, %d", comments.Edges[0].Node.ID, comments.Edges[1].Node.ID)
+	}
+}
 
-	comments, err := store.GetComments(1, 0, 10)
+func TestGetCommentsBatchMemory(t *testing.T) {
+	store := NewMemoryStore()
+
+	first, _ := store.CreatePost("Title", "Content", "Author", nil, model.PostStatusPublished, nil)
+	second, _ := store.CreatePost("Title", "Content", "Author", nil, model.PostStatusPublished, nil)
+	empty, _ := store.CreatePost("Title", "Content", "Author", nil, model.PostStatusPublished, nil)
+	store.CreateComment(first.ID, "Author", "Content", nil)
+	store.CreateComment(second.ID, "Author", "Content", nil)
+	store.CreateComment(second.ID, "Author", "Content", nil)
+
+	pages, err := store.GetComments([]int{first.ID, second.ID, empty.ID}, 10, "", model.CommentSortOld, false)
 	if err != nil {
-		t.Errorf("error was not expected while getting comments: %s", err)
+		t.Fatalf("error was not expected while getting comments: %s", err)
+	}
+
+	if pages[first.ID].TotalCount != 1 || pages[second.ID].TotalCount != 2 || pages[empty.ID].TotalCount != 0 {
+		t.Errorf("unexpected comment counts: %d, %d, %d", pages[first.ID].TotalCount, pages[second.ID].TotalCount, pages[empty.ID].TotalCount)
 	}
 
-	if len(comments) != 2 {
-		t.Errorf("expected 2 comments, got %d", len(comments))
+	if pages[empty.ID].Edges == nil || pages[empty.ID].PageInfo.EndCursor != nil {
+		t.Errorf("unexpected empty page: %+v", pages[empty.ID])
+	}
+}
+
+func TestGetCommentsPaginationMemory(t *testing.T) {
+	store := NewMemoryStore()
+
+	post, _ := store.CreatePost("Title", "Content", "Author", nil, model.PostStatusPublished, nil)
+	for i := 0; i < 5; i++ {
+		comment, _ := store.CreateComment(post.ID, "Author", "Content", nil)
+		store.CreateComment(post.ID, "Author", "Reply", &comment.ID)
+	}
+
+	var ids []int
+	after := ""
+	for page := 0; page < 3; page++ {
+		pages, err := store.GetComments([]int{post.ID}, 2, after, model.CommentSortOld, false)
+		if err != nil {
+			t.Fatalf("error was not expected while getting comments: %s", err)
+		}
+
+		comments := pages[post.ID]
+		if comments.TotalCount != 5 {
+			t.Errorf("expected total count 5, got %d", comments.TotalCount)
+		}
+
+		for _, edge := range comments.Edges {
+			ids = append(ids, edge.Node.ID)
+		}
+
+		hasNext := page < 2
+		if comments.PageInfo.HasNextPage != hasNext {
+			t.Errorf("page %d: expected hasNextPage %v, got %v", page, hasNext, comments.PageInfo.HasNextPage)
+		}
+
+		if comments.PageInfo.EndCursor == nil {
+			t.Fatalf("page %d: end cursor should not be nil", page)
+		}
+		after = *comments.PageInfo.EndCursor
+	}
+
+	expected := []int{1, 3, 5, 7, 9}
+	if len(ids) != len(expected) {
+		t.Fatalf("expected comments %v, got %v", expected, ids)
+	}
+	for i := range expected {
+		if ids[i] != expected[i] {
+			t.Fatalf("expected comments %v, got %v", expected, ids)
+		}
+	}
+
+	if _, err := store.GetComments([]int{post.ID}, 2, "not a cursor", model.CommentSortOld, false); err != ErrInvalidCursor {
+		t.Errorf("expected ErrInvalidCursor, got %v", err)
+	}
+}
+
+func TestGetRepliesMemory(t *testing.T) {
+	store := NewMemoryStore()
+
+	post, _ := store.CreatePost("Title", "Content", "Author", nil, model.PostStatusPublished, nil)
+	parent, _ := store.CreateComment(post.ID, "Author", "Parent", nil)
+	other, _ := store.CreateComment(post.ID, "Author", "Other", nil)
+	for i := 0; i < 3; i++ {
+		store.CreateComment(post.ID, "Author", "Reply", &parent.ID)
+	}
+	nested, _ := store.CreateComment(post.ID, "Author", "Nested", &other.ID)
+	store.CreateComment(post.ID, "Author", "Deep reply", &nested.ID)
+
+	replies, err := store.GetReplies([]int{parent.ID, other.ID}, 2, "", model.CommentSortOld, false)
+	if err != nil {
+		t.Fatalf("error was not expected while getting replies: %s", err)
+	}
+
+	first := replies[parent.ID]
+	if len(first.Edges) != 2 || first.TotalCount != 3 || !first.PageInfo.HasNextPage {
+		t.Errorf("unexpected replies of parent: total %d, %+v", first.TotalCount, first.Edges)
+	}
+
+	second := replies[other.ID]
+	if len(second.Edges) != 1 || second.Edges[0].Node.ID != nested.ID || second.Edges[0].Node.ReplyCount != 1 {
+		t.Errorf("unexpected replies of other comment: %+v", second.Edges)
+	}
+
+	next, err := store.GetReplies([]int{parent.ID}, 2, *first.PageInfo.EndCursor, model.CommentSortOld, false)
+	if err != nil {
+		t.Fatalf("error was not expected while getting replies: %s", err)
+	}
+
+	if len(next[parent.ID].Edges) != 1 || next[parent.ID].PageInfo.HasNextPage {
+		t.Errorf("unexpected second page of replies: %+v", next[parent.ID].Edges)
+	}
+
+	gotParent, _ := store.GetComment(parent.ID)
+	if gotParent.ReplyCount != 3 {
+		t.Errorf("expected parent to have 3 replies, got %d", gotParent.ReplyCount)
 	}
 }
 
 func TestGetCommentMemory(t *testing.T) {
 	store := NewMemoryStore()
 
+	store.CreatePost("Title", "Content", "Author", nil, model.PostStatusPublished, nil)
 	comment := &model.Comment{ID: 1, PostID: 1, Author: "Author", Content: "Content"}
 	store.comments[1] = comment
 
@@ -83,12 +255,18 @@ func TestGetCommentMemory(t *testing.T) {
 	if gotComment.ID != 1 || gotComment.Author != "Author" || gotComment.Content != "Content" {
 		t.Errorf("unexpected values in comment: %+v", gotComment)
 	}
+
+	// Вместе с постом недоступны и его комментарии
+	store.DeletePost(1)
+	if _, err := store.GetComment(1); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected %v for a comment of a deleted post, got %v", ErrNotFound, err)
+	}
 }
 
 func TestCreatePostMemory(t *testing.T) {
 	store := NewMemoryStore()
 
-	post, err := store.CreatePost("Title", "Content", "Author")
+	post, err := store.CreatePost("Title", "Content", "Author", nil, model.PostStatusPublished, nil)
 	if err != nil {
 		t.Errorf("error was not expected while creating post: %s", err)
 	}
@@ -101,12 +279,18 @@ func TestCreatePostMemory(t *testing.T) {
 	if post.ID != 1 || post.Title != "Title" || post.Content != "Content" || post.Author != "Author" {
 		t.Errorf("unexpected values in post: %+v", post)
 	}
+
+	// Изменение возвращённого поста не затрагивает хранилище
+	post.Title = "Changed"
+	if stored, _ := store.GetPost(post.ID); stored.Title != "Title" {
+		t.Errorf("stored post should not change, got %q", stored.Title)
+	}
 }
 
 func TestCreateCommentMemory(t *testing.T) {
 	store := NewMemoryStore()
 
-	post, _ := store.CreatePost("Title", "Content", "Author")
+	post, _ := store.CreatePost("Title", "Content", "Author", nil, model.PostStatusPublished, nil)
 	comment, err := store.CreateComment(post.ID, "Author", "Content", nil)
 	if err != nil {
 		t.Errorf("error was not expected while creating comment: %s", err)
@@ -122,11 +306,48 @@ func TestCreateCommentMemory(t *testing.T) {
 	}
 }
 
+func TestCreateCommentErrorsMemory(t *testing.T) {
+	store := NewMemoryStore()
+
+	post, _ := store.CreatePost("Title", "Content", "Author", nil, model.PostStatusPublished, nil)
+	other, _ := store.CreatePost("Other", "Content", "Author", nil, model.PostStatusPublished, nil)
+	foreign, _ := store.CreateComment(other.ID, "Author", "Content", nil)
+	hidden, _ := store.CreateComment(post.ID, "Author", "Spam", nil)
+	underHidden, _ := store.CreateComment(post.ID, "Author", "Reply", &hidden.ID)
+	store.comments[hidden.ID].IsHidden = true
+	missing := 100
+
+	tests := []struct {
+		name     string
+		postID   int
+		content  string
+		parentID *int
+		want     error
+	}{
+		{"post not found", 100, "Content", nil, ErrNotFound},
+		{"missing parent", post.ID, "Content", &missing, ErrInvalidParent},
+		{"parent from another post", post.ID, "Content", &foreign.ID, ErrInvalidParent},
+		{"hidden parent", post.ID, "Content", &hidden.ID, ErrInvalidParent},
+		{"hidden ancestor", post.ID, "Content", &underHidden.ID, ErrInvalidParent},
+	}
+
+	for _, tt := range tests {
+		if _, err := store.CreateComment(tt.postID, "Author", tt.content, tt.parentID); !errors.Is(err, tt.want) {
+			t.Errorf("%s: expected %v, got %v", tt.name, tt.want, err)
+		}
+	}
+
+	store.SetCommentsEnabled(post.ID, false)
+	if _, err := store.CreateComment(post.ID, "Author", "Content", nil); !errors.Is(err, ErrCommentsDisabled) {
+		t.Errorf("expected %v, got %v", ErrCommentsDisabled, err)
+	}
+}
+
 func TestUpdatePostMemory(t *testing.T) {
 	store := NewMemoryStore()
 
-	post, _ := store.CreatePost("Title", "Content", "Author")
-	updatedPost, err := store.UpdatePost(post.ID, "Updated Title", "Updated Content")
+	post, _ := store.CreatePost("Title", "Content", "Author", nil, model.PostStatusPublished, nil)
+	updatedPost, err := store.UpdatePost(post.ID, "Updated Title", "Updated Content", nil, "Editor")
 	if err != nil {
 		t.Errorf("error was not expected while updating post: %s", err)
 	}
@@ -139,14 +360,19 @@ func TestUpdatePostMemory(t *testing.T) {
 	if updatedPost.ID != post.ID || updatedPost.Title != "Updated Title" || updatedPost.Content != "Updated Content" {
 		t.Errorf("unexpected values in updated post: %+v", updatedPost)
 	}
+
+	updatedPost.Content = "Changed"
+	if stored, _ := store.GetPost(post.ID); stored.Content != "Updated Content" {
+		t.Errorf("stored post should not change, got %q", stored.Content)
+	}
 }
 
 func TestUpdateCommentMemory(t *testing.T) {
 	store := NewMemoryStore()
 
-	post, _ := store.CreatePost("Title", "Content", "Author")
+	post, _ := store.CreatePost("Title", "Content", "Author", nil, model.PostStatusPublished, nil)
 	comment, _ := store.CreateComment(post.ID, "Author", "Content", nil)
-	updatedComment, err := store.UpdateComment(comment.ID, "Updated Content")
+	updatedComment, err := store.UpdateComment(comment.ID, "Updated Content", "Editor")
 	if err != nil {
 		t.Errorf("error was not expected while updating comment: %s", err)
 	}
@@ -161,17 +387,583 @@ func TestUpdateCommentMemory(t *testing.T) {
 	}
 }
 
-func TestDisableCommentsMemory(t *testing.T) {
+func TestSetCommentsEnabledMemory(t *testing.T) {
 	store := NewMemoryStore()
 
-	post, _ := store.CreatePost("Title", "Content", "Author")
-	err := store.DisableComments(post.ID)
+	post, _ := store.CreatePost("Title", "Content", "Author", nil, model.PostStatusPublished, nil)
+	days := 3
+	store.SetAutoLock(post.ID, &days)
+
+	locked, err := store.SetCommentsEnabled(post.ID, false)
 	if err != nil {
-		t.Errorf("error was not expected while disabling comments: %s", err)
+		t.Fatalf("error was not expected while disabling comments: %s", err)
+	}
+	if locked.CommentsEnabled || locked.CommentsLockReason == nil || *locked.CommentsLockReason != model.CommentLockReasonManual {
+		t.Errorf("comments should be locked manually: %+v", locked)
+	}
+
+	unlocked, err := store.SetCommentsEnabled(post.ID, true)
+	if err != nil {
+		t.Fatalf("error was not expected while enabling comments: %s", err)
+	}
+	if !unlocked.CommentsEnabled || unlocked.CommentsLockReason != nil || unlocked.AutoLockAfterDays != nil {
+		t.Errorf("enabling comments should clear the lock and the auto-lock rule: %+v", unlocked)
+	}
+
+	if _, err := store.SetCommentsEnabled(100, false); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected %v, got %v", ErrNotFound, err)
+	}
+}
+
+func TestLockExpiredPostsMemory(t *testing.T) {
+	store := NewMemoryStore()
+
+	created := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	store.now = func() time.Time { return created }
+	for i := 0; i < 3; i++ {
+		store.CreatePost("Title", "Content", "Author", nil, model.PostStatusPublished, nil)
+	}
+
+	week, month := 7, 30
+	store.SetAutoLock(1, &week)
+	store.SetAutoLock(2, &month)
+
+	if _, err := store.SetAutoLock(3, new(int)); !errors.Is(err, ErrInvalidArgument) {
+		t.Errorf("expected %v for zero days, got %v", ErrInvalidArgument, err)
+	}
+
+	locked, err := store.LockExpiredPosts(created.AddDate(0, 0, 7))
+	if err != nil {
+		t.Fatalf("error was not expected while locking posts: %s", err)
+	}
+	if len(locked) != 1 || locked[0] != 1 {
+		t.Errorf("expected only post 1 to be locked, got %v", locked)
+	}
+
+	post, _ := store.GetPost(1)
+	if post.CommentsEnabled || post.CommentsLockReason == nil || *post.CommentsLockReason != model.CommentLockReasonAuto {
+		t.Errorf("post 1 should be locked automatically: %+v", post)
+	}
+
+	// Уже закрытый пост повторно не закрывается
+	if locked, _ := store.LockExpiredPosts(created.AddDate(0, 0, 8)); len(locked) != 0 {
+		t.Errorf("expected no posts to be locked, got %v", locked)
+	}
+}
+
+func TestPostStatusMemory(t *testing.T) {
+	store := NewMemoryStore()
+
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	store.now = func() time.Time { return now }
+	later := now.Add(time.Hour)
+
+	if _, err := store.CreatePost("Title", "Content", "Author", nil, model.PostStatusScheduled, nil); !errors.Is(err, ErrInvalidArgument) {
+		t.Errorf("expected %v without publishAt, got %v", ErrInvalidArgument, err)
+	}
+	if _, err := store.CreatePost("Title", "Content", "Author", nil, model.PostStatusScheduled, &now); !errors.Is(err, ErrInvalidArgument) {
+		t.Errorf("expected %v for publishAt in the past, got %v", ErrInvalidArgument, err)
+	}
+
+	draft, _ := store.CreatePost("Draft", "Content", "Author", nil, model.PostStatusDraft, nil)
+	scheduled, _ := store.CreatePost("Scheduled", "Content", "Author", nil, model.PostStatusScheduled, &later)
+	published, _ := store.CreatePost("Published", "Content", "Author", nil, model.PostStatusPublished, nil)
+	if draft.PublishAt != nil || !scheduled.PublishAt.Equal(later) || !published.PublishAt.Equal(now) {
+		t.Errorf("unexpected publishAt: %v, %v, %v", draft.PublishAt, scheduled.PublishAt, published.PublishAt)
+	}
+
+	// Неопубликованные посты видны только автору
+	if posts, _ := store.GetPosts(10, "", model.PostSortID, nil, "Other"); posts.TotalCount != 1 || posts.Edges[0].Node.ID != published.ID {
+		t.Errorf("expected only the published post, got %+v", posts.Edges)
+	}
+	if posts, _ := store.GetPosts(10, "", model.PostSortID, nil, "Author"); posts.TotalCount != 3 {
+		t.Errorf("expected all 3 posts for the author, got %d", posts.TotalCount)
+	}
+
+	// Чужой черновик для остальных не существует, автор узнаёт, что пост не опубликован
+	if _, err := store.CreateComment(draft.ID, "Other", "Comment", nil); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected %v, got %v", ErrNotFound, err)
+	}
+	if _, err := store.Vote(model.VoteTargetPost, scheduled.ID, "Other", 1); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected %v, got %v", ErrNotFound, err)
+	}
+	if _, err := store.CreateComment(draft.ID, "Author", "Comment", nil); !errors.Is(err, ErrPostNotPublished) {
+		t.Errorf("expected %v, got %v", ErrPostNotPublished, err)
+	}
+	if _, err := store.Vote(model.VoteTargetPost, draft.ID, "Author", 1); !errors.Is(err, ErrPostNotPublished) {
+		t.Errorf("expected %v, got %v", ErrPostNotPublished, err)
+	}
+
+	if ids, _ := store.PublishScheduledPosts(now); len(ids) != 0 {
+		t.Errorf("expected no posts to be published yet, got %v", ids)
+	}
+	if ids, _ := store.PublishScheduledPosts(later); len(ids) != 1 || ids[0] != scheduled.ID {
+		t.Errorf("expected the scheduled post to be published, got %v", ids)
+	}
+
+	post, err := store.PublishPost(draft.ID, nil)
+	if err != nil {
+		t.Fatalf("error was not expected while publishing post: %s", err)
+	}
+	if post.Status != model.PostStatusPublished || !post.PublishAt.Equal(now) {
+		t.Errorf("draft should be published now: %+v", post)
+	}
+	if _, err := store.PublishPost(draft.ID, nil); !errors.Is(err, ErrInvalidArgument) {
+		t.Errorf("expected %v for an already published post, got %v", ErrInvalidArgument, err)
+	}
+}
+
+func TestDeleteCommentMemory(t *testing.T) {
+	store := NewMemoryStore()
+	post, _ := store.CreatePost("Title", "Content", "Author", nil, model.PostStatusPublished, nil)
+	root, _ := store.CreateComment(post.ID, "Author", "Root", nil)
+	reply, _ := store.CreateComment(post.ID, "Author", "Reply", &root.ID)
+
+	if err := store.DeleteComment(root.ID); err != nil {
+		t.Fatalf("error was not expected while deleting comment: %s", err)
+	}
+
+	// Комментарий с ответами остаётся в дереве без автора и текста
+	tombstone, err := store.GetComment(root.ID)
+	if err != nil {
+		t.Fatalf("tombstone should stay visible: %s", err)
+	}
+	if !tombstone.IsDeleted || tombstone.Author != "" || tombstone.Content != "" {
+		t.Errorf("unexpected tombstone: %+v", tombstone)
+	}
+	if err := store.DeleteComment(root.ID); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected %v for a deleted comment, got %v", ErrNotFound, err)
+	}
+	if _, err := store.CreateComment(post.ID, "Author", "Reply", &root.ID); !errors.Is(err, ErrInvalidParent) {
+		t.Errorf("expected %v for a deleted parent, got %v", ErrInvalidParent, err)
+	}
+
+	// После удаления последнего ответа скрывается и сам ответ, и надгробие
+	if err := store.DeleteComment(reply.ID); err != nil {
+		t.Fatalf("error was not expected while deleting reply: %s", err)
+	}
+	for _, id := range []int{root.ID, reply.ID} {
+		if _, err := store.GetComment(id); !errors.Is(err, ErrNotFound) {
+			t.Errorf("comment %d should be pruned, got %v", id, err)
+		}
+	}
+	if conn, _ := store.GetComments([]int{post.ID}, 10, "", model.CommentSortOld, false); conn[post.ID].TotalCount != 0 {
+		t.Errorf("expected no comments, got %d", conn[post.ID].TotalCount)
+	}
+
+	// Идентификаторы не переиспользуются
+	comment, _ := store.CreateComment(post.ID, "Author", "New", nil)
+	if comment.ID != reply.ID+1 {
+		t.Errorf("expected id %d, got %d", reply.ID+1, comment.ID)
+	}
+}
+
+func TestDeleteAndPurgePostMemory(t *testing.T) {
+	store := NewMemoryStore()
+	post, _ := store.CreatePost("Title", "Content", "Author", nil, model.PostStatusPublished, nil)
+	other, _ := store.CreatePost("Title", "Content", "Author", nil, model.PostStatusPublished, nil)
+	comment, _ := store.CreateComment(other.ID, "Author", "Comment", nil)
+	store.CreateComment(other.ID, "Author", "Reply", &comment.ID)
+
+	if err := store.DeletePost(post.ID); err != nil {
+		t.Fatalf("error was not expected while deleting post: %s", err)
+	}
+	if _, err := store.GetPost(post.ID); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected %v for a deleted post, got %v", ErrNotFound, err)
+	}
+	if conn, _ := store.GetPosts(10, "", model.PostSortID, nil, ""); conn.TotalCount != 1 {
+		t.Errorf("expected 1 post, got %d", conn.TotalCount)
+	}
+
+	// Удалённый пост можно стереть окончательно
+	if err := store.PurgePost(post.ID); err != nil {
+		t.Errorf("error was not expected while purging deleted post: %s", err)
+	}
+	if err := store.PurgePost(post.ID); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected %v, got %v", ErrNotFound, err)
+	}
+
+	if err := store.PurgeComment(comment.ID); err != nil {
+		t.Fatalf("error was not expected while purging comment: %s", err)
+	}
+	if conn, _ := store.GetComments([]int{other.ID}, 10, "", model.CommentSortOld, false); conn[other.ID].TotalCount != 0 {
+		t.Errorf("replies should be purged with the comment, got %d comments", conn[other.ID].TotalCount)
+	}
+}
+
+func TestRevisionsMemory(t *testing.T) {
+	store := NewMemoryStore()
+	post, _ := store.CreatePost("Title", "Content", "Author", nil, model.PostStatusPublished, nil)
+	comment, _ := store.CreateComment(post.ID, "Author", "First", nil)
+
+	store.UpdatePost(post.ID, "Title 2", "Content 2", nil, "Author")
+	updated, _ := store.UpdatePost(post.ID, "Title 3", "Content 3", nil, "Moderator")
+	if updated.EditedAt == nil {
+		t.Error("editedAt should be set after update")
+	}
+
+	page, err := store.GetPostRevisions(post.ID, 1, "")
+	if err != nil {
+		t.Fatalf("error was not expected while getting revisions: %s", err)
+	}
+	if page.TotalCount != 2 || len(page.Edges) != 1 || !page.PageInfo.HasNextPage {
+		t.Fatalf("unexpected page: %+v", page)
+	}
+	if r := page.Edges[0].Node; r.Number != 1 || *r.Title != "Title" || r.Content != "Content" || r.Editor != "Author" {
+		t.Errorf("first revision should keep the original text: %+v", r)
+	}
+
+	page, _ = store.GetPostRevisions(post.ID, 10, *page.PageInfo.EndCursor)
+	if len(page.Edges) != 1 || page.Edges[0].Node.Content != "Content 2" || page.Edges[0].Node.Editor != "Moderator" || page.PageInfo.HasNextPage {
+		t.Errorf("unexpected second page: %+v", page)
+	}
+
+	if _, err := store.GetPostRevision(post.ID, 3); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected %v, got %v", ErrNotFound, err)
+	}
+
+	store.UpdateComment(comment.ID, "Second", "Author")
+	if r, err := store.GetCommentRevision(comment.ID, 1); err != nil || r.Content != "First" || r.Title != nil {
+		t.Errorf("unexpected comment revision: %+v, %v", r, err)
+	}
+
+	// Удаление комментария стирает и его историю
+	store.DeleteComment(comment.ID)
+	if page, _ := store.GetCommentRevisions(comment.ID, 10, ""); page.TotalCount != 0 {
+		t.Errorf("revisions should be removed with the comment, got %d", page.TotalCount)
+	}
+}
+
+func TestTimestampsMemory(t *testing.T) {
+	store := NewMemoryStore()
+	created := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	store.now = func() time.Time { return created }
+
+	post, _ := store.CreatePost("Title", "Content", "Author", nil, model.PostStatusPublished, nil)
+	comment, _ := store.CreateComment(post.ID, "Author", "Content", nil)
+	if !post.CreatedAt.Equal(created) || !post.UpdatedAt.Equal(created) || !comment.CreatedAt.Equal(created) || !comment.UpdatedAt.Equal(created) {
+		t.Errorf("unexpected timestamps: %+v, %+v", post, comment)
+	}
+
+	edited := created.Add(time.Hour)
+	store.now = func() time.Time { return edited }
+
+	post, _ = store.UpdatePost(post.ID, "Title", "Updated", nil, "Author")
+	comment, _ = store.UpdateComment(comment.ID, "Updated", "Author")
+	if !post.CreatedAt.Equal(created) || !post.UpdatedAt.Equal(edited) || !comment.CreatedAt.Equal(created) || !comment.UpdatedAt.Equal(edited) {
+		t.Errorf("updatedAt should change on edit: %+v, %+v", post, comment)
+	}
+}
+
+func TestVoteMemory(t *testing.T) {
+	store := NewMemoryStore()
+	post, _ := store.CreatePost("Title", "Content", "Author", nil, model.PostStatusPublished, nil)
+
+	store.Vote(model.VoteTargetPost, post.ID, "alice", 1)
+	store.Vote(model.VoteTargetPost, post.ID, "bob", 1)
+	// Повторный голос заменяет прежний
+	result, err := store.Vote(model.VoteTargetPost, post.ID, "bob", -1)
+	if err != nil {
+		t.Fatalf("error was not expected while voting: %s", err)
+	}
+	if result.Score != 0 || result.Upvotes != 1 || result.Downvotes != 1 || result.MyVote != -1 {
+		t.Errorf("unexpected vote result: %+v", result)
+	}
+
+	result, _ = store.Vote(model.VoteTargetPost, post.ID, "alice", 0)
+	if result.Score != -1 || result.Upvotes != 0 {
+		t.Errorf("vote should be withdrawn: %+v", result)
+	}
+
+	votes, _ := store.GetVotes(model.VoteTargetPost, []int{post.ID}, "bob")
+	if votes[post.ID] != -1 {
+		t.Errorf("expected bob's vote -1, got %v", votes)
+	}
+	if votes, _ := store.GetVotes(model.VoteTargetPost, []int{post.ID}, "alice"); len(votes) != 0 {
+		t.Errorf("alice should have no vote, got %v", votes)
+	}
+	if p, _ := store.GetPost(post.ID); p.Score != -1 {
+		t.Errorf("expected stored score -1, got %d", p.Score)
+	}
+
+	if _, err := store.Vote(model.VoteTargetPost, post.ID, "alice", 2); !errors.Is(err, ErrInvalidArgument) {
+		t.Errorf("expected %v, got %v", ErrInvalidArgument, err)
+	}
+	if _, err := store.Vote(model.VoteTargetComment, 42, "alice", 1); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected %v, got %v", ErrNotFound, err)
+	}
+
+	// Комментарий удалённого поста недоступен для голосования
+	comment, _ := store.CreateComment(post.ID, "Author", "Content", nil)
+	store.DeletePost(post.ID)
+	if _, err := store.Vote(model.VoteTargetComment, comment.ID, "alice", 1); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected %v for a comment of a deleted post, got %v", ErrNotFound, err)
+	}
+}
+
+func TestReactionsMemory(t *testing.T) {
+	store := NewMemoryStore()
+	post, _ := store.CreatePost("Title", "Content", "Author", nil, model.PostStatusPublished, nil)
+	comment, _ := store.CreateComment(post.ID, "Author", "Content", nil)
+
+	store.AddReaction(comment.ID, "alice", "🎉")
+	store.AddReaction(comment.ID, "bob", "👍")
+	// Повторная реакция того же вида не учитывается
+	store.AddReaction(comment.ID, "bob", "🎉")
+	reactions, err := store.AddReaction(comment.ID, "bob", "🎉")
+	if err != nil {
+		t.Fatalf("error was not expected while adding reaction: %s", err)
+	}
+	if len(reactions) != 2 || reactions[0].Emoji != "🎉" || reactions[0].Count != 2 || !reactions[0].ReactedByMe || reactions[1].Emoji != "👍" {
+		t.Errorf("unexpected reactions: %+v, %+v", reactions[0], reactions[1])
+	}
+
+	// Реакция, которую сняли все, при повторной постановке оказывается в конце
+	store.RemoveReaction(comment.ID, "alice", "🎉")
+	store.RemoveReaction(comment.ID, "bob", "🎉")
+	store.AddReaction(comment.ID, "alice", "🎉")
+
+	result, _ := store.GetReactions([]int{comment.ID, 42}, "bob")
+	got := result[comment.ID]
+	if len(result) != 1 || len(got) != 2 || got[0].Emoji != "👍" || !got[0].ReactedByMe || got[1].Emoji != "🎉" || got[1].ReactedByMe {
+		t.Errorf("unexpected reactions: %v", result)
+	}
+
+	if err := store.DeleteComment(comment.ID); err != nil {
+		t.Fatalf("error was not expected while deleting comment: %s", err)
+	}
+	if result, _ := store.GetReactions([]int{comment.ID}, "bob"); len(result) != 0 {
+		t.Errorf("reactions of a deleted comment should be removed, got %v", result)
+	}
+	if _, err := store.AddReaction(comment.ID, "bob", "👍"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected %v, got %v", ErrNotFound, err)
+	}
+
+	// Реакции на комментарий удалённого поста не ставятся
+	other, _ := store.CreateComment(post.ID, "Author", "Other", nil)
+	store.DeletePost(post.ID)
+	if _, err := store.AddReaction(other.ID, "bob", "👍"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected %v for a comment of a deleted post, got %v", ErrNotFound, err)
+	}
+	if _, err := store.RemoveReaction(other.ID, "bob", "👍"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected %v for a comment of a deleted post, got %v", ErrNotFound, err)
+	}
+}
+
+func TestSearchMemory(t *testing.T) {
+	store := NewMemoryStore()
+	store.CreatePost("Ёлка", "Праздничная ёлка в парке", "Author", nil, model.PostStatusPublished, nil)
+	store.CreatePost("Погода", "В парке идёт снег, ёлка в снегу", "Author", nil, model.PostStatusPublished, nil)
+	deleted, _ := store.CreatePost("Ёлка", "Удалённый пост", "Author", nil, model.PostStatusPublished, nil)
+	store.DeletePost(deleted.ID)
+
+	posts, err := store.SearchPosts("елка", 1, "")
+	if err != nil {
+		t.Fatalf("error was not expected while searching posts: %s", err)
+	}
+	// Совпадение в заголовке весит больше
+	if posts.TotalCount != 2 || len(posts.Edges) != 1 || posts.Edges[0].Node.ID != 1 || !posts.PageInfo.HasNextPage {
+		t.Fatalf("unexpected first page: %+v", posts)
+	}
+	if posts.Edges[0].Snippet != "Праздничная <mark>ёлка</mark> в парке" {
+		t.Errorf("unexpected snippet: %q", posts.Edges[0].Snippet)
+	}
+
+	posts, _ = store.SearchPosts("елка", 1, *posts.PageInfo.EndCursor)
+	if len(posts.Edges) != 1 || posts.Edges[0].Node.ID != 2 || posts.PageInfo.HasNextPage {
+		t.Errorf("unexpected second page: %+v", posts.Edges)
+	}
+
+	store.UpdatePost(2, "Погода", "Солнечно", nil, "Author")
+	if posts, _ := store.SearchPosts("снег", 10, ""); posts.TotalCount != 0 {
+		t.Errorf("edited post should be reindexed, got %+v", posts.Edges)
+	}
+
+	first, _ := store.CreateComment(1, "Author", "Красивая ёлка", nil)
+	store.CreateComment(2, "Author", "Где ёлка?", nil)
+	store.CreateComment(deleted.ID, "Author", "Ёлка удалённого поста", nil)
+
+	comments, _ := store.SearchComments(nil, "ёлка", 10, "")
+	if comments.TotalCount != 2 {
+		t.Errorf("comments of deleted posts should not be found, got %+v", comments.Edges)
+	}
+	postID := 1
+	comments, _ = store.SearchComments(&postID, "ёлка", 10, "")
+	if comments.TotalCount != 1 || comments.Edges[0].Node.ID != first.ID {
+		t.Errorf("unexpected comments of post 1: %+v", comments.Edges)
+	}
+
+	store.DeleteComment(first.ID)
+	if comments, _ := store.SearchComments(&postID, "ёлка", 10, ""); comments.TotalCount != 0 {
+		t.Errorf("deleted comment should not be found, got %+v", comments.Edges)
+	}
+
+	if _, err := store.SearchPosts(" !? ", 10, ""); !errors.Is(err, ErrInvalidArgument) {
+		t.Errorf("expected %v, got %v", ErrInvalidArgument, err)
+	}
+}
+
+func TestTagsMemory(t *testing.T) {
+	store := NewMemoryStore()
+	store.CreatePost("Go", "Content", "Author", []string{"  Go ", "Web   Dev", "go"}, model.PostStatusPublished, nil)
+	store.CreatePost("News", "Content", "Author", []string{"news", "GO"}, model.PostStatusPublished, nil)
+	deleted, _ := store.CreatePost("Deleted", "Content", "Author", []string{"news"}, model.PostStatusPublished, nil)
+	store.DeletePost(deleted.ID)
+
+	tags, _ := store.GetPostTags([]int{1, 2})
+	if len(tags[1]) != 2 || tags[1][0] != "go" || tags[1][1] != "web dev" {
+		t.Errorf("tags should be normalized and sorted, got %v", tags[1])
+	}
+
+	popular, err := store.GetTags(10)
+	if err != nil {
+		t.Fatalf("error was not expected while getting tags: %s", err)
+	}
+	want := []model.Tag{{Name: "go", Count: 2}, {Name: "news", Count: 1}, {Name: "web dev", Count: 1}}
+	if len(popular) != len(want) {
+		t.Fatalf("unexpected tags: %+v", popular)
+	}
+	for i := range want {
+		if *popular[i] != want[i] {
+			t.Errorf("tag %d: expected %+v, got %+v", i, want[i], *popular[i])
+		}
+	}
+
+	filter := &model.PostFilter{Tags: []string{"GO", "news"}}
+	if posts, _ := store.GetPosts(10, "", model.PostSortID, filter, ""); posts.TotalCount != 1 || posts.Edges[0].Node.ID != 2 {
+		t.Errorf("expected only post 2, got %+v", posts.Edges)
+	}
+
+	// nil оставляет прежние теги, пустой список снимает все
+	store.UpdatePost(2, "News", "Content", nil, "Author")
+	if tags, _ := store.GetPostTags([]int{2}); len(tags[2]) != 2 {
+		t.Errorf("tags should be kept, got %v", tags)
+	}
+	store.UpdatePost(2, "News", "Content", []string{}, "Author")
+	if tags, _ := store.GetPostTags([]int{2}); len(tags) != 0 {
+		t.Errorf("tags should be removed, got %v", tags)
+	}
+
+	long := strings.Repeat("x", maxTagLength+1)
+	if _, err := store.CreatePost("Title", "Content", "Author", []string{long}, model.PostStatusPublished, nil); !errors.Is(err, ErrInvalidArgument) {
+		t.Errorf("expected %v, got %v", ErrInvalidArgument, err)
+	}
+}
+
+func TestReportsMemory(t *testing.T) {
+	store := NewMemoryStore()
+	post, _ := store.CreatePost("Title", "Content", "Author", nil, model.PostStatusPublished, nil)
+	spam, _ := store.CreateComment(post.ID, "Spammer", "Buy now", nil)
+	rude, _ := store.CreateComment(post.ID, "Troll", "Rude words", nil)
+	store.CreateComment(post.ID, "Fan", "Buy it too", &spam.ID)
+
+	if _, err := store.ReportComment(spam.ID, "alice", "  "); !errors.Is(err, ErrInvalidArgument) {
+		t.Errorf("expected %v for an empty reason, got %v", ErrInvalidArgument, err)
+	}
+	first, err := store.ReportComment(spam.ID, "alice", "spam")
+	if err != nil {
+		t.Fatalf("error was not expected while reporting comment: %s", err)
+	}
+	if _, err := store.ReportComment(spam.ID, "alice", "spam again"); !errors.Is(err, ErrInvalidArgument) {
+		t.Errorf("expected %v for a repeated report, got %v", ErrInvalidArgument, err)
+	}
+	store.ReportComment(spam.ID, "bob", "advertising")
+	rudeReport, _ := store.ReportComment(rude.ID, "alice", "abuse")
+
+	queue, err := store.GetReports(2, "", model.ReportStatusOpen)
+	if err != nil {
+		t.Fatalf("error was not expected while getting reports: %s", err)
+	}
+	if queue.TotalCount != 3 || len(queue.Edges) != 2 || queue.Edges[0].Node.ID != first.ID || !queue.PageInfo.HasNextPage {
+		t.Errorf("unexpected first page: total %d, %+v", queue.TotalCount, queue.Edges)
+	}
+
+	// Решение по жалобе закрывает все открытые жалобы на комментарий
+	report, err := store.ResolveReport(first.ID, model.ReportActionHideComment, "moderator")
+	if err != nil {
+		t.Fatalf("error was not expected while resolving report: %s", err)
+	}
+	if report.Status != model.ReportStatusResolved || *report.Action != model.ReportActionHideComment || *report.ResolvedBy != "moderator" {
+		t.Errorf("unexpected report: %+v", report)
+	}
+	if queue, _ := store.GetReports(10, "", model.ReportStatusOpen); queue.TotalCount != 1 || queue.Edges[0].Node.CommentID != rude.ID {
+		t.Errorf("expected only the report on the rude comment, got %+v", queue.Edges)
+	}
+	if _, err := store.ResolveReport(first.ID, model.ReportActionDismiss, "moderator"); !errors.Is(err, ErrInvalidArgument) {
+		t.Errorf("expected %v for a resolved report, got %v", ErrInvalidArgument, err)
+	}
+
+	if pages, _ := store.GetComments([]int{post.ID}, 10, "", model.CommentSortOld, false); pages[post.ID].TotalCount != 1 {
+		t.Errorf("hidden comment should be excluded, got %+v", pages[post.ID].Edges)
+	}
+	if pages, _ := store.GetComments([]int{post.ID}, 10, "", model.CommentSortOld, true); pages[post.ID].TotalCount != 2 || !pages[post.ID].Edges[0].Node.IsHidden {
+		t.Errorf("hidden comment should be included, got %+v", pages[post.ID].Edges)
+	}
+	// Ответ скрытого комментария тоже не находится
+	if found, _ := store.SearchComments(nil, "buy", 10, ""); found.TotalCount != 0 {
+		t.Errorf("hidden comment and its replies should not be found, got %d", found.TotalCount)
+	}
+
+	if _, err := store.ResolveReport(rudeReport.ID, model.ReportActionLockPost, "moderator"); err != nil {
+		t.Fatalf("error was not expected while resolving report: %s", err)
+	}
+	if post, _ := store.GetPost(post.ID); post.CommentsEnabled {
+		t.Error("comments should be locked")
+	}
+
+	if _, err := store.ResolveReport(42, model.ReportActionDismiss, "moderator"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected %v, got %v", ErrNotFound, err)
+	}
+}
+
+func TestCommentSortsMemory(t *testing.T) {
+	store := NewMemoryStore()
+	post, _ := store.CreatePost("Title", "Content", "Author", nil, model.PostStatusPublished, nil)
+
+	// votes[i] - голоса за и против i-го комментария
+	votes := [][2]int{{1, 0}, {10, 1}, {5, 5}, {0, 3}}
+	for i, v := range votes {
+		comment, _ := store.CreateComment(post.ID, "Author", "Content", nil)
+		for j := 0; j < v[0]; j++ {
+			store.Vote(model.VoteTargetComment, comment.ID, fmt.Sprintf("up-%d-%d", i, j), 1)
+		}
+		for j := 0; j < v[1]; j++ {
+			store.Vote(model.VoteTargetComment, comment.ID, fmt.Sprintf("down-%d-%d", i, j), -1)
+		}
+	}
+
+	tests := []struct {
+		sort model.CommentSort
+		want []int
+	}{
+		{model.CommentSortOld, []int{1, 2, 3, 4}},
+		{model.CommentSortNew, []int{4, 3, 2, 1}},
+		{model.CommentSortTop, []int{2, 1, 3, 4}},
+		{model.CommentSortBest, []int{2, 3, 1, 4}},
+		{model.CommentSortControversial, []int{3, 2, 1, 4}},
 	}
 
-	updatedPost, _ := store.GetPost(post.ID, 0, 10)
-	if updatedPost.CommentsEnabled {
-		t.Errorf("comments should be disabled for the post")
+	for _, tt := range tests {
+		// Читаем по два комментария, чтобы проверить и курсор
+		var got []int
+		after := ""
+		for {
+			pages, err := store.GetComments([]int{post.ID}, 2, after, tt.sort, false)
+			if err != nil {
+				t.Fatalf("%s: error was not expected: %s", tt.sort, err)
+			}
+			page := pages[post.ID]
+			for _, edge := range page.Edges {
+				got = append(got, edge.Node.ID)
+			}
+			if !page.PageInfo.HasNextPage {
+				break
+			}
+			after = *page.PageInfo.EndCursor
+		}
+
+		if fmt.Sprint(got) != fmt.Sprint(tt.want) {
+			t.Errorf("%s: expected %v, got %v", tt.sort, tt.want, got)
+		}
 	}
 }
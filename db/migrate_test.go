@@ -0,0 +1,138 @@
+package db
+
+import (
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+func TestLoadMigrations(t *testing.T) {
+	migrations, err := loadMigrations()
+	if err != nil {
+		t.Fatalf("error was not expected while loading migrations: %s", err)
+	}
+
+	if len(migrations) == 0 {
+		t.Fatal("expected at least one migration")
+	}
+
+	if migrations[0].Version != 1 || migrations[0].Name != "init" {
+		t.Errorf("unexpected first migration: %04d_%s", migrations[0].Version, migrations[0].Name)
+	}
+
+	if !strings.Contains(migrations[0].Up, "CREATE TABLE IF NOT EXISTS comments") {
+		t.Errorf("init migration should create the comments table")
+	}
+
+	for i := 1; i < len(migrations); i++ {
+		if migrations[i].Version <= migrations[i-1].Version {
+			t.Errorf("migrations are not ordered: %d after %d", migrations[i].Version, migrations[i-1].Version)
+		}
+	}
+
+	// Базе, созданной до миграций, внешние ключи добавляются отдельной миграцией
+	var foreignKeys *Migration
+	for i := range migrations {
+		if migrations[i].Name == "comment_foreign_keys" {
+			foreignKeys = &migrations[i]
+		}
+	}
+	if foreignKeys == nil {
+		t.Fatal("expected the comment_foreign_keys migration")
+	}
+	for _, constraint := range []string{"comments_post_id_fkey", "comments_parent_id_fkey"} {
+		if !strings.Contains(foreignKeys.Up, "ADD CONSTRAINT "+constraint) || !strings.Contains(foreignKeys.Down, "DROP CONSTRAINT IF EXISTS "+constraint) {
+			t.Errorf("comment_foreign_keys migration should add and drop %s", constraint)
+		}
+	}
+	if strings.Contains(foreignKeys.Up, "DELETE FROM") {
+		t.Error("comment_foreign_keys migration should not delete rows")
+	}
+}
+
+func expectMigrationLock(mock sqlmock.Sqlmock) {
+	mock.ExpectExec("SELECT pg_advisory_lock\\(\\$1\\)").WithArgs(migrationLockKey).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec("CREATE TABLE IF NOT EXISTS schema_migrations").WillReturnResult(sqlmock.NewResult(0, 0))
+}
+
+func TestMigrateUp(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	migrations, err := loadMigrations()
+	if err != nil {
+		t.Fatalf("error was not expected while loading migrations: %s", err)
+	}
+
+	expectMigrationLock(mock)
+	// Первая миграция уже применена
+	mock.ExpectQuery("SELECT version, applied_at FROM schema_migrations").
+		WillReturnRows(sqlmock.NewRows([]string{"version", "applied_at"}).AddRow(1, time.Now()))
+
+	for _, m := range migrations[1:] {
+		mock.ExpectBegin()
+		mock.ExpectExec(regexp.QuoteMeta(m.Up)).WillReturnResult(sqlmock.NewResult(0, 0))
+		mock.ExpectExec("INSERT INTO schema_migrations").WithArgs(m.Version, m.Name).WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectCommit()
+	}
+	mock.ExpectExec("SELECT pg_advisory_unlock\\(\\$1\\)").WithArgs(migrationLockKey).WillReturnResult(sqlmock.NewResult(0, 0))
+
+	applied, err := MigrateUp(db)
+	if err != nil {
+		t.Errorf("error was not expected while migrating: %s", err)
+	}
+
+	if len(applied) != len(migrations)-1 {
+		t.Errorf("expected %d applied migrations, got %d", len(migrations)-1, len(applied))
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestMigrateDown(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	migrations, err := loadMigrations()
+	if err != nil {
+		t.Fatalf("error was not expected while loading migrations: %s", err)
+	}
+	last := migrations[len(migrations)-1]
+
+	rows := sqlmock.NewRows([]string{"version", "applied_at"})
+	for _, m := range migrations {
+		rows.AddRow(m.Version, time.Now())
+	}
+
+	expectMigrationLock(mock)
+	mock.ExpectQuery("SELECT version, applied_at FROM schema_migrations").WillReturnRows(rows)
+	mock.ExpectBegin()
+	mock.ExpectExec(regexp.QuoteMeta(last.Down)).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec("DELETE FROM schema_migrations WHERE version = \\$1").WithArgs(last.Version).WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectCommit()
+	mock.ExpectExec("SELECT pg_advisory_unlock\\(\\$1\\)").WithArgs(migrationLockKey).WillReturnResult(sqlmock.NewResult(0, 0))
+
+	rolledBack, err := MigrateDown(db)
+	if err != nil {
+		t.Errorf("error was not expected while rolling back: %s", err)
+	}
+
+	if rolledBack == nil || rolledBack.Version != last.Version {
+		t.Errorf("expected migration %d to be rolled back, got %+v", last.Version, rolledBack)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
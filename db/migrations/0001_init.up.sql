@@ -0,0 +1,19 @@
+CREATE TABLE IF NOT EXISTS posts (
+    id               SERIAL PRIMARY KEY,
+    title            TEXT    NOT NULL,
+    content          TEXT    NOT NULL,
+    author           TEXT    NOT NULL,
+    comments_enabled BOOLEAN NOT NULL DEFAULT TRUE
+);
+
+CREATE TABLE IF NOT EXISTS comments (
+    id        SERIAL PRIMARY KEY,
+    post_id   INTEGER NOT NULL REFERENCES posts (id) ON DELETE CASCADE,
+    parent_id INTEGER REFERENCES comments (id) ON DELETE CASCADE,
+    author    TEXT    NOT NULL,
+    content   TEXT    NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS posts_author_idx ON posts (author);
+CREATE INDEX IF NOT EXISTS comments_post_id_idx ON comments (post_id, id) WHERE parent_id IS NULL;
+CREATE INDEX IF NOT EXISTS comments_parent_id_idx ON comments (parent_id, id);
@@ -0,0 +1,6 @@
+DROP INDEX IF EXISTS posts_auto_lock_idx;
+
+ALTER TABLE posts
+    DROP COLUMN IF EXISTS comments_lock_reason,
+    DROP COLUMN IF EXISTS auto_lock_after_days,
+    DROP COLUMN IF EXISTS created_at;
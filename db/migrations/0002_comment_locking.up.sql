@@ -0,0 +1,9 @@
+ALTER TABLE posts
+    ADD COLUMN created_at           TIMESTAMPTZ NOT NULL DEFAULT now(),
+    ADD COLUMN auto_lock_after_days INTEGER CHECK (auto_lock_after_days > 0),
+    ADD COLUMN comments_lock_reason TEXT;
+
+UPDATE posts SET comments_lock_reason = 'MANUAL' WHERE NOT comments_enabled;
+
+CREATE INDEX IF NOT EXISTS posts_auto_lock_idx ON posts (created_at)
+    WHERE comments_enabled AND auto_lock_after_days IS NOT NULL;
@@ -0,0 +1,5 @@
+ALTER TABLE comments
+    DROP COLUMN IF EXISTS is_pruned,
+    DROP COLUMN IF EXISTS is_deleted;
+
+ALTER TABLE posts DROP COLUMN IF EXISTS is_deleted;
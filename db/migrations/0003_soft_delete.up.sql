@@ -0,0 +1,6 @@
+ALTER TABLE posts ADD COLUMN is_deleted BOOLEAN NOT NULL DEFAULT FALSE;
+
+-- is_pruned: удалённый комментарий без видимых ответов, в списки не попадает
+ALTER TABLE comments
+    ADD COLUMN is_deleted BOOLEAN NOT NULL DEFAULT FALSE,
+    ADD COLUMN is_pruned  BOOLEAN NOT NULL DEFAULT FALSE;
@@ -0,0 +1,22 @@
+ALTER TABLE posts ADD COLUMN edited_at TIMESTAMPTZ;
+ALTER TABLE comments ADD COLUMN edited_at TIMESTAMPTZ;
+
+-- Текст поста или комментария до правки number
+CREATE TABLE IF NOT EXISTS post_revisions (
+    post_id   INTEGER     NOT NULL REFERENCES posts (id) ON DELETE CASCADE,
+    number    INTEGER     NOT NULL,
+    title     TEXT        NOT NULL,
+    content   TEXT        NOT NULL,
+    editor    TEXT        NOT NULL,
+    edited_at TIMESTAMPTZ NOT NULL,
+    PRIMARY KEY (post_id, number)
+);
+
+CREATE TABLE IF NOT EXISTS comment_revisions (
+    comment_id INTEGER     NOT NULL REFERENCES comments (id) ON DELETE CASCADE,
+    number     INTEGER     NOT NULL,
+    content    TEXT        NOT NULL,
+    editor     TEXT        NOT NULL,
+    edited_at  TIMESTAMPTZ NOT NULL,
+    PRIMARY KEY (comment_id, number)
+);
@@ -0,0 +1,14 @@
+ALTER TABLE posts ADD COLUMN updated_at TIMESTAMPTZ;
+UPDATE posts SET updated_at = COALESCE(edited_at, created_at);
+ALTER TABLE posts
+    ALTER COLUMN updated_at SET NOT NULL,
+    ALTER COLUMN updated_at SET DEFAULT now();
+
+-- Время создания существующих комментариев неизвестно, им достаётся время миграции
+ALTER TABLE comments
+    ADD COLUMN created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    ADD COLUMN updated_at TIMESTAMPTZ;
+UPDATE comments SET updated_at = COALESCE(edited_at, created_at);
+ALTER TABLE comments
+    ALTER COLUMN updated_at SET NOT NULL,
+    ALTER COLUMN updated_at SET DEFAULT now();
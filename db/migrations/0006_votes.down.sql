@@ -0,0 +1,12 @@
+DROP TABLE IF EXISTS comment_votes;
+DROP TABLE IF EXISTS post_votes;
+
+ALTER TABLE comments
+    DROP COLUMN IF EXISTS controversy,
+    DROP COLUMN IF EXISTS best,
+    DROP COLUMN IF EXISTS downvotes,
+    DROP COLUMN IF EXISTS upvotes;
+
+ALTER TABLE posts
+    DROP COLUMN IF EXISTS downvotes,
+    DROP COLUMN IF EXISTS upvotes;
@@ -0,0 +1,25 @@
+-- Счётчики голосов хранятся рядом с целью, чтобы не пересчитывать голоса при чтении.
+-- best и controversy - ключи сортировок BEST и CONTROVERSIAL, их считает сервис.
+ALTER TABLE posts
+    ADD COLUMN upvotes   INTEGER NOT NULL DEFAULT 0,
+    ADD COLUMN downvotes INTEGER NOT NULL DEFAULT 0;
+
+ALTER TABLE comments
+    ADD COLUMN upvotes     INTEGER          NOT NULL DEFAULT 0,
+    ADD COLUMN downvotes   INTEGER          NOT NULL DEFAULT 0,
+    ADD COLUMN best        DOUBLE PRECISION NOT NULL DEFAULT 0,
+    ADD COLUMN controversy DOUBLE PRECISION NOT NULL DEFAULT 0;
+
+CREATE TABLE IF NOT EXISTS post_votes (
+    post_id INTEGER  NOT NULL REFERENCES posts (id) ON DELETE CASCADE,
+    user_id TEXT     NOT NULL,
+    value   SMALLINT NOT NULL CHECK (value IN (-1, 1)),
+    PRIMARY KEY (post_id, user_id)
+);
+
+CREATE TABLE IF NOT EXISTS comment_votes (
+    comment_id INTEGER  NOT NULL REFERENCES comments (id) ON DELETE CASCADE,
+    user_id    TEXT     NOT NULL,
+    value      SMALLINT NOT NULL CHECK (value IN (-1, 1)),
+    PRIMARY KEY (comment_id, user_id)
+);
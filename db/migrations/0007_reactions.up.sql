@@ -0,0 +1,9 @@
+-- Каждый пользователь может поставить комментарию одну реакцию каждого вида.
+-- created_at задаёт порядок реакций: первой показывается та, что появилась раньше.
+CREATE TABLE IF NOT EXISTS comment_reactions (
+    comment_id INTEGER     NOT NULL REFERENCES comments (id) ON DELETE CASCADE,
+    user_id    TEXT        NOT NULL,
+    emoji      TEXT        NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    PRIMARY KEY (comment_id, user_id, emoji)
+);
@@ -0,0 +1,17 @@
+-- Поисковые векторы строятся по русской и английской конфигурациям, чтобы
+-- слова обоих языков приводились к основе. Заголовок поста весит больше
+-- текста: вес A против B.
+ALTER TABLE posts ADD COLUMN search tsvector GENERATED ALWAYS AS (
+    setweight(to_tsvector('russian', title), 'A') ||
+    setweight(to_tsvector('english', title), 'A') ||
+    setweight(to_tsvector('russian', content), 'B') ||
+    setweight(to_tsvector('english', content), 'B')
+) STORED;
+
+ALTER TABLE comments ADD COLUMN search tsvector GENERATED ALWAYS AS (
+    setweight(to_tsvector('russian', content), 'B') ||
+    setweight(to_tsvector('english', content), 'B')
+) STORED;
+
+CREATE INDEX IF NOT EXISTS posts_search_idx ON posts USING GIN (search);
+CREATE INDEX IF NOT EXISTS comments_search_idx ON comments USING GIN (search);
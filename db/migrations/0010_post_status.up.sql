@@ -0,0 +1,9 @@
+-- Существующие посты считаются опубликованными в момент создания
+ALTER TABLE posts
+    ADD COLUMN status     TEXT NOT NULL DEFAULT 'PUBLISHED' CHECK (status IN ('DRAFT', 'SCHEDULED', 'PUBLISHED')),
+    ADD COLUMN publish_at TIMESTAMPTZ;
+
+UPDATE posts SET publish_at = created_at;
+
+-- Фоновая публикация ищет отложенные посты по времени публикации
+CREATE INDEX IF NOT EXISTS posts_scheduled_idx ON posts (publish_at) WHERE status = 'SCHEDULED';
@@ -0,0 +1,19 @@
+-- Скрытые модератором комментарии видят только модераторы и администраторы
+ALTER TABLE comments ADD COLUMN is_hidden BOOLEAN NOT NULL DEFAULT FALSE;
+
+-- Жалобы пользователей на комментарии. На один комментарий пользователь жалуется один раз.
+CREATE TABLE IF NOT EXISTS comment_reports (
+    id          SERIAL PRIMARY KEY,
+    comment_id  INTEGER     NOT NULL REFERENCES comments (id) ON DELETE CASCADE,
+    reporter    TEXT        NOT NULL,
+    reason      TEXT        NOT NULL,
+    status      TEXT        NOT NULL DEFAULT 'OPEN' CHECK (status IN ('OPEN', 'DISMISSED', 'RESOLVED')),
+    action      TEXT        CHECK (action IN ('DISMISS', 'HIDE_COMMENT', 'DELETE_COMMENT', 'LOCK_POST')),
+    resolved_by TEXT,
+    resolved_at TIMESTAMPTZ,
+    created_at  TIMESTAMPTZ NOT NULL DEFAULT now(),
+    UNIQUE (comment_id, reporter)
+);
+
+-- Очередь модерации выбирает жалобы по статусу в порядке поступления
+CREATE INDEX IF NOT EXISTS comment_reports_status_idx ON comment_reports (status, id);
@@ -0,0 +1,6 @@
+-- Тексты запросов APQ по sha256-хешу, чтобы хеши переживали перезапуск сервиса
+CREATE TABLE IF NOT EXISTS persisted_queries (
+    hash       TEXT PRIMARY KEY,
+    query      TEXT        NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS posts_auto_lock_idx;
+CREATE INDEX IF NOT EXISTS posts_auto_lock_idx ON posts (created_at)
+    WHERE comments_enabled AND auto_lock_after_days IS NOT NULL;
@@ -0,0 +1,5 @@
+-- Срок автоматического закрытия комментариев отсчитывается от publish_at,
+-- поэтому индекс строится по нему и с тем же условием, что и в LockExpiredPosts
+DROP INDEX IF EXISTS posts_auto_lock_idx;
+CREATE INDEX IF NOT EXISTS posts_auto_lock_idx ON posts (publish_at)
+    WHERE comments_enabled AND NOT is_deleted AND status = 'PUBLISHED' AND auto_lock_after_days IS NOT NULL;
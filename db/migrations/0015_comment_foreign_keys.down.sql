@@ -0,0 +1,3 @@
+ALTER TABLE comments
+    DROP CONSTRAINT IF EXISTS comments_parent_id_fkey,
+    DROP CONSTRAINT IF EXISTS comments_post_id_fkey;
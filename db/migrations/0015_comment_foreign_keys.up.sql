@@ -0,0 +1,36 @@
+-- 0001_init создаёт таблицы через IF NOT EXISTS, поэтому у базы, созданной до
+-- миграций, внешних ключей comments может не быть. Добавляем их, если их нет.
+-- Строки, которые нарушают ключ, миграция не удаляет: она останавливается и
+-- сообщает их число, чтобы их разобрали вручную.
+DO $$
+DECLARE
+    orphans BIGINT;
+BEGIN
+    IF NOT EXISTS (
+        SELECT 1 FROM pg_constraint c
+        JOIN pg_attribute a ON a.attrelid = c.conrelid AND a.attnum = ANY (c.conkey)
+        WHERE c.conrelid = 'comments'::regclass AND c.contype = 'f' AND a.attname = 'post_id'
+    ) THEN
+        SELECT COUNT(*) INTO orphans FROM comments c WHERE NOT EXISTS (SELECT 1 FROM posts p WHERE p.id = c.post_id);
+        IF orphans > 0 THEN
+            RAISE EXCEPTION '% comments reference missing posts, remove them before adding comments_post_id_fkey', orphans;
+        END IF;
+        ALTER TABLE comments ADD CONSTRAINT comments_post_id_fkey
+            FOREIGN KEY (post_id) REFERENCES posts (id) ON DELETE CASCADE;
+    END IF;
+
+    IF NOT EXISTS (
+        SELECT 1 FROM pg_constraint c
+        JOIN pg_attribute a ON a.attrelid = c.conrelid AND a.attnum = ANY (c.conkey)
+        WHERE c.conrelid = 'comments'::regclass AND c.contype = 'f' AND a.attname = 'parent_id'
+    ) THEN
+        SELECT COUNT(*) INTO orphans FROM comments c
+        WHERE c.parent_id IS NOT NULL AND NOT EXISTS (SELECT 1 FROM comments p WHERE p.id = c.parent_id);
+        IF orphans > 0 THEN
+            RAISE EXCEPTION '% comments reply to missing comments, remove them before adding comments_parent_id_fkey', orphans;
+        END IF;
+        ALTER TABLE comments ADD CONSTRAINT comments_parent_id_fkey
+            FOREIGN KEY (parent_id) REFERENCES comments (id) ON DELETE CASCADE;
+    END IF;
+END
+$$;
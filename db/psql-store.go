@@ -3,128 +3,326 @@ package db
 import (
 	"PostCommentService/graph/model"
 	"database/sql"
+	"encoding/json"
 	"errors"
+	"fmt"
+	"time"
+
+	"github.com/lib/pq"
 )
 
+// Канал PostgreSQL, в который отправляется уведомление о каждом новом комментарии
+const CommentAddedChannel = "comment_added"
+
+// CommentEvent - содержимое уведомления в канале CommentAddedChannel
+type CommentEvent struct {
+	ID     int `json:"id"`
+	PostID int `json:"postId"`
+}
+
 type PostgresStore struct {
-	db *sql.DB
+	db  *sql.DB
+	now func() time.Time
 }
 
 func NewPostgresStore(db *sql.DB) *PostgresStore {
 	return &PostgresStore{
-		db: db,
+		db:  db,
+		now: time.Now,
 	}
 }
 
-func (s *PostgresStore) GetPosts() ([]*model.Post, error) {
-	rows, err := s.db.Query("SELECT id, title, content, comments_enabled, author FROM posts")
+func (s *PostgresStore) GetPosts(first int, after string, sort model.PostSort, filter *model.PostFilter, viewer string) (*model.PostConnection, error) {
+	first, err := pageSize(first)
+	if err != nil {
+		return nil, err
+	}
+
+	afterID, err := decodeCursor(after)
+	if err != nil {
+		return nil, err
+	}
+
+	where, args := postFilterClause(filter, viewer)
+
+	var total int
+	if err := s.db.QueryRow("SELECT COUNT(*) FROM posts"+where, args...).Scan(&total); err != nil {
+		return nil, err
+	}
+
+	order, cmp := "ASC", ">"
+	if sort == model.PostSortNewest {
+		order, cmp = "DESC", "<"
+	}
+
+	if afterID != 0 {
+		args = append(args, afterID)
+		where = appendCondition(where, fmt.Sprintf("id %s $%d", cmp, len(args)))
+	}
+
+	// Запрашиваем на один пост больше, чтобы узнать, есть ли следующая страница
+	args = append(args, first+1)
+	query := fmt.Sprintf("SELECT %s FROM posts%s ORDER BY id %s LIMIT $%d", postColumns, where, order, len(args))
+
+	rows, err := s.db.Query(query, args...)
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
 
-	var posts []*model.Post
+	posts := []*model.Post{}
 	for rows.Next() {
-		var p model.Post
-		if err := rows.Scan(&p.ID, &p.Title, &p.Content, &p.CommentsEnabled, &p.Author); err != nil {
+		p, err := scanPost(rows)
+		if err != nil {
 			return nil, err
 		}
-		posts = append(posts, &p)
+		posts = append(posts, p)
 	}
 
 	if err := rows.Err(); err != nil {
 		return nil, err
 	}
 
-	return posts, nil
+	hasNextPage := len(posts) > first
+	if hasNextPage {
+		posts = posts[:first]
+	}
+
+	return newPostConnection(posts, total, hasNextPage), nil
+}
+
+// postFilterClause возвращает условие выборки постов, видимых viewer и подходящих под filter
+func postFilterClause(filter *model.PostFilter, viewer string) (string, []interface{}) {
+	where := " WHERE NOT is_deleted AND (status = 'PUBLISHED' OR author = $1 AND $1 <> '')"
+	args := []interface{}{viewer}
+	if filter == nil {
+		return where, args
+	}
+
+	if filter.Author != nil {
+		args = append(args, *filter.Author)
+		where = appendCondition(where, fmt.Sprintf("author = $%d", len(args)))
+	}
+	if filter.CommentsEnabled != nil {
+		args = append(args, *filter.CommentsEnabled)
+		where = appendCondition(where, fmt.Sprintf("comments_enabled = $%d", len(args)))
+	}
+	if tags := tagSet(filter.Tags); len(tags) > 0 {
+		// Пост должен быть отмечен всеми тегами фильтра
+		args = append(args, pq.StringArray(tags), len(tags))
+		where = appendCondition(where, fmt.Sprintf(`id IN (SELECT pt.post_id FROM post_tags pt JOIN tags t ON t.id = pt.tag_id
+			WHERE t.name = ANY($%d) GROUP BY pt.post_id HAVING COUNT(*) = $%d)`, len(args)-1, len(args)))
+	}
+
+	return where, args
 }
 
-func (s *PostgresStore) GetPost(id, offset, limit int) (*model.Post, error) {
-	row := s.db.QueryRow("SELECT id, title, content, comments_enabled, author FROM posts WHERE id = $1", id)
+func appendCondition(where, condition string) string {
+	if where == "" {
+		return " WHERE " + condition
+	}
+	return where + " AND " + condition
+}
 
+const postColumns = "id, title, content, comments_enabled, author, comments_lock_reason, auto_lock_after_days, edited_at, created_at, updated_at, upvotes, downvotes, status, publish_at"
+
+// scanPost читает колонки postColumns, extra - назначения для следующих за ними колонок
+func scanPost(row rowScanner, extra ...interface{}) (*model.Post, error) {
 	var p model.Post
-	if err := row.Scan(&p.ID, &p.Title, &p.Content, &p.CommentsEnabled, &p.Author); err != nil {
+	var reason sql.NullString
+	dest := []interface{}{&p.ID, &p.Title, &p.Content, &p.CommentsEnabled, &p.Author, &reason, &p.AutoLockAfterDays, &p.EditedAt, &p.CreatedAt, &p.UpdatedAt, &p.Upvotes, &p.Downvotes, &p.Status, &p.PublishAt}
+	if err := row.Scan(append(dest, extra...)...); err != nil {
+		return nil, err
+	}
+	p.Score = p.Upvotes - p.Downvotes
+	if reason.Valid {
+		r := model.CommentLockReason(reason.String)
+		p.CommentsLockReason = &r
+	}
+	return &p, nil
+}
+
+// queryPost выполняет запрос, возвращающий одну строку с колонками postColumns
+func (s *PostgresStore) queryPost(id int, query string, args ...interface{}) (*model.Post, error) {
+	p, err := scanPost(s.db.QueryRow(query, args...))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, postNotFound(id)
+	}
+	return p, err
+}
+
+func (s *PostgresStore) GetPost(id int) (*model.Post, error) {
+	return s.queryPost(id, "SELECT "+postColumns+" FROM posts WHERE id = $1 AND NOT is_deleted", id)
+}
+
+// GetComments возвращает страницу комментариев верхнего уровня для каждого из постов postIDs
+func (s *PostgresStore) GetComments(postIDs []int, first int, after string, sort model.CommentSort, withHidden bool) (map[int]*model.CommentConnection, error) {
+	return s.commentPages("post_id", "parent_id IS NULL", postIDs, first, after, sort, withHidden, func(c *model.Comment) int {
+		return c.PostID
+	})
+}
+
+// GetReplies возвращает страницу прямых ответов для каждого из комментариев parentIDs
+func (s *PostgresStore) GetReplies(parentIDs []int, first int, after string, sort model.CommentSort, withHidden bool) (map[int]*model.CommentConnection, error) {
+	return s.commentPages("parent_id", "", parentIDs, first, after, sort, withHidden, func(c *model.Comment) int {
+		return *c.ParentID
+	})
+}
+
+// commentPages загружает страницы комментариев сразу для нескольких групп
+// (постов или родительских комментариев) двумя запросами. Страница
+// отсчитывается отдельно для каждой группы.
+func (s *PostgresStore) commentPages(groupColumn, condition string, ids []int, first int, after string, sort model.CommentSort, withHidden bool, groupOf func(*model.Comment) int) (map[int]*model.CommentConnection, error) {
+	first, err := pageSize(first)
+	if err != nil {
 		return nil, err
 	}
-	if offset == 0 && limit == 0 {
-		return &p, nil
+
+	order, err := orderFor(sort)
+	if err != nil {
+		return nil, err
 	}
 
-	comments, err := s.GetComments(p.ID, offset, limit)
+	pos, err := order.decodeCursor(after)
 	if err != nil {
 		return nil, err
 	}
 
-	p.Comments = comments
+	groupIDs := make(pq.Int64Array, len(ids))
+	for i, id := range ids {
+		groupIDs[i] = int64(id)
+	}
 
-	return &p, nil
-}
+	// Удалённые комментарии без ответов в списки не попадают
+	where := groupColumn + " = ANY($1) AND NOT is_pruned"
+	if condition != "" {
+		where += " AND " + condition
+	}
+	if !withHidden {
+		where += " AND NOT is_hidden"
+	}
+
+	afterCondition, args := order.afterCondition(pos, 2)
+	args = append([]interface{}{groupIDs}, args...)
 
-func (s *PostgresStore) GetComments(postID, offset, limit int) ([]*model.Comment, error) {
-	rows, err := s.db.Query("SELECT id, post_id, content, author, parent_id FROM comments WHERE post_id = $1 ORDER BY id ASC ", postID)
+	type counts struct{ total, remaining int }
+	stats := make(map[int]counts, len(ids))
+
+	rows, err := s.db.Query(fmt.Sprintf("SELECT %[1]s, COUNT(*), COUNT(*) FILTER (WHERE %[3]s) FROM comments WHERE %[2]s GROUP BY %[1]s", groupColumn, where, afterCondition), args...)
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
 
-	commentMap := make(map[int]*model.Comment)
-
 	for rows.Next() {
-		var c model.Comment
-		if err := rows.Scan(&c.ID, &c.PostID, &c.Author, &c.Content, &c.ParentID); err != nil {
+		var id int
+		var c counts
+		if err := rows.Scan(&id, &c.total, &c.remaining); err != nil {
 			return nil, err
 		}
-		commentMap[c.ID] = &c
+		stats[id] = c
 	}
 
 	if err := rows.Err(); err != nil {
 		return nil, err
 	}
 
-	return processComments(commentMap, offset, limit), nil
+	args = append(args, first)
+	rows, err = s.db.Query(fmt.Sprintf(`SELECT `+commentColumns+` FROM (
+			SELECT *, ROW_NUMBER() OVER (PARTITION BY %[1]s ORDER BY %[4]s) AS rn
+			FROM comments WHERE %[2]s AND %[3]s
+		) c WHERE c.rn <= $%[5]d ORDER BY c.%[1]s, c.rn`, groupColumn, where, afterCondition, order.orderBy(), len(args)), args...)
+	if err != nil {
+		return nil, err
+	}
+
+	comments, err := scanComments(rows)
+	if err != nil {
+		return nil, err
+	}
+
+	grouped := make(map[int][]*model.Comment, len(ids))
+	for _, comment := range comments {
+		id := groupOf(comment)
+		grouped[id] = append(grouped[id], comment)
+	}
+
+	result := make(map[int]*model.CommentConnection, len(ids))
+	for _, id := range ids {
+		page := grouped[id]
+		result[id] = newCommentConnection(page, stats[id].total, stats[id].remaining > len(page), order)
+	}
+
+	return result, nil
 }
 
-func processComments(commentMap map[int]*model.Comment, offset, limit int) []*model.Comment {
-	var topLevelComments []*model.Comment
+// Колонки комментария вместе с числом прямых ответов на него и числом скрытых из них
+const commentColumns = "c.id, c.post_id, c.author, c.content, c.parent_id, c.is_deleted, (SELECT COUNT(*) FROM comments r WHERE r.parent_id = c.id AND NOT r.is_pruned), c.edited_at, c.created_at, c.updated_at, c.upvotes, c.downvotes, c.is_hidden, (SELECT COUNT(*) FROM comments r WHERE r.parent_id = c.id AND NOT r.is_pruned AND r.is_hidden)"
 
-	for _, comment := range commentMap {
-		if comment.ParentID != nil {
-			parentComment, ok := commentMap[*comment.ParentID]
-			if ok {
-				parentComment.Child = append(parentComment.Child, comment)
-			}
-		} else {
-			topLevelComments = append(topLevelComments, comment)
-		}
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+// scanComment читает колонки commentColumns, extra - назначения для следующих за ними колонок
+func scanComment(row rowScanner, extra ...interface{}) (*model.Comment, error) {
+	var c model.Comment
+	dest := []interface{}{&c.ID, &c.PostID, &c.Author, &c.Content, &c.ParentID, &c.IsDeleted, &c.ReplyCount, &c.EditedAt, &c.CreatedAt, &c.UpdatedAt, &c.Upvotes, &c.Downvotes, &c.IsHidden, &c.HiddenReplyCount}
+	if err := row.Scan(append(dest, extra...)...); err != nil {
+		return nil, err
 	}
-	// Применяем пагинацию
-	if len(topLevelComments) <= offset {
-		return []*model.Comment{}
+	c.Score = c.Upvotes - c.Downvotes
+	return &c, nil
+}
+
+func scanComments(rows *sql.Rows) ([]*model.Comment, error) {
+	defer rows.Close()
+
+	comments := []*model.Comment{}
+	for rows.Next() {
+		c, err := scanComment(rows)
+		if err != nil {
+			return nil, err
+		}
+		comments = append(comments, c)
 	}
 
-	end := offset + limit
-	if end > len(topLevelComments) {
-		end = len(topLevelComments)
+	if err := rows.Err(); err != nil {
+		return nil, err
 	}
 
-	return topLevelComments[offset:end]
+	return comments, nil
 }
 
 func (s *PostgresStore) GetComment(id int) (*model.Comment, error) {
-	row := s.db.QueryRow("SELECT id, post_id, author, content, parent_id FROM comments WHERE id = $1", id)
+	// Комментарии удалённого поста недоступны, как и сам пост
+	c, err := scanComment(s.db.QueryRow("SELECT "+commentColumns+" FROM comments c JOIN posts p ON p.id = c.post_id WHERE c.id = $1 AND NOT c.is_pruned AND NOT p.is_deleted", id))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, commentNotFound(id)
+	}
+	return c, err
+}
 
-	var c model.Comment
-	if err := row.Scan(&c.ID, &c.PostID, &c.Author, &c.Content, &c.ParentID); err != nil {
+func (s *PostgresStore) CreatePost(title, content, author string, tags []string, status model.PostStatus, publishAt *time.Time) (*model.Post, error) {
+	tags, err := normalizeTags(tags)
+	if err != nil {
 		return nil, err
 	}
 
-	return &c, nil
-}
+	p := model.Post{CreatedAt: s.now(), Status: status}
+	p.UpdatedAt = p.CreatedAt
+	if p.PublishAt, err = publication(status, publishAt, p.CreatedAt); err != nil {
+		return nil, err
+	}
 
-func (s *PostgresStore) CreatePost(title, content, author string) (*model.Post, error) {
-	var p model.Post
-	err := s.db.QueryRow("INSERT INTO posts(title, content, author,comments_enabled) VALUES($1, $2, $3, $4) RETURNING id",
-		title, content, author, true).Scan(&p.ID)
+	err = s.inTx(func(tx *sql.Tx) error {
+		err := tx.QueryRow(`INSERT INTO posts(title, content, author, comments_enabled, created_at, updated_at, status, publish_at)
+			VALUES($1, $2, $3, $4, $5, $5, $6, $7) RETURNING id`,
+			title, content, author, true, p.CreatedAt, p.Status, p.PublishAt).Scan(&p.ID)
+		if err != nil {
+			return err
+		}
+		return addTags(tx, p.ID, tags)
+	})
 	if err != nil {
 		return nil, err
 	}
@@ -138,61 +336,1039 @@ func (s *PostgresStore) CreatePost(title, content, author string) (*model.Post,
 }
 
 func (s *PostgresStore) CreateComment(postID int, author, content string, parentID *int) (*model.Comment, error) {
-	if len(content) > 2000 {
-		return nil, errors.New("comment is too long")
+	c := model.Comment{
+		PostID:    postID,
+		Author:    author,
+		Content:   content,
+		ParentID:  parentID,
+		CreatedAt: s.now(),
+	}
+	c.UpdatedAt = c.CreatedAt
+
+	// Уведомление отправляется в той же транзакции, поэтому реплики получают
+	// его только после фиксации комментария, а ошибка уведомления отменяет вставку
+	err := s.inTx(func(tx *sql.Tx) error {
+		// Проверяем, опубликован ли пост и разрешены ли к нему комментарии
+		var commentsEnabled bool
+		var status model.PostStatus
+		var postAuthor string
+		err := tx.QueryRow("SELECT comments_enabled, status, author FROM posts WHERE id = $1 AND NOT is_deleted FOR SHARE", postID).Scan(&commentsEnabled, &status, &postAuthor)
+		if errors.Is(err, sql.ErrNoRows) {
+			return postNotFound(postID)
+		}
+		if err != nil {
+			return err
+		}
+
+		if err := checkPublished(status, postAuthor, author, postNotFound(postID)); err != nil {
+			return err
+		}
+		if !commentsEnabled {
+			return ErrCommentsDisabled
+		}
+
+		// Ответить можно только на существующий комментарий того же поста вне скрытой
+		// ветки: иначе ответ увидели бы подписчики commentAdded
+		if parentID != nil {
+			var parentPostID int
+			var parentDeleted, parentHidden bool
+			err := tx.QueryRow("SELECT post_id, is_deleted, is_hidden OR "+hiddenAncestor+" FROM comments WHERE id = $1", *parentID).
+				Scan(&parentPostID, &parentDeleted, &parentHidden)
+			if errors.Is(err, sql.ErrNoRows) || err == nil && (parentPostID != postID || parentDeleted || parentHidden) {
+				return ErrInvalidParent
+			}
+			if err != nil {
+				return err
+			}
+		}
+
+		err = tx.QueryRow("INSERT INTO comments(post_id, author, content, parent_id, created_at, updated_at) VALUES($1, $2, $3, $4, $5, $5) RETURNING id",
+			postID, author, content, parentID, c.CreatedAt).Scan(&c.ID)
+		if err != nil {
+			return err
+		}
+
+		// Уведомляем все реплики сервиса о новом комментарии
+		payload, err := json.Marshal(CommentEvent{ID: c.ID, PostID: c.PostID})
+		if err != nil {
+			return err
+		}
+		_, err = tx.Exec("SELECT pg_notify($1, $2)", CommentAddedChannel, string(payload))
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &c, nil
+}
+
+func (s *PostgresStore) GetCommentsAfter(id, limit int) ([]*model.Comment, error) {
+	rows, err := s.db.Query("SELECT "+commentColumns+" FROM comments c WHERE c.id > $1 ORDER BY c.id ASC LIMIT $2", id, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	return scanComments(rows)
+}
+
+func (s *PostgresStore) LastCommentID() (int, error) {
+	var id int
+	err := s.db.QueryRow("SELECT COALESCE(MAX(id), 0) FROM comments").Scan(&id)
+	return id, err
+}
+
+// GetPersistedQuery возвращает текст запроса APQ по его sha256-хешу
+func (s *PostgresStore) GetPersistedQuery(hash string) (string, error) {
+	var query string
+	err := s.db.QueryRow("SELECT query FROM persisted_queries WHERE hash = $1", hash).Scan(&query)
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", fmt.Errorf("persisted query %s: %w", hash, ErrNotFound)
 	}
+	return query, err
+}
 
-	// Проверяем, разрешены ли комментарии для этого поста
-	var commentsEnabled bool
-	err := s.db.QueryRow("SELECT comments_enabled FROM posts WHERE id = $1", postID).Scan(&commentsEnabled)
+// SavePersistedQuery сохраняет текст запроса APQ. Хеш уже проверен,
+// поэтому повторное сохранение того же хеша ничего не меняет.
+func (s *PostgresStore) SavePersistedQuery(hash, query string) error {
+	_, err := s.db.Exec("INSERT INTO persisted_queries (hash, query, created_at) VALUES ($1, $2, $3) ON CONFLICT (hash) DO NOTHING", hash, query, s.now())
+	return err
+}
+
+func (s *PostgresStore) UpdatePost(id int, title, content string, tags []string, editor string) (*model.Post, error) {
+	tags, err := normalizeTags(tags)
 	if err != nil {
-		if err == sql.ErrNoRows {
-			return nil, errors.New("post not found")
+		return nil, err
+	}
+
+	var post *model.Post
+	now := s.now()
+	err = s.inTx(func(tx *sql.Tx) error {
+		// Блокировка строки не даёт параллельным правкам получить один номер
+		var oldTitle, oldContent string
+		err := tx.QueryRow("SELECT title, content FROM posts WHERE id = $1 AND NOT is_deleted FOR UPDATE", id).Scan(&oldTitle, &oldContent)
+		if errors.Is(err, sql.ErrNoRows) {
+			return postNotFound(id)
+		}
+		if err != nil {
+			return err
+		}
+
+		_, err = tx.Exec(`INSERT INTO post_revisions (post_id, number, title, content, editor, edited_at)
+			SELECT $1, COUNT(*) + 1, $2, $3, $4, $5 FROM post_revisions WHERE post_id = $1`, id, oldTitle, oldContent, editor, now)
+		if err != nil {
+			return err
+		}
+
+		if tags != nil {
+			if _, err := tx.Exec("DELETE FROM post_tags WHERE post_id = $1", id); err != nil {
+				return err
+			}
+			if err := addTags(tx, id, tags); err != nil {
+				return err
+			}
 		}
+
+		post, err = scanPost(tx.QueryRow("UPDATE posts SET title = $2, content = $3, edited_at = $4, updated_at = $4 WHERE id = $1 RETURNING "+postColumns,
+			id, title, content, now))
+		return err
+	})
+	if err != nil {
 		return nil, err
 	}
 
-	if !commentsEnabled {
-		return nil, errors.New("comments are disabled for this post")
+	return post, nil
+}
+
+func (s *PostgresStore) UpdateComment(id int, content, editor string) (*model.Comment, error) {
+	now := s.now()
+	err := s.inTx(func(tx *sql.Tx) error {
+		var oldContent string
+		err := tx.QueryRow(`SELECT c.content FROM comments c JOIN posts p ON p.id = c.post_id
+			WHERE c.id = $1 AND NOT c.is_deleted AND NOT p.is_deleted FOR UPDATE OF c`, id).Scan(&oldContent)
+		if errors.Is(err, sql.ErrNoRows) {
+			return commentNotFound(id)
+		}
+		if err != nil {
+			return err
+		}
+
+		_, err = tx.Exec(`INSERT INTO comment_revisions (comment_id, number, content, editor, edited_at)
+			SELECT $1, COUNT(*) + 1, $2, $3, $4 FROM comment_revisions WHERE comment_id = $1`, id, oldContent, editor, now)
+		if err != nil {
+			return err
+		}
+
+		_, err = tx.Exec("UPDATE comments SET content = $2, edited_at = $3, updated_at = $3 WHERE id = $1", id, content, now)
+		return err
+	})
+	if err != nil {
+		return nil, err
 	}
 
-	var c model.Comment
-	err = s.db.QueryRow("INSERT INTO comments(post_id, author, content, parent_id) VALUES($1, $2, $3, $4) RETURNING id", postID, author, content, parentID).Scan(&c.ID)
+	return s.GetComment(id)
+}
+
+func (s *PostgresStore) SetCommentsEnabled(postID int, enabled bool) (*model.Post, error) {
+	return s.queryPost(postID, `UPDATE posts SET
+			comments_enabled = $2,
+			comments_lock_reason = CASE WHEN $2 THEN NULL ELSE 'MANUAL' END,
+			auto_lock_after_days = CASE WHEN $2 THEN NULL ELSE auto_lock_after_days END,
+			updated_at = $3
+		WHERE id = $1 AND NOT is_deleted RETURNING `+postColumns, postID, enabled, s.now())
+}
+
+func (s *PostgresStore) SetAutoLock(postID int, afterDays *int) (*model.Post, error) {
+	if err := validateAutoLock(afterDays); err != nil {
+		return nil, err
+	}
+
+	return s.queryPost(postID, "UPDATE posts SET auto_lock_after_days = $2, updated_at = $3 WHERE id = $1 AND NOT is_deleted RETURNING "+postColumns,
+		postID, afterDays, s.now())
+}
+
+func (s *PostgresStore) LockExpiredPosts(now time.Time) ([]int, error) {
+	rows, err := s.db.Query(`UPDATE posts SET comments_enabled = FALSE, comments_lock_reason = 'AUTO', updated_at = $1
+		WHERE comments_enabled AND NOT is_deleted AND status = 'PUBLISHED' AND auto_lock_after_days IS NOT NULL
+			AND publish_at + auto_lock_after_days * INTERVAL '1 day' <= $1
+		RETURNING id`, now)
 	if err != nil {
 		return nil, err
 	}
+	defer rows.Close()
+
+	locked := []int{}
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		locked = append(locked, id)
+	}
 
-	c.PostID = postID
-	c.Author = author
-	c.Content = content
-	c.ParentID = parentID
+	return locked, rows.Err()
+}
 
-	return &c, nil
+func (s *PostgresStore) PublishPost(id int, publishAt *time.Time) (*model.Post, error) {
+	var post *model.Post
+	now := s.now()
+	err := s.inTx(func(tx *sql.Tx) error {
+		var status model.PostStatus
+		err := tx.QueryRow("SELECT status FROM posts WHERE id = $1 AND NOT is_deleted FOR UPDATE", id).Scan(&status)
+		if errors.Is(err, sql.ErrNoRows) {
+			return postNotFound(id)
+		}
+		if err != nil {
+			return err
+		}
+		if status == model.PostStatusPublished {
+			return fmt.Errorf("%w: post %d is already published", ErrInvalidArgument, id)
+		}
+
+		status = model.PostStatusScheduled
+		if publishAt == nil || !publishAt.After(now) {
+			status, publishAt = model.PostStatusPublished, &now
+		}
+
+		post, err = scanPost(tx.QueryRow("UPDATE posts SET status = $2, publish_at = $3, updated_at = $4 WHERE id = $1 RETURNING "+postColumns,
+			id, status, *publishAt, now))
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return post, nil
 }
 
-func (s *PostgresStore) UpdatePost(id int, title, content string) (*model.Post, error) {
-	_, err := s.db.Exec("UPDATE posts SET title = $1, content = $2 WHERE id = $3", title, content, id)
+func (s *PostgresStore) PublishScheduledPosts(now time.Time) ([]int, error) {
+	rows, err := s.db.Query(`UPDATE posts SET status = 'PUBLISHED', updated_at = $1
+		WHERE status = 'SCHEDULED' AND NOT is_deleted AND publish_at <= $1
+		RETURNING id`, now)
 	if err != nil {
 		return nil, err
 	}
+	defer rows.Close()
+
+	published := []int{}
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		published = append(published, id)
+	}
+
+	return published, rows.Err()
+}
+
+func (s *PostgresStore) DeletePost(id int) error {
+	res, err := s.db.Exec("UPDATE posts SET is_deleted = TRUE WHERE id = $1 AND NOT is_deleted", id)
+	if err != nil {
+		return err
+	}
+
+	return expectAffected(res, postNotFound(id))
+}
+
+func (s *PostgresStore) DeleteComment(id int) error {
+	return s.inTx(func(tx *sql.Tx) error {
+		return deleteComment(tx, id, s.now())
+	})
+}
+
+func deleteComment(tx *sql.Tx, id int, now time.Time) error {
+	res, err := tx.Exec(`UPDATE comments SET is_deleted = TRUE, author = '', content = '', updated_at = $2
+		WHERE id = $1 AND NOT is_deleted AND post_id IN (SELECT id FROM posts WHERE NOT is_deleted)`, id, now)
+	if err != nil {
+		return err
+	}
+	if err := expectAffected(res, commentNotFound(id)); err != nil {
+		return err
+	}
+
+	// История правок хранит прежний текст, поэтому удаляется вместе с ним
+	if _, err := tx.Exec("DELETE FROM comment_revisions WHERE comment_id = $1", id); err != nil {
+		return err
+	}
+	if _, err := tx.Exec("DELETE FROM comment_reactions WHERE comment_id = $1", id); err != nil {
+		return err
+	}
+
+	return prune(tx, id)
+}
+
+func (s *PostgresStore) PurgePost(id int) error {
+	// Комментарии удаляются каскадно
+	res, err := s.db.Exec("DELETE FROM posts WHERE id = $1", id)
+	if err != nil {
+		return err
+	}
+
+	return expectAffected(res, postNotFound(id))
+}
+
+func (s *PostgresStore) PurgeComment(id int) error {
+	return s.inTx(func(tx *sql.Tx) error {
+		// Ответы удаляются каскадно
+		var parentID sql.NullInt64
+		err := tx.QueryRow("DELETE FROM comments WHERE id = $1 RETURNING parent_id", id).Scan(&parentID)
+		if errors.Is(err, sql.ErrNoRows) {
+			return commentNotFound(id)
+		}
+		if err != nil {
+			return err
+		}
+
+		if !parentID.Valid {
+			return nil
+		}
+		return prune(tx, int(parentID.Int64))
+	})
+}
+
+// prune скрывает из списков удалённый комментарий id, если видимых ответов
+// у него не осталось, и затем так же проверяет его родителя
+func prune(tx *sql.Tx, id int) error {
+	for {
+		var parentID sql.NullInt64
+		err := tx.QueryRow(`UPDATE comments c SET is_pruned = TRUE
+			WHERE c.id = $1 AND c.is_deleted AND NOT c.is_pruned
+				AND NOT EXISTS (SELECT 1 FROM comments r WHERE r.parent_id = c.id AND NOT r.is_pruned)
+			RETURNING c.parent_id`, id).Scan(&parentID)
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		if !parentID.Valid {
+			return nil
+		}
+		id = int(parentID.Int64)
+	}
+}
+
+func (s *PostgresStore) GetPostRevisions(postID int, first int, after string) (*model.RevisionConnection, error) {
+	return s.revisionPage("post_revisions", "post_id", "title", postID, first, after)
+}
 
-	return s.GetPost(id, 0, 0)
+func (s *PostgresStore) GetCommentRevisions(commentID int, first int, after string) (*model.RevisionConnection, error) {
+	return s.revisionPage("comment_revisions", "comment_id", "NULL", commentID, first, after)
 }
 
-func (s *PostgresStore) UpdateComment(id int, content string) (*model.Comment, error) {
-	if len(content) > 2000 {
-		return nil, errors.New("comment is too long")
+// revisionPage строит страницу истории правок из таблицы table. У комментариев
+// заголовка нет, для них titleColumn - NULL.
+func (s *PostgresStore) revisionPage(table, idColumn, titleColumn string, id, first int, after string) (*model.RevisionConnection, error) {
+	first, err := pageSize(first)
+	if err != nil {
+		return nil, err
 	}
 
-	_, err := s.db.Exec("UPDATE comments SET content = $1 WHERE id = $2", content, id)
+	afterNumber, err := decodeCursor(after)
 	if err != nil {
 		return nil, err
 	}
 
-	return s.GetComment(id)
+	var total int
+	if err := s.db.QueryRow(fmt.Sprintf("SELECT COUNT(*) FROM %s WHERE %s = $1", table, idColumn), id).Scan(&total); err != nil {
+		return nil, err
+	}
+
+	rows, err := s.db.Query(fmt.Sprintf(`SELECT number, %s, content, editor, edited_at FROM %s
+		WHERE %s = $1 AND number > $2 ORDER BY number LIMIT $3`, titleColumn, table, idColumn), id, afterNumber, first+1)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	revisions := []*model.Revision{}
+	for rows.Next() {
+		var r model.Revision
+		if err := rows.Scan(&r.Number, &r.Title, &r.Content, &r.Editor, &r.EditedAt); err != nil {
+			return nil, err
+		}
+		revisions = append(revisions, &r)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	hasNextPage := len(revisions) > first
+	if hasNextPage {
+		revisions = revisions[:first]
+	}
+
+	return newRevisionConnection(revisions, total, hasNextPage), nil
 }
 
-func (s *PostgresStore) DisableComments(postID int) error {
-	_, err := s.db.Exec("UPDATE posts SET comments_enabled = false WHERE id = $1", postID)
-	return err
+func (s *PostgresStore) GetPostRevision(postID, number int) (*model.Revision, error) {
+	return s.revision("SELECT number, title, content, editor, edited_at FROM post_revisions WHERE post_id = $1 AND number = $2", postID, number)
+}
+
+func (s *PostgresStore) GetCommentRevision(commentID, number int) (*model.Revision, error) {
+	return s.revision("SELECT number, NULL, content, editor, edited_at FROM comment_revisions WHERE comment_id = $1 AND number = $2", commentID, number)
+}
+
+func (s *PostgresStore) revision(query string, id, number int) (*model.Revision, error) {
+	var r model.Revision
+	err := s.db.QueryRow(query, id, number).Scan(&r.Number, &r.Title, &r.Content, &r.Editor, &r.EditedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, revisionNotFound(number)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &r, nil
+}
+
+// voteTable описывает, где хранятся голоса за цель и её счётчики
+type voteTable struct {
+	votes, column string
+	notFound      func(id int) error
+	// Запрос, блокирующий цель и возвращающий её счётчики, статус и автора поста
+	lock string
+	// Запрос, сохраняющий новые счётчики цели ($1 - ID, $2 - за, $3 - против)
+	update string
+}
+
+var voteTables = map[model.VoteTarget]voteTable{
+	model.VoteTargetPost: {
+		votes: "post_votes", column: "post_id",
+		notFound: postNotFound,
+		lock:     "SELECT upvotes, downvotes, status, author FROM posts WHERE id = $1 AND NOT is_deleted FOR UPDATE",
+		update:   "UPDATE posts SET upvotes = $2, downvotes = $3 WHERE id = $1",
+	},
+	model.VoteTargetComment: {
+		votes: "comment_votes", column: "comment_id",
+		notFound: commentNotFound,
+		lock: `SELECT c.upvotes, c.downvotes, p.status, p.author FROM comments c JOIN posts p ON p.id = c.post_id
+			WHERE c.id = $1 AND NOT c.is_deleted AND NOT p.is_deleted FOR UPDATE OF c`,
+		// Ключи ранжирования хранятся, чтобы по ним можно было сортировать в базе
+		update: "UPDATE comments SET upvotes = $2, downvotes = $3, best = $4, controversy = $5 WHERE id = $1",
+	},
+}
+
+func voteTableFor(target model.VoteTarget) (voteTable, error) {
+	t, ok := voteTables[target]
+	if !ok {
+		return voteTable{}, fmt.Errorf("%w: unknown vote target %s", ErrInvalidArgument, target)
+	}
+	return t, nil
+}
+
+func (s *PostgresStore) Vote(target model.VoteTarget, targetID int, userID string, value int) (*model.VoteResult, error) {
+	if err := validateVote(value); err != nil {
+		return nil, err
+	}
+
+	t, err := voteTableFor(target)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &model.VoteResult{TargetType: target, TargetID: targetID, MyVote: value}
+	err = s.inTx(func(tx *sql.Tx) error {
+		// Блокировка цели не даёт параллельным голосам затереть счётчики друг друга
+		var up, down int
+		var status model.PostStatus
+		var author string
+		err := tx.QueryRow(t.lock, targetID).Scan(&up, &down, &status, &author)
+		if errors.Is(err, sql.ErrNoRows) {
+			return t.notFound(targetID)
+		}
+		if err != nil {
+			return err
+		}
+		if err := checkPublished(status, author, userID, t.notFound(targetID)); err != nil {
+			return err
+		}
+
+		var old int
+		err = tx.QueryRow(fmt.Sprintf("SELECT value FROM %s WHERE %s = $1 AND user_id = $2", t.votes, t.column), targetID, userID).Scan(&old)
+		if err != nil && !errors.Is(err, sql.ErrNoRows) {
+			return err
+		}
+
+		if value == 0 {
+			_, err = tx.Exec(fmt.Sprintf("DELETE FROM %s WHERE %s = $1 AND user_id = $2", t.votes, t.column), targetID, userID)
+		} else {
+			_, err = tx.Exec(fmt.Sprintf(`INSERT INTO %[1]s (%[2]s, user_id, value) VALUES ($1, $2, $3)
+				ON CONFLICT (%[2]s, user_id) DO UPDATE SET value = EXCLUDED.value`, t.votes, t.column), targetID, userID, value)
+		}
+		if err != nil {
+			return err
+		}
+
+		up, down = applyVote(up, down, old, value)
+		args := []interface{}{targetID, up, down}
+		if target == model.VoteTargetComment {
+			args = append(args, wilson(up, down), controversy(up, down))
+		}
+		if _, err := tx.Exec(t.update, args...); err != nil {
+			return err
+		}
+
+		result.Upvotes, result.Downvotes, result.Score = up, down, up-down
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+func (s *PostgresStore) GetVotes(target model.VoteTarget, ids []int, userID string) (map[int]int, error) {
+	t, err := voteTableFor(target)
+	if err != nil {
+		return nil, err
+	}
+
+	targetIDs := make(pq.Int64Array, len(ids))
+	for i, id := range ids {
+		targetIDs[i] = int64(id)
+	}
+
+	rows, err := s.db.Query(fmt.Sprintf("SELECT %[2]s, value FROM %[1]s WHERE %[2]s = ANY($1) AND user_id = $2", t.votes, t.column), targetIDs, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	votes := make(map[int]int, len(ids))
+	for rows.Next() {
+		var id, value int
+		if err := rows.Scan(&id, &value); err != nil {
+			return nil, err
+		}
+		votes[id] = value
+	}
+
+	return votes, rows.Err()
+}
+
+func (s *PostgresStore) AddReaction(commentID int, userID, emoji string) ([]*model.Reaction, error) {
+	return s.react(commentID, userID, `INSERT INTO comment_reactions (comment_id, user_id, emoji, created_at)
+		VALUES ($1, $2, $3, $4) ON CONFLICT (comment_id, user_id, emoji) DO NOTHING`, commentID, userID, emoji, s.now())
+}
+
+func (s *PostgresStore) RemoveReaction(commentID int, userID, emoji string) ([]*model.Reaction, error) {
+	return s.react(commentID, userID, "DELETE FROM comment_reactions WHERE comment_id = $1 AND user_id = $2 AND emoji = $3", commentID, userID, emoji)
+}
+
+// react выполняет изменение реакций комментария и возвращает его реакции
+func (s *PostgresStore) react(commentID int, userID, query string, args ...interface{}) ([]*model.Reaction, error) {
+	var reactions []*model.Reaction
+	err := s.inTx(func(tx *sql.Tx) error {
+		// Блокировка не даёт удалить комментарий, пока на него ставится реакция
+		var status model.PostStatus
+		var author string
+		err := tx.QueryRow(`SELECT p.status, p.author FROM comments c JOIN posts p ON p.id = c.post_id
+			WHERE c.id = $1 AND NOT c.is_deleted AND NOT p.is_deleted FOR SHARE OF c`, commentID).Scan(&status, &author)
+		if errors.Is(err, sql.ErrNoRows) {
+			return commentNotFound(commentID)
+		}
+		if err != nil {
+			return err
+		}
+		if err := checkPublished(status, author, userID, commentNotFound(commentID)); err != nil {
+			return err
+		}
+
+		if _, err := tx.Exec(query, args...); err != nil {
+			return err
+		}
+
+		result, err := queryReactions(tx, []int{commentID}, userID)
+		if err != nil {
+			return err
+		}
+		reactions = result[commentID]
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if reactions == nil {
+		reactions = []*model.Reaction{}
+	}
+	return reactions, nil
+}
+
+func (s *PostgresStore) GetReactions(commentIDs []int, userID string) (map[int][]*model.Reaction, error) {
+	return queryReactions(s.db, commentIDs, userID)
+}
+
+type querier interface {
+	Query(query string, args ...interface{}) (*sql.Rows, error)
+}
+
+func queryReactions(q querier, commentIDs []int, userID string) (map[int][]*model.Reaction, error) {
+	ids := make(pq.Int64Array, len(commentIDs))
+	for i, id := range commentIDs {
+		ids[i] = int64(id)
+	}
+
+	rows, err := q.Query(`SELECT comment_id, emoji, COUNT(*), BOOL_OR(user_id = $2) FROM comment_reactions
+		WHERE comment_id = ANY($1) GROUP BY comment_id, emoji ORDER BY comment_id, MIN(created_at), emoji`, ids, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	reactions := make(map[int][]*model.Reaction, len(commentIDs))
+	for rows.Next() {
+		var id int
+		var r model.Reaction
+		if err := rows.Scan(&id, &r.Emoji, &r.Count, &r.ReactedByMe); err != nil {
+			return nil, err
+		}
+		reactions[id] = append(reactions[id], &r)
+	}
+
+	return reactions, rows.Err()
+}
+
+// Конфигурация, которой разбирается запрос и строится фрагмент. Конфигурация
+// russian приводит латинские слова к основе по правилам english, поэтому
+// её лексемы совпадают с обеими частями поискового вектора.
+const searchConfig = "russian"
+
+// Поисковый запрос в синтаксисе websearch_to_tsquery ($1 - текст запроса)
+const searchQuery = "(SELECT websearch_to_tsquery('" + searchConfig + "', $1) AS q) query"
+
+// Параметры ts_headline для фрагмента найденного текста
+const headlineOptions = "StartSel=<mark>, StopSel=</mark>, MaxWords=35, MinWords=15"
+
+// headline строит фрагмент текста column с найденными словами запроса q.
+// Текст экранируется до ts_headline так же, как html.EscapeString, поэтому
+// теги <mark> - единственная разметка во фрагменте.
+func headline(column, q string) string {
+	escaped := `replace(replace(replace(replace(replace(` + column + `, '&', '&amp;'), '<', '&lt;'), '>', '&gt;'), '"', '&#34;'), '''', '&#39;')`
+	return fmt.Sprintf("ts_headline('%s', %s, %s, '%s')", searchConfig, escaped, q, headlineOptions)
+}
+
+func (s *PostgresStore) SearchPosts(query string, first int, after string) (*model.PostSearchConnection, error) {
+	if _, err := searchTerms(query); err != nil {
+		return nil, err
+	}
+
+	first, err := pageSize(first)
+	if err != nil {
+		return nil, err
+	}
+
+	pos, err := decodeKeyCursor(after)
+	if err != nil {
+		return nil, err
+	}
+
+	// Неопубликованные посты в поиск не попадают
+	const where = "NOT is_deleted AND status = 'PUBLISHED' AND search @@ q"
+
+	var total int
+	if err := s.db.QueryRow("SELECT COUNT(*) FROM posts, "+searchQuery+" WHERE "+where, query).Scan(&total); err != nil {
+		return nil, err
+	}
+
+	cond, args := afterRank(pos, []interface{}{query})
+	args = append(args, first+1)
+	// Фрагмент строится только для постов, попавших на страницу
+	rows, err := s.db.Query(fmt.Sprintf(`SELECT %s, rank, %s FROM (
+		SELECT posts.*, q, ts_rank(search, q)::float8 AS rank FROM posts, %s WHERE %s
+	) p WHERE %s ORDER BY rank DESC, id ASC LIMIT $%d`, postColumns, headline("content", "q"), searchQuery, where, cond, len(args)), args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var posts []*model.Post
+	var hits []position
+	var snippets []string
+	for rows.Next() {
+		var hit position
+		var snippet string
+		p, err := scanPost(rows, &hit.key, &snippet)
+		if err != nil {
+			return nil, err
+		}
+		hit.id = p.ID
+		posts, hits, snippets = append(posts, p), append(hits, hit), append(snippets, snippet)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	hasNext := len(posts) > first
+	if hasNext {
+		posts, hits, snippets = posts[:first], hits[:first], snippets[:first]
+	}
+
+	return newPostSearchConnection(posts, hits, snippets, total, hasNext), nil
+}
+
+func (s *PostgresStore) SearchComments(postID *int, query string, first int, after string) (*model.CommentSearchConnection, error) {
+	if _, err := searchTerms(query); err != nil {
+		return nil, err
+	}
+
+	first, err := pageSize(first)
+	if err != nil {
+		return nil, err
+	}
+
+	pos, err := decodeKeyCursor(after)
+	if err != nil {
+		return nil, err
+	}
+
+	// Ответы скрытых комментариев обычные пользователи не видят, поэтому
+	// не ищем и их: ни комментарий, ни его предки не должны быть скрыты
+	where := "NOT is_deleted AND NOT is_pruned AND NOT is_hidden AND search @@ q AND post_id IN (SELECT id FROM posts WHERE NOT is_deleted) AND NOT " + hiddenAncestor
+	args := []interface{}{query}
+	if postID != nil {
+		args = append(args, *postID)
+		where += fmt.Sprintf(" AND post_id = $%d", len(args))
+	}
+
+	var total int
+	if err := s.db.QueryRow("SELECT COUNT(*) FROM comments, "+searchQuery+" WHERE "+where, args...).Scan(&total); err != nil {
+		return nil, err
+	}
+
+	cond, args := afterRank(pos, args)
+	args = append(args, first+1)
+	rows, err := s.db.Query(fmt.Sprintf(`SELECT %s, c.rank, %s FROM (
+		SELECT comments.*, q, ts_rank(search, q)::float8 AS rank FROM comments, %s WHERE %s
+	) c WHERE %s ORDER BY c.rank DESC, c.id ASC LIMIT $%d`, commentColumns, headline("c.content", "c.q"), searchQuery, where, cond, len(args)), args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var comments []*model.Comment
+	var hits []position
+	var snippets []string
+	for rows.Next() {
+		var hit position
+		var snippet string
+		c, err := scanComment(rows, &hit.key, &snippet)
+		if err != nil {
+			return nil, err
+		}
+		hit.id = c.ID
+		comments, hits, snippets = append(comments, c), append(hits, hit), append(snippets, snippet)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	hasNext := len(comments) > first
+	if hasNext {
+		comments, hits, snippets = comments[:first], hits[:first], snippets[:first]
+	}
+
+	return newCommentSearchConnection(comments, hits, snippets, total, hasNext), nil
+}
+
+// hiddenAncestor - условие "у комментария из comments есть скрытый предок"
+const hiddenAncestor = `EXISTS (
+		WITH RECURSIVE ancestors AS (
+			SELECT a.parent_id, a.is_hidden FROM comments a WHERE a.id = comments.parent_id
+			UNION ALL
+			SELECT a.parent_id, a.is_hidden FROM comments a JOIN ancestors ON a.id = ancestors.parent_id
+		) SELECT 1 FROM ancestors WHERE is_hidden
+	)`
+
+// afterRank добавляет к args аргументы условия "результат поиска идёт после pos"
+// и возвращает это условие
+func afterRank(pos *position, args []interface{}) (string, []interface{}) {
+	if pos == nil {
+		return "TRUE", args
+	}
+	cond, condArgs := keyCondition("rank", *pos, len(args)+1)
+	return cond, append(args, condArgs...)
+}
+
+// addTags отмечает пост тегами tags, недостающие теги создаются
+func addTags(tx *sql.Tx, postID int, tags []string) error {
+	if len(tags) == 0 {
+		return nil
+	}
+
+	names := pq.StringArray(tags)
+	if _, err := tx.Exec("INSERT INTO tags (name) SELECT unnest($1::text[]) ON CONFLICT (name) DO NOTHING", names); err != nil {
+		return err
+	}
+	_, err := tx.Exec("INSERT INTO post_tags (post_id, tag_id) SELECT $1, id FROM tags WHERE name = ANY($2)", postID, names)
+	return err
+}
+
+func (s *PostgresStore) GetPostTags(postIDs []int) (map[int][]string, error) {
+	ids := make(pq.Int64Array, len(postIDs))
+	for i, id := range postIDs {
+		ids[i] = int64(id)
+	}
+
+	rows, err := s.db.Query(`SELECT pt.post_id, t.name FROM post_tags pt JOIN tags t ON t.id = pt.tag_id
+		WHERE pt.post_id = ANY($1) ORDER BY pt.post_id, t.name`, ids)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	tags := make(map[int][]string, len(postIDs))
+	for rows.Next() {
+		var id int
+		var name string
+		if err := rows.Scan(&id, &name); err != nil {
+			return nil, err
+		}
+		tags[id] = append(tags[id], name)
+	}
+
+	return tags, rows.Err()
+}
+
+func (s *PostgresStore) GetTags(first int) ([]*model.Tag, error) {
+	first, err := pageSize(first)
+	if err != nil {
+		return nil, err
+	}
+
+	// Учитываются только опубликованные посты, неиспользуемые теги не попадают в ответ
+	rows, err := s.db.Query(`SELECT t.name, COUNT(*) FROM tags t
+		JOIN post_tags pt ON pt.tag_id = t.id JOIN posts p ON p.id = pt.post_id AND NOT p.is_deleted AND p.status = 'PUBLISHED'
+		GROUP BY t.name ORDER BY COUNT(*) DESC, t.name LIMIT $1`, first)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	tags := []*model.Tag{}
+	for rows.Next() {
+		var tag model.Tag
+		if err := rows.Scan(&tag.Name, &tag.Count); err != nil {
+			return nil, err
+		}
+		tags = append(tags, &tag)
+	}
+
+	return tags, rows.Err()
+}
+
+const reportColumns = "id, comment_id, reporter, reason, status, action, resolved_by, resolved_at, created_at"
+
+func scanReport(row rowScanner) (*model.Report, error) {
+	var r model.Report
+	if err := row.Scan(&r.ID, &r.CommentID, &r.Reporter, &r.Reason, &r.Status, &r.Action, &r.ResolvedBy, &r.ResolvedAt, &r.CreatedAt); err != nil {
+		return nil, err
+	}
+	return &r, nil
+}
+
+func (s *PostgresStore) ReportComment(commentID int, reporter, reason string) (*model.Report, error) {
+	if err := validateReportReason(reason); err != nil {
+		return nil, err
+	}
+
+	var report *model.Report
+	err := s.inTx(func(tx *sql.Tx) error {
+		var id int
+		err := tx.QueryRow(`SELECT c.id FROM comments c JOIN posts p ON p.id = c.post_id
+			WHERE c.id = $1 AND NOT c.is_deleted AND NOT p.is_deleted FOR SHARE OF c`, commentID).Scan(&id)
+		if errors.Is(err, sql.ErrNoRows) {
+			return commentNotFound(commentID)
+		}
+		if err != nil {
+			return err
+		}
+
+		report, err = scanReport(tx.QueryRow(`INSERT INTO comment_reports (comment_id, reporter, reason, created_at) VALUES ($1, $2, $3, $4)
+			ON CONFLICT (comment_id, reporter) DO NOTHING RETURNING `+reportColumns, commentID, reporter, reason, s.now()))
+		if errors.Is(err, sql.ErrNoRows) {
+			return fmt.Errorf("%w: comment %d is already reported by %s", ErrInvalidArgument, commentID, reporter)
+		}
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return report, nil
+}
+
+func (s *PostgresStore) GetReports(first int, after string, status model.ReportStatus) (*model.ReportConnection, error) {
+	first, err := pageSize(first)
+	if err != nil {
+		return nil, err
+	}
+
+	afterID, err := decodeCursor(after)
+	if err != nil {
+		return nil, err
+	}
+
+	var total int
+	if err := s.db.QueryRow("SELECT COUNT(*) FROM comment_reports WHERE status = $1", status).Scan(&total); err != nil {
+		return nil, err
+	}
+
+	rows, err := s.db.Query("SELECT "+reportColumns+" FROM comment_reports WHERE status = $1 AND id > $2 ORDER BY id LIMIT $3", status, afterID, first+1)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	reports := []*model.Report{}
+	for rows.Next() {
+		r, err := scanReport(rows)
+		if err != nil {
+			return nil, err
+		}
+		reports = append(reports, r)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	hasNextPage := len(reports) > first
+	if hasNextPage {
+		reports = reports[:first]
+	}
+
+	return newReportConnection(reports, total, hasNextPage), nil
+}
+
+func (s *PostgresStore) ResolveReport(id int, action model.ReportAction, moderator string) (*model.Report, error) {
+	status, err := reportStatusFor(action)
+	if err != nil {
+		return nil, err
+	}
+
+	var report *model.Report
+	now := s.now()
+	err = s.inTx(func(tx *sql.Tx) error {
+		var commentID, postID int
+		var current model.ReportStatus
+		err := tx.QueryRow(`SELECT r.comment_id, c.post_id, r.status FROM comment_reports r JOIN comments c ON c.id = r.comment_id
+			WHERE r.id = $1 FOR UPDATE OF r`, id).Scan(&commentID, &postID, &current)
+		if errors.Is(err, sql.ErrNoRows) {
+			return reportNotFound(id)
+		}
+		if err != nil {
+			return err
+		}
+		if current != model.ReportStatusOpen {
+			return fmt.Errorf("%w: report %d is already resolved", ErrInvalidArgument, id)
+		}
+
+		switch action {
+		case model.ReportActionHideComment:
+			res, err := tx.Exec("UPDATE comments SET is_hidden = TRUE WHERE id = $1 AND NOT is_deleted", commentID)
+			if err != nil {
+				return err
+			}
+			if err := expectAffected(res, commentNotFound(commentID)); err != nil {
+				return err
+			}
+		case model.ReportActionDeleteComment:
+			if err := deleteComment(tx, commentID, now); err != nil {
+				return err
+			}
+		case model.ReportActionLockPost:
+			res, err := tx.Exec(`UPDATE posts SET comments_enabled = FALSE, comments_lock_reason = 'MANUAL', updated_at = $2
+				WHERE id = $1 AND NOT is_deleted`, postID, now)
+			if err != nil {
+				return err
+			}
+			if err := expectAffected(res, postNotFound(postID)); err != nil {
+				return err
+			}
+		}
+
+		if _, err := tx.Exec(`UPDATE comment_reports SET status = $2, action = $3, resolved_by = $4, resolved_at = $5
+			WHERE comment_id = $1 AND status = 'OPEN'`, commentID, status, action, moderator, now); err != nil {
+			return err
+		}
+
+		report, err = scanReport(tx.QueryRow("SELECT "+reportColumns+" FROM comment_reports WHERE id = $1", id))
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return report, nil
+}
+
+func (s *PostgresStore) inTx(fn func(tx *sql.Tx) error) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := fn(tx); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// expectAffected возвращает notFound, если запрос не изменил ни одной строки
+func expectAffected(res sql.Result, notFound error) error {
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return notFound
+	}
+	return nil
 }
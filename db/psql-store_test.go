@@ -2,9 +2,13 @@ package db
 
 import (
 	"PostCommentService/graph/model"
+	"database/sql"
+	"errors"
 	"testing"
+	"time"
 
 	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/lib/pq"
 )
 
 func TestGetPosts(t *testing.T) {
@@ -17,27 +21,28 @@ func TestGetPosts(t *testing.T) {
 
 	ps := NewPostgresStore(db)
 
-	rows := sqlmock.NewRows([]string{"id", "title", "content", "comments_enabled", "author"}).
-		AddRow(1, "Test title 1", "Test content 1", true, "Test author 1").
-		AddRow(2, "Test title 2", "Test content 2", false, "Test author 2")
+	rows := sqlmock.NewRows([]string{"id", "title", "content", "comments_enabled", "author", "comments_lock_reason", "auto_lock_after_days", "edited_at", "created_at", "updated_at", "upvotes", "downvotes", "status", "publish_at"}).
+		AddRow(1, "Test title 1", "Test content 1", true, "Test author 1", nil, nil, nil, time.Time{}, time.Time{}, 0, 0, "PUBLISHED", time.Time{}).
+		AddRow(2, "Test title 2", "Test content 2", false, "Test author 2", nil, nil, nil, time.Time{}, time.Time{}, 0, 0, "PUBLISHED", time.Time{})
 
-	mock.ExpectQuery("SELECT id, title, content, comments_enabled, author FROM posts").WillReturnRows(rows)
+	mock.ExpectQuery("^SELECT COUNT\\(\\*\\) FROM posts WHERE NOT is_deleted AND \\(status = 'PUBLISHED' OR author = \\$1 AND \\$1 <> ''\\)$").WithArgs("").WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(2))
+	mock.ExpectQuery("^SELECT id, title, content, comments_enabled, author, comments_lock_reason, auto_lock_after_days, edited_at, created_at, updated_at, upvotes, downvotes, status, publish_at FROM posts WHERE NOT is_deleted AND \\(status = 'PUBLISHED' OR author = \\$1 AND \\$1 <> ''\\) ORDER BY id ASC LIMIT \\$2$").WithArgs("", 11).WillReturnRows(rows)
 
-	posts, err := ps.GetPosts()
+	posts, err := ps.GetPosts(10, "", model.PostSortID, nil, "")
 	if err != nil {
 		t.Errorf("error was not expected while getting posts: %s", err)
 	}
 
-	if len(posts) != 2 {
-		t.Errorf("expected length of posts list to be '2', got '%v'", len(posts))
+	if len(posts.Edges) != 2 || posts.TotalCount != 2 || posts.PageInfo.HasNextPage {
+		t.Fatalf("unexpected posts connection: %+v", posts)
 	}
 
-	post1 := posts[0]
+	post1 := posts.Edges[0].Node
 	if post1.ID != 1 || post1.Title != "Test title 1" || post1.Content != "Test content 1" || post1.Author != "Test author 1" || post1.CommentsEnabled != true {
 		t.Errorf("unexpected values in post1: %+v", post1)
 	}
 
-	post2 := posts[1]
+	post2 := posts.Edges[1].Node
 	if post2.ID != 2 || post2.Title != "Test title 2" || post2.Content != "Test content 2" || post2.Author != "Test author 2" || post2.CommentsEnabled != false {
 		t.Errorf("unexpected values in post2: %+v", post2)
 	}
@@ -47,7 +52,7 @@ func TestGetPosts(t *testing.T) {
 	}
 }
 
-func TestGetPost(t *testing.T) {
+func TestGetPostsFiltered(t *testing.T) {
 	db, mock, err := sqlmock.New()
 	if err != nil {
 		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
@@ -56,17 +61,109 @@ func TestGetPost(t *testing.T) {
 
 	ps := NewPostgresStore(db)
 
-	rows := sqlmock.NewRows([]string{"id", "title", "content", "comments_enabled", "author"}).
-		AddRow(1, "Test title", "Test content", true, "Test author")
+	author := "Test author"
+	enabled := true
+	filter := &model.PostFilter{Author: &author, CommentsEnabled: &enabled}
+
+	rows := sqlmock.NewRows([]string{"id", "title", "content", "comments_enabled", "author", "comments_lock_reason", "auto_lock_after_days", "edited_at", "created_at", "updated_at", "upvotes", "downvotes", "status", "publish_at"}).
+		AddRow(4, "Test title 4", "Test content 4", true, "Test author", nil, nil, nil, time.Time{}, time.Time{}, 0, 0, "PUBLISHED", time.Time{}).
+		AddRow(3, "Test title 3", "Test content 3", true, "Test author", nil, nil, nil, time.Time{}, time.Time{}, 0, 0, "PUBLISHED", time.Time{})
+
+	mock.ExpectQuery("^SELECT COUNT\\(\\*\\) FROM posts WHERE NOT is_deleted AND \\(status = 'PUBLISHED' OR author = \\$1 AND \\$1 <> ''\\) AND author = \\$2 AND comments_enabled = \\$3$").WithArgs("", "Test author", true).WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(3))
+	mock.ExpectQuery("^SELECT (.+) FROM posts WHERE NOT is_deleted AND \\(status = 'PUBLISHED' OR author = \\$1 AND \\$1 <> ''\\) AND author = \\$2 AND comments_enabled = \\$3 AND id < \\$4 ORDER BY id DESC LIMIT \\$5$").WithArgs("", "Test author", true, 5, 2).WillReturnRows(rows)
+
+	posts, err := ps.GetPosts(1, encodeCursor(5), model.PostSortNewest, filter, "")
+	if err != nil {
+		t.Errorf("error was not expected while getting posts: %s", err)
+	}
+
+	if len(posts.Edges) != 1 || posts.Edges[0].Node.ID != 4 || !posts.PageInfo.HasNextPage || posts.TotalCount != 3 {
+		t.Errorf("unexpected posts connection: total %d, %+v", posts.TotalCount, posts.Edges)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestGetPostsByTags(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
 
-	mock.ExpectQuery("^SELECT (.+) FROM posts WHERE id = \\$1$").WithArgs(1).WillReturnRows(rows)
+	ps := NewPostgresStore(db)
+	filter := &model.PostFilter{Tags: []string{"News", " go "}}
 
-	commentRows := sqlmock.NewRows([]string{"id", "post_id", "author", "content", "parent_id"}).
-		AddRow(1, 1, "Comment author", "Comment content", nil)
+	where := "^SELECT COUNT\\(\\*\\) FROM posts WHERE NOT is_deleted AND \\(status = 'PUBLISHED' OR author = \\$1 AND \\$1 <> ''\\) AND id IN \\(SELECT pt.post_id FROM post_tags pt JOIN tags t ON t.id = pt.tag_id\\s+WHERE t.name = ANY\\(\\$2\\) GROUP BY pt.post_id HAVING COUNT\\(\\*\\) = \\$3\\)$"
+	mock.ExpectQuery(where).WithArgs("alice", pq.StringArray{"go", "news"}, 2).WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(0))
+	mock.ExpectQuery("^SELECT (.+) HAVING COUNT\\(\\*\\) = \\$3\\) ORDER BY id ASC LIMIT \\$4$").WithArgs("alice", pq.StringArray{"go", "news"}, 2, 11).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}))
 
-	mock.ExpectQuery("^SELECT (.+) FROM comments WHERE post_id = \\$1 ORDER BY id ASC").WithArgs(1).WillReturnRows(commentRows)
+	posts, err := ps.GetPosts(10, "", model.PostSortID, filter, "alice")
+	if err != nil {
+		t.Fatalf("error was not expected while getting posts: %s", err)
+	}
+	if posts.TotalCount != 0 || len(posts.Edges) != 0 {
+		t.Errorf("unexpected posts connection: %+v", posts)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
 
-	post, err := ps.GetPost(1, 0, 10)
+func TestTags(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	ps := NewPostgresStore(db)
+
+	mock.ExpectQuery("^SELECT pt.post_id, t.name FROM post_tags pt JOIN tags t ON t.id = pt.tag_id\\s+WHERE pt.post_id = ANY\\(\\$1\\) ORDER BY pt.post_id, t.name$").
+		WithArgs(pq.Int64Array{1, 2}).
+		WillReturnRows(sqlmock.NewRows([]string{"post_id", "name"}).AddRow(1, "go").AddRow(1, "news"))
+
+	tags, err := ps.GetPostTags([]int{1, 2})
+	if err != nil {
+		t.Fatalf("error was not expected while getting post tags: %s", err)
+	}
+	if len(tags) != 1 || len(tags[1]) != 2 || tags[1][1] != "news" {
+		t.Errorf("unexpected post tags: %v", tags)
+	}
+
+	mock.ExpectQuery("^SELECT t.name, COUNT\\(\\*\\) FROM tags t (.+) AND NOT p.is_deleted AND p.status = 'PUBLISHED'\\s+GROUP BY t.name ORDER BY COUNT\\(\\*\\) DESC, t.name LIMIT \\$1$").
+		WithArgs(20).WillReturnRows(sqlmock.NewRows([]string{"name", "count"}).AddRow("go", 3).AddRow("news", 1))
+
+	popular, err := ps.GetTags(20)
+	if err != nil {
+		t.Fatalf("error was not expected while getting tags: %s", err)
+	}
+	if len(popular) != 2 || popular[0].Name != "go" || popular[0].Count != 3 {
+		t.Errorf("unexpected tags: %+v", popular)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestGetPost(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	ps := NewPostgresStore(db)
+
+	rows := sqlmock.NewRows([]string{"id", "title", "content", "comments_enabled", "author", "comments_lock_reason", "auto_lock_after_days", "edited_at", "created_at", "updated_at", "upvotes", "downvotes", "status", "publish_at"}).
+		AddRow(1, "Test title", "Test content", true, "Test author", nil, nil, nil, time.Time{}, time.Time{}, 0, 0, "PUBLISHED", time.Time{})
+
+	mock.ExpectQuery("^SELECT (.+) FROM posts WHERE id = \\$1 AND NOT is_deleted$").WithArgs(1).WillReturnRows(rows)
+
+	post, err := ps.GetPost(1)
 	if err != nil {
 		t.Errorf("error was not expected while getting post: %s", err)
 	}
@@ -79,15 +176,6 @@ func TestGetPost(t *testing.T) {
 	if post.ID != 1 || post.Title != "Test title" || post.Content != "Test content" || post.Author != "Test author" || post.CommentsEnabled != true {
 		t.Errorf("unexpected values in post: %+v", post)
 	}
-
-	if len(post.Comments) != 1 {
-		t.Errorf("expected length of comments list to be '1', got '%v'", len(post.Comments))
-	}
-
-	comment := post.Comments[0]
-	if comment.ID != 1 || comment.PostID != 1 || comment.Author != "Comment author" || comment.Content != "Comment content" {
-		t.Errorf("unexpected values in comment: %+v", comment)
-	}
 	if err := mock.ExpectationsWereMet(); err != nil {
 		t.Errorf("there were unfulfilled expectations: %s", err)
 	}
@@ -102,10 +190,10 @@ func TestGetComment(t *testing.T) {
 
 	ps := NewPostgresStore(db)
 
-	rows := sqlmock.NewRows([]string{"id", "post_id", "author", "content", "parent_id"}).
-		AddRow(1, 1, "Comment author", "Comment content", nil)
+	rows := sqlmock.NewRows([]string{"id", "post_id", "author", "content", "parent_id", "is_deleted", "reply_count", "edited_at", "created_at", "updated_at", "upvotes", "downvotes", "is_hidden", "hidden_reply_count"}).
+		AddRow(1, 1, "Comment author", "Comment content", nil, false, 2, nil, time.Time{}, time.Time{}, 0, 0, false, 0)
 
-	mock.ExpectQuery("^SELECT (.+) FROM comments WHERE id = \\$1$").WithArgs(1).WillReturnRows(rows)
+	mock.ExpectQuery("^SELECT (.+) FROM comments c JOIN posts p ON p.id = c.post_id WHERE c.id = \\$1 AND NOT c.is_pruned AND NOT p.is_deleted$").WithArgs(1).WillReturnRows(rows)
 
 	comment, err := ps.GetComment(1)
 	if err != nil {
@@ -117,7 +205,7 @@ func TestGetComment(t *testing.T) {
 		return
 	}
 
-	if comment.ID != 1 || comment.PostID != 1 || comment.Author != "Comment author" || comment.Content != "Comment content" {
+	if comment.ID != 1 || comment.PostID != 1 || comment.Author != "Comment author" || comment.Content != "Comment content" || comment.ReplyCount != 2 {
 		t.Errorf("unexpected values in comment: %+v", comment)
 	}
 	if err := mock.ExpectationsWereMet(); err != nil {
@@ -134,28 +222,38 @@ func TestGetComments(t *testing.T) {
 
 	ps := NewPostgresStore(db)
 
-	commentRows := sqlmock.NewRows([]string{"id", "post_id", "author", "content", "parent_id"}).
-		AddRow(1, 1, "Comment author", "Comment content", nil).
-		AddRow(2, 1, "Another author", "Another content", nil)
+	commentRows := sqlmock.NewRows([]string{"id", "post_id", "author", "content", "parent_id", "is_deleted", "reply_count", "edited_at", "created_at", "updated_at", "upvotes", "downvotes", "is_hidden", "hidden_reply_count"}).
+		AddRow(2, 1, "Comment author", "Comment content", nil, false, 1, nil, time.Time{}, time.Time{}, 0, 0, false, 0).
+		AddRow(3, 1, "Another author", "Another content", nil, false, 0, nil, time.Time{}, time.Time{}, 0, 0, false, 0)
 
-	mock.ExpectQuery("^SELECT (.+) FROM comments WHERE post_id = \\$1 ORDER BY id ASC").WithArgs(1).WillReturnRows(commentRows)
+	mock.ExpectQuery("^SELECT post_id, COUNT\\(\\*\\), (.+) FROM comments WHERE post_id = ANY\\(\\$1\\) AND NOT is_pruned AND parent_id IS NULL AND NOT is_hidden GROUP BY post_id$").WithArgs(sqlmock.AnyArg(), 1).WillReturnRows(sqlmock.NewRows([]string{"post_id", "count", "remaining"}).AddRow(1, 4, 3))
+	mock.ExpectQuery("PARTITION BY post_id (.+) WHERE post_id = ANY\\(\\$1\\) AND NOT is_pruned AND parent_id IS NULL AND NOT is_hidden AND id > \\$2(.+) WHERE c.rn <= \\$3 ORDER BY c.post_id, c.rn$").WithArgs(sqlmock.AnyArg(), 1, 2).WillReturnRows(commentRows)
 
-	comments, err := ps.GetComments(1, 0, 10)
+	pages, err := ps.GetComments([]int{1}, 2, encodeCursor(1), model.CommentSortOld, false)
 	if err != nil {
-		t.Errorf("error was not expected while getting comments: %s", err)
+		t.Fatalf("error was not expected while getting comments: %s", err)
 	}
 
-	if len(comments) != 2 {
-		t.Errorf("expected length of comments list to be '2', got '%v'", len(comments))
+	comments := pages[1]
+	if len(comments.Edges) != 2 {
+		t.Fatalf("expected length of comments list to be '2', got '%v'", len(comments.Edges))
+	}
+
+	if comments.TotalCount != 4 || !comments.PageInfo.HasNextPage || *comments.PageInfo.EndCursor != encodeCursor(3) {
+		t.Errorf("unexpected page info: total %d, %+v", comments.TotalCount, comments.PageInfo)
 	}
 
-	firstComment := comments[0]
-	if firstComment.ID != 1 || firstComment.PostID != 1 || firstComment.Author != "Comment author" || firstComment.Content != "Comment content" {
+	firstComment := comments.Edges[0].Node
+	if firstComment.ID != 2 || firstComment.PostID != 1 || firstComment.Author != "Comment author" || firstComment.Content != "Comment content" {
 		t.Errorf("unexpected values in first comment: %+v", firstComment)
 	}
 
-	secondComment := comments[1]
-	if secondComment.ID != 2 || secondComment.PostID != 1 || secondComment.Author != "Another author" || secondComment.Content != "Another content" {
+	if firstComment.ReplyCount != 1 {
+		t.Errorf("expected first comment to have 1 reply, got %d", firstComment.ReplyCount)
+	}
+
+	secondComment := comments.Edges[1].Node
+	if secondComment.ID != 3 || secondComment.PostID != 1 || secondComment.Author != "Another author" || secondComment.Content != "Another content" {
 		t.Errorf("unexpected values in second comment: %+v", secondComment)
 	}
 	if err := mock.ExpectationsWereMet(); err != nil {
@@ -163,26 +261,52 @@ func TestGetComments(t *testing.T) {
 	}
 }
 
-func TestProcessComments(t *testing.T) {
-	commentMap := make(map[int]*model.Comment)
-	commentMap[1] = &model.Comment{ID: 1, PostID: 1, Author: "Author 1", Content: "Content 1"}
-	commentMap[2] = &model.Comment{ID: 2, PostID: 1, Author: "Author 2", Content: "Content 2", ParentID: &commentMap[1].ID}
-	commentMap[3] = &model.Comment{ID: 3, PostID: 1, Author: "Author 3", Content: "Content 3"}
+func TestGetReplies(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
 
-	comments := processComments(commentMap, 0, 10)
+	ps := NewPostgresStore(db)
 
-	if len(comments) != 2 {
-		t.Errorf("expected length of comments list to be '2', got '%v'", len(comments))
+	countRows := sqlmock.NewRows([]string{"parent_id", "count", "remaining"}).
+		AddRow(1, 3, 3).
+		AddRow(2, 1, 1)
+	mock.ExpectQuery("^SELECT parent_id, COUNT\\(\\*\\), (.+) FROM comments WHERE parent_id = ANY\\(\\$1\\) AND NOT is_pruned GROUP BY parent_id$").WithArgs(sqlmock.AnyArg()).WillReturnRows(countRows)
+
+	replyRows := sqlmock.NewRows([]string{"id", "post_id", "author", "content", "parent_id", "is_deleted", "reply_count", "edited_at", "created_at", "updated_at", "upvotes", "downvotes", "is_hidden", "hidden_reply_count"}).
+		AddRow(4, 1, "Reply author", "Reply 1", 1, false, 0, nil, time.Time{}, time.Time{}, 0, 0, false, 0).
+		AddRow(5, 1, "Reply author", "Reply 2", 1, false, 1, nil, time.Time{}, time.Time{}, 0, 0, false, 0).
+		AddRow(6, 1, "Reply author", "Reply 3", 2, false, 0, nil, time.Time{}, time.Time{}, 0, 0, false, 0)
+	mock.ExpectQuery("PARTITION BY parent_id (.+) WHERE c.rn <= \\$2 ORDER BY c.parent_id, c.rn$").WithArgs(sqlmock.AnyArg(), 2).WillReturnRows(replyRows)
+
+	replies, err := ps.GetReplies([]int{1, 2, 3}, 2, "", model.CommentSortOld, true)
+	if err != nil {
+		t.Fatalf("error was not expected while getting replies: %s", err)
+	}
+
+	if len(replies) != 3 {
+		t.Fatalf("expected replies for 3 parents, got %d", len(replies))
 	}
 
-	if comments[0].ID != 1 || comments[1].ID != 3 {
-		t.Errorf("unexpected comment IDs: got '%v' and '%v'", comments[0].ID, comments[1].ID)
+	first := replies[1]
+	if len(first.Edges) != 2 || first.TotalCount != 3 || !first.PageInfo.HasNextPage || first.Edges[1].Node.ReplyCount != 1 {
+		t.Errorf("unexpected replies of comment 1: total %d, %+v", first.TotalCount, first.Edges)
 	}
 
-	if len(comments[0].Child) != 1 || comments[0].Child[0].ID != 2 {
-		t.Errorf("unexpected child comment ID: got '%v'", comments[0].Child[0].ID)
+	second := replies[2]
+	if len(second.Edges) != 1 || second.Edges[0].Node.ID != 6 || second.PageInfo.HasNextPage {
+		t.Errorf("unexpected replies of comment 2: %+v", second.Edges)
 	}
 
+	if len(replies[3].Edges) != 0 || replies[3].TotalCount != 0 {
+		t.Errorf("comment 3 should have no replies: %+v", replies[3])
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
 }
 
 func TestCreatePost(t *testing.T) {
@@ -194,12 +318,21 @@ func TestCreatePost(t *testing.T) {
 	defer db.Close()
 
 	ps := NewPostgresStore(db)
+	created := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	ps.now = func() time.Time { return created }
 
 	rows := sqlmock.NewRows([]string{"id"}).AddRow(1)
 
-	mock.ExpectQuery("INSERT INTO posts").WithArgs("Test title", "Test content", "Test author", true).WillReturnRows(rows)
+	mock.ExpectBegin()
+	mock.ExpectQuery("INSERT INTO posts").WithArgs("Test title", "Test content", "Test author", true, created, model.PostStatusPublished, created).WillReturnRows(rows)
+	tags := pq.StringArray{"go", "web dev"}
+	mock.ExpectExec("^INSERT INTO tags \\(name\\) SELECT unnest\\(\\$1::text\\[\\]\\) ON CONFLICT \\(name\\) DO NOTHING$").WithArgs(tags).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("^INSERT INTO post_tags \\(post_id, tag_id\\) SELECT \\$1, id FROM tags WHERE name = ANY\\(\\$2\\)$").WithArgs(1, tags).
+		WillReturnResult(sqlmock.NewResult(0, 2))
+	mock.ExpectCommit()
 
-	post, err := ps.CreatePost("Test title", "Test content", "Test author")
+	post, err := ps.CreatePost("Test title", "Test content", "Test author", []string{" Web   Dev", "GO", "go"}, model.PostStatusPublished, nil)
 	if err != nil {
 		t.Errorf("error was not expected while creating post: %s", err)
 	}
@@ -224,6 +357,10 @@ func TestCreatePost(t *testing.T) {
 		t.Errorf("expected post CommentsEnabled to be 'true', got '%v'", post.CommentsEnabled)
 	}
 
+	if !post.CreatedAt.Equal(created) || !post.UpdatedAt.Equal(created) {
+		t.Errorf("expected post timestamps to be %s, got %s and %s", created, post.CreatedAt, post.UpdatedAt)
+	}
+
 	if err := mock.ExpectationsWereMet(); err != nil {
 		t.Errorf("there were unfulfilled expectations: %s", err)
 	}
@@ -238,11 +375,16 @@ func TestCreateComment(t *testing.T) {
 	defer db.Close()
 
 	ps := NewPostgresStore(db)
+	created := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	ps.now = func() time.Time { return created }
 
-	mock.ExpectQuery("SELECT comments_enabled FROM posts WHERE id = \\$1").WithArgs(1).WillReturnRows(sqlmock.NewRows([]string{"comments_enabled"}).AddRow(true))
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT comments_enabled, status, author FROM posts WHERE id = \\$1 AND NOT is_deleted FOR SHARE").WithArgs(1).WillReturnRows(sqlmock.NewRows([]string{"comments_enabled", "status", "author"}).AddRow(true, "PUBLISHED", "Post author"))
 
 	commentRows := sqlmock.NewRows([]string{"id"}).AddRow(1)
-	mock.ExpectQuery("INSERT INTO comments").WithArgs(1, "Comment author", "Comment content", nil).WillReturnRows(commentRows)
+	mock.ExpectQuery("INSERT INTO comments").WithArgs(1, "Comment author", "Comment content", nil, created).WillReturnRows(commentRows)
+	mock.ExpectExec("SELECT pg_notify\\(\\$1, \\$2\\)").WithArgs(CommentAddedChannel, `{"id":1,"postId":1}`).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectCommit()
 
 	comment, err := ps.CreateComment(1, "Comment author", "Comment content", nil)
 	if err != nil {
@@ -254,7 +396,7 @@ func TestCreateComment(t *testing.T) {
 		return
 	}
 
-	if comment.ID != 1 || comment.PostID != 1 || comment.Author != "Comment author" || comment.Content != "Comment content" {
+	if comment.ID != 1 || comment.PostID != 1 || comment.Author != "Comment author" || comment.Content != "Comment content" || !comment.CreatedAt.Equal(created) {
 		t.Errorf("unexpected values in comment: %+v", comment)
 	}
 	if err := mock.ExpectationsWereMet(); err != nil {
@@ -262,7 +404,7 @@ func TestCreateComment(t *testing.T) {
 	}
 }
 
-func TestUpdatePost(t *testing.T) {
+func TestCreateCommentErrors(t *testing.T) {
 	db, mock, err := sqlmock.New()
 	if err != nil {
 		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
@@ -270,25 +412,166 @@ func TestUpdatePost(t *testing.T) {
 	defer db.Close()
 
 	ps := NewPostgresStore(db)
+	parentID := 5
 
-	mock.ExpectExec("UPDATE posts SET title = \\$1, content = \\$2 WHERE id = \\$3").WithArgs("New title", "New content", 1).WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT comments_enabled, status, author FROM posts WHERE id = \\$1").WithArgs(1).WillReturnError(sql.ErrNoRows)
+	mock.ExpectRollback()
+	if _, err := ps.CreateComment(1, "Comment author", "Comment content", nil); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected %v, got %v", ErrNotFound, err)
+	}
+
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT comments_enabled, status, author FROM posts WHERE id = \\$1").WithArgs(1).WillReturnRows(sqlmock.NewRows([]string{"comments_enabled", "status", "author"}).AddRow(false, "PUBLISHED", "Post author"))
+	mock.ExpectRollback()
+	if _, err := ps.CreateComment(1, "Comment author", "Comment content", nil); !errors.Is(err, ErrCommentsDisabled) {
+		t.Errorf("expected %v, got %v", ErrCommentsDisabled, err)
+	}
 
-	postRows := sqlmock.NewRows([]string{"id", "title", "content", "comments_enabled", "author"}).AddRow(1, "New title", "New content", true, "Test author")
-	mock.ExpectQuery("^SELECT (.+) FROM posts WHERE id = \\$1").WithArgs(1).WillReturnRows(postRows)
+	// Черновик видит только его автор, остальным поста будто нет
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT comments_enabled, status, author FROM posts WHERE id = \\$1").WithArgs(1).WillReturnRows(sqlmock.NewRows([]string{"comments_enabled", "status", "author"}).AddRow(true, "DRAFT", "Post author"))
+	mock.ExpectRollback()
+	if _, err := ps.CreateComment(1, "Comment author", "Comment content", nil); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected %v, got %v", ErrNotFound, err)
+	}
 
-	post, err := ps.UpdatePost(1, "New title", "New content")
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT comments_enabled, status, author FROM posts WHERE id = \\$1").WithArgs(1).WillReturnRows(sqlmock.NewRows([]string{"comments_enabled", "status", "author"}).AddRow(true, "DRAFT", "Post author"))
+	mock.ExpectRollback()
+	if _, err := ps.CreateComment(1, "Post author", "Comment content", nil); !errors.Is(err, ErrPostNotPublished) {
+		t.Errorf("expected %v, got %v", ErrPostNotPublished, err)
+	}
+
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT comments_enabled, status, author FROM posts WHERE id = \\$1").WithArgs(1).WillReturnRows(sqlmock.NewRows([]string{"comments_enabled", "status", "author"}).AddRow(true, "PUBLISHED", "Post author"))
+	mock.ExpectQuery("SELECT post_id, is_deleted, is_hidden OR EXISTS (.+) FROM comments WHERE id = \\$1").WithArgs(parentID).WillReturnRows(sqlmock.NewRows([]string{"post_id", "is_deleted", "hidden"}).AddRow(2, false, false))
+	mock.ExpectRollback()
+	if _, err := ps.CreateComment(1, "Comment author", "Comment content", &parentID); !errors.Is(err, ErrInvalidParent) {
+		t.Errorf("expected %v, got %v", ErrInvalidParent, err)
+	}
+
+	// Ответ в скрытой ветке запрещён
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT comments_enabled, status, author FROM posts WHERE id = \\$1").WithArgs(1).WillReturnRows(sqlmock.NewRows([]string{"comments_enabled", "status", "author"}).AddRow(true, "PUBLISHED", "Post author"))
+	mock.ExpectQuery("SELECT post_id, is_deleted, is_hidden OR EXISTS (.+) FROM comments WHERE id = \\$1").WithArgs(parentID).WillReturnRows(sqlmock.NewRows([]string{"post_id", "is_deleted", "hidden"}).AddRow(1, false, true))
+	mock.ExpectRollback()
+	if _, err := ps.CreateComment(1, "Comment author", "Comment content", &parentID); !errors.Is(err, ErrInvalidParent) {
+		t.Errorf("expected %v, got %v", ErrInvalidParent, err)
+	}
+
+	// Ошибка уведомления откатывает вставку: клиент может безопасно повторить запрос
+	errNotify := errors.New("notify failed")
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT comments_enabled, status, author FROM posts").WithArgs(1).WillReturnRows(sqlmock.NewRows([]string{"comments_enabled", "status", "author"}).AddRow(true, "PUBLISHED", "Post author"))
+	mock.ExpectQuery("INSERT INTO comments").WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+	mock.ExpectExec("SELECT pg_notify").WillReturnError(errNotify)
+	mock.ExpectRollback()
+	if _, err := ps.CreateComment(1, "Comment author", "Comment content", nil); !errors.Is(err, errNotify) {
+		t.Errorf("expected %v, got %v", errNotify, err)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestGetCommentsAfter(t *testing.T) {
+	db, mock, err := sqlmock.New()
 	if err != nil {
-		t.Errorf("error was not expected while updating post: %s", err)
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
 	}
+	defer db.Close()
 
-	if post == nil {
-		t.Errorf("post should not be nil")
-		return
+	ps := NewPostgresStore(db)
+
+	commentRows := sqlmock.NewRows([]string{"id", "post_id", "author", "content", "parent_id", "is_deleted", "reply_count", "edited_at", "created_at", "updated_at", "upvotes", "downvotes", "is_hidden", "hidden_reply_count"}).
+		AddRow(3, 1, "Comment author", "Comment content", nil, false, 0, nil, time.Time{}, time.Time{}, 0, 0, false, 0).
+		AddRow(4, 2, "Another author", "Another content", 3, false, 0, nil, time.Time{}, time.Time{}, 0, 0, false, 0)
+
+	mock.ExpectQuery("^SELECT (.+) FROM comments c WHERE c.id > \\$1 ORDER BY c.id ASC LIMIT \\$2").WithArgs(2, 100).WillReturnRows(commentRows)
+
+	comments, err := ps.GetCommentsAfter(2, 100)
+	if err != nil {
+		t.Errorf("error was not expected while getting comments: %s", err)
+	}
+
+	if len(comments) != 2 {
+		t.Fatalf("expected length of comments list to be '2', got '%v'", len(comments))
+	}
+
+	if comments[0].ID != 3 || comments[1].ID != 4 || comments[1].ParentID == nil || *comments[1].ParentID != 3 {
+		t.Errorf("unexpected comments: %+v, %+v", comments[0], comments[1])
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestLastCommentID(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
 	}
+	defer db.Close()
 
-	if post.ID != 1 || post.Title != "New title" || post.Content != "New content" {
+	ps := NewPostgresStore(db)
+
+	mock.ExpectQuery("SELECT COALESCE\\(MAX\\(id\\), 0\\) FROM comments").WillReturnRows(sqlmock.NewRows([]string{"max"}).AddRow(42))
+
+	id, err := ps.LastCommentID()
+	if err != nil {
+		t.Errorf("error was not expected while getting last comment id: %s", err)
+	}
+
+	if id != 42 {
+		t.Errorf("expected last comment id to be '42', got '%d'", id)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestUpdatePost(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	ps := NewPostgresStore(db)
+	editedAt := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	ps.now = func() time.Time { return editedAt }
+
+	mock.ExpectBegin()
+	mock.ExpectQuery("^SELECT title, content FROM posts WHERE id = \\$1 AND NOT is_deleted FOR UPDATE$").WithArgs(1).
+		WillReturnRows(sqlmock.NewRows([]string{"title", "content"}).AddRow("Old title", "Old content"))
+	mock.ExpectExec("^INSERT INTO post_revisions (.+) FROM post_revisions WHERE post_id = \\$1$").WithArgs(1, "Old title", "Old content", "Editor", editedAt).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	postRows := sqlmock.NewRows([]string{"id", "title", "content", "comments_enabled", "author", "comments_lock_reason", "auto_lock_after_days", "edited_at", "created_at", "updated_at", "upvotes", "downvotes", "status", "publish_at"}).
+		AddRow(1, "New title", "New content", true, "Test author", nil, nil, editedAt, time.Time{}, time.Time{}, 0, 0, "PUBLISHED", time.Time{})
+	// Пустой список снимает все теги
+	mock.ExpectExec("^DELETE FROM post_tags WHERE post_id = \\$1$").WithArgs(1).WillReturnResult(sqlmock.NewResult(0, 2))
+	mock.ExpectQuery("^UPDATE posts SET title = \\$2, content = \\$3, edited_at = \\$4, updated_at = \\$4 WHERE id = \\$1 RETURNING (.+)$").WithArgs(1, "New title", "New content", editedAt).
+		WillReturnRows(postRows)
+	mock.ExpectCommit()
+
+	post, err := ps.UpdatePost(1, "New title", "New content", []string{}, "Editor")
+	if err != nil {
+		t.Fatalf("error was not expected while updating post: %s", err)
+	}
+
+	if post.ID != 1 || post.Title != "New title" || post.Content != "New content" || post.EditedAt == nil || !post.EditedAt.Equal(editedAt) {
 		t.Errorf("unexpected values in post: %+v", post)
 	}
+
+	mock.ExpectBegin()
+	mock.ExpectQuery("^SELECT title, content FROM posts (.+) FOR UPDATE$").WithArgs(2).WillReturnError(sql.ErrNoRows)
+	mock.ExpectRollback()
+
+	if _, err := ps.UpdatePost(2, "New title", "New content", nil, "Editor"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected %v, got %v", ErrNotFound, err)
+	}
 	if err := mock.ExpectationsWereMet(); err != nil {
 		t.Errorf("there were unfulfilled expectations: %s", err)
 	}
@@ -302,31 +585,438 @@ func TestUpdateComment(t *testing.T) {
 	defer db.Close()
 
 	ps := NewPostgresStore(db)
+	editedAt := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	ps.now = func() time.Time { return editedAt }
+
+	mock.ExpectBegin()
+	mock.ExpectQuery("^SELECT c.content FROM comments c JOIN posts p ON p.id = c.post_id\\s+WHERE c.id = \\$1 AND NOT c.is_deleted AND NOT p.is_deleted FOR UPDATE OF c$").WithArgs(1).
+		WillReturnRows(sqlmock.NewRows([]string{"content"}).AddRow("Old content"))
+	mock.ExpectExec("^INSERT INTO comment_revisions (.+) FROM comment_revisions WHERE comment_id = \\$1$").WithArgs(1, "Old content", "Editor", editedAt).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("^UPDATE comments SET content = \\$2, edited_at = \\$3, updated_at = \\$3 WHERE id = \\$1$").WithArgs(1, "New content", editedAt).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	commentRows := sqlmock.NewRows([]string{"id", "post_id", "author", "content", "parent_id", "is_deleted", "reply_count", "edited_at", "created_at", "updated_at", "upvotes", "downvotes", "is_hidden", "hidden_reply_count"}).AddRow(1, 1, "Test author", "New content", nil, false, 0, editedAt, time.Time{}, editedAt, 0, 0, false, 0)
+	mock.ExpectQuery("^SELECT (.+) FROM comments c JOIN posts p ON p.id = c.post_id WHERE c.id = \\$1").WithArgs(1).WillReturnRows(commentRows)
+
+	comment, err := ps.UpdateComment(1, "New content", "Editor")
+	if err != nil {
+		t.Fatalf("error was not expected while updating comment: %s", err)
+	}
 
-	mock.ExpectExec("UPDATE comments SET content = \\$1 WHERE id = \\$2").WithArgs("New content", 1).WillReturnResult(sqlmock.NewResult(1, 1))
+	if comment.ID != 1 || comment.Content != "New content" || comment.Author != "Test author" || comment.EditedAt == nil {
+		t.Errorf("unexpected values in comment: %+v", comment)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
 
-	commentRows := sqlmock.NewRows([]string{"id", "post_id", "content", "author", "parent_id"}).AddRow(1, 1, "Test author", "New content", nil)
-	mock.ExpectQuery("^SELECT (.+) FROM comments WHERE id = \\$1").WithArgs(1).WillReturnRows(commentRows)
+func TestGetPostRevisions(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
 
-	comment, err := ps.UpdateComment(1, "New content")
+	ps := NewPostgresStore(db)
+	editedAt := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	mock.ExpectQuery("^SELECT COUNT\\(\\*\\) FROM post_revisions WHERE post_id = \\$1$").WithArgs(1).
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(3))
+	rows := sqlmock.NewRows([]string{"number", "title", "content", "editor", "edited_at"}).
+		AddRow(2, "Title 2", "Content 2", "alice", editedAt).
+		AddRow(3, "Title 3", "Content 3", "bob", editedAt)
+	mock.ExpectQuery("^SELECT number, title, content, editor, edited_at FROM post_revisions (.+) ORDER BY number LIMIT \\$3$").WithArgs(1, 1, 2).
+		WillReturnRows(rows)
+
+	revisions, err := ps.GetPostRevisions(1, 1, encodeCursor(1))
 	if err != nil {
-		t.Errorf("error was not expected while updating comment: %s", err)
+		t.Fatalf("error was not expected while getting revisions: %s", err)
 	}
 
-	if comment == nil {
-		t.Errorf("comment should not be nil")
-		return
+	if revisions.TotalCount != 3 || len(revisions.Edges) != 1 || !revisions.PageInfo.HasNextPage {
+		t.Errorf("unexpected page: %+v", revisions)
+	}
+	if r := revisions.Edges[0].Node; r.Number != 2 || r.Title == nil || *r.Title != "Title 2" || r.Editor != "alice" {
+		t.Errorf("unexpected revision: %+v", r)
 	}
 
-	if comment.ID != 1 || comment.Content != "New content" || comment.Author != "Test author" {
-		t.Errorf("unexpected values in comment: %+v", comment)
+	mock.ExpectQuery("^SELECT number, NULL, content, editor, edited_at FROM comment_revisions WHERE comment_id = \\$1 AND number = \\$2$").WithArgs(1, 5).
+		WillReturnError(sql.ErrNoRows)
+
+	if _, err := ps.GetCommentRevision(1, 5); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected %v, got %v", ErrNotFound, err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestSetCommentsEnabled(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	ps := NewPostgresStore(db)
+
+	rows := sqlmock.NewRows([]string{"id", "title", "content", "comments_enabled", "author", "comments_lock_reason", "auto_lock_after_days", "edited_at", "created_at", "updated_at", "upvotes", "downvotes", "status", "publish_at"}).
+		AddRow(1, "Test title", "Test content", false, "Test author", "MANUAL", 7, nil, time.Time{}, time.Time{}, 0, 0, "PUBLISHED", time.Time{})
+	mock.ExpectQuery("^UPDATE posts SET (.+) WHERE id = \\$1 AND NOT is_deleted RETURNING (.+)$").WithArgs(1, false, sqlmock.AnyArg()).WillReturnRows(rows)
+
+	post, err := ps.SetCommentsEnabled(1, false)
+	if err != nil {
+		t.Fatalf("error was not expected while disabling comments: %s", err)
+	}
+	if post.CommentsEnabled || post.CommentsLockReason == nil || *post.CommentsLockReason != model.CommentLockReasonManual || post.AutoLockAfterDays == nil || *post.AutoLockAfterDays != 7 {
+		t.Errorf("unexpected values in post: %+v", post)
+	}
+
+	mock.ExpectQuery("^UPDATE posts SET (.+) WHERE id = \\$1 AND NOT is_deleted RETURNING (.+)$").WithArgs(2, true, sqlmock.AnyArg()).WillReturnError(sql.ErrNoRows)
+
+	var notFound *NotFoundError
+	if _, err := ps.SetCommentsEnabled(2, true); !errors.As(err, &notFound) || notFound.Entity != "post" || notFound.ID != 2 {
+		t.Errorf("expected post 2 not found, got %v", err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestSetAutoLock(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	ps := NewPostgresStore(db)
+	days := 7
+
+	rows := sqlmock.NewRows([]string{"id", "title", "content", "comments_enabled", "author", "comments_lock_reason", "auto_lock_after_days", "edited_at", "created_at", "updated_at", "upvotes", "downvotes", "status", "publish_at"}).
+		AddRow(1, "Test title", "Test content", true, "Test author", nil, 7, nil, time.Time{}, time.Time{}, 0, 0, "PUBLISHED", time.Time{})
+	mock.ExpectQuery("^UPDATE posts SET auto_lock_after_days = \\$2, updated_at = \\$3 WHERE id = \\$1 AND NOT is_deleted RETURNING (.+)$").WithArgs(1, &days, sqlmock.AnyArg()).WillReturnRows(rows)
+
+	post, err := ps.SetAutoLock(1, &days)
+	if err != nil {
+		t.Fatalf("error was not expected while setting auto-lock: %s", err)
+	}
+	if post.AutoLockAfterDays == nil || *post.AutoLockAfterDays != 7 || post.CommentsLockReason != nil {
+		t.Errorf("unexpected values in post: %+v", post)
+	}
+
+	if _, err := ps.SetAutoLock(1, new(int)); !errors.Is(err, ErrInvalidArgument) {
+		t.Errorf("expected %v, got %v", ErrInvalidArgument, err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestLockExpiredPosts(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	ps := NewPostgresStore(db)
+	now := time.Date(2024, 1, 8, 0, 0, 0, 0, time.UTC)
+
+	mock.ExpectQuery("^UPDATE posts SET comments_enabled = FALSE, comments_lock_reason = 'AUTO', updated_at = \\$1 (.+) RETURNING id$").WithArgs(now).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1).AddRow(4))
+
+	locked, err := ps.LockExpiredPosts(now)
+	if err != nil {
+		t.Fatalf("error was not expected while locking posts: %s", err)
+	}
+	if len(locked) != 2 || locked[0] != 1 || locked[1] != 4 {
+		t.Errorf("unexpected locked posts: %v", locked)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestPublishPost(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	ps := NewPostgresStore(db)
+	now := time.Date(2024, 1, 8, 0, 0, 0, 0, time.UTC)
+	ps.now = func() time.Time { return now }
+	publishAt := now.Add(time.Hour)
+
+	mock.ExpectBegin()
+	mock.ExpectQuery("^SELECT status FROM posts WHERE id = \\$1 AND NOT is_deleted FOR UPDATE$").WithArgs(1).
+		WillReturnRows(sqlmock.NewRows([]string{"status"}).AddRow("DRAFT"))
+	rows := sqlmock.NewRows([]string{"id", "title", "content", "comments_enabled", "author", "comments_lock_reason", "auto_lock_after_days", "edited_at", "created_at", "updated_at", "upvotes", "downvotes", "status", "publish_at"}).
+		AddRow(1, "Test title", "Test content", true, "Test author", nil, nil, nil, time.Time{}, now, 0, 0, "SCHEDULED", publishAt)
+	mock.ExpectQuery("^UPDATE posts SET status = \\$2, publish_at = \\$3, updated_at = \\$4 WHERE id = \\$1 RETURNING (.+)$").
+		WithArgs(1, model.PostStatusScheduled, publishAt, now).WillReturnRows(rows)
+	mock.ExpectCommit()
+
+	post, err := ps.PublishPost(1, &publishAt)
+	if err != nil {
+		t.Fatalf("error was not expected while publishing post: %s", err)
+	}
+	if post.Status != model.PostStatusScheduled || post.PublishAt == nil || !post.PublishAt.Equal(publishAt) {
+		t.Errorf("unexpected post: %+v", post)
+	}
+
+	// Уже опубликованный пост повторно не публикуется
+	mock.ExpectBegin()
+	mock.ExpectQuery("^SELECT status FROM posts (.+) FOR UPDATE$").WithArgs(2).
+		WillReturnRows(sqlmock.NewRows([]string{"status"}).AddRow("PUBLISHED"))
+	mock.ExpectRollback()
+
+	if _, err := ps.PublishPost(2, nil); !errors.Is(err, ErrInvalidArgument) {
+		t.Errorf("expected %v, got %v", ErrInvalidArgument, err)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestPublishScheduledPosts(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	ps := NewPostgresStore(db)
+	now := time.Date(2024, 1, 8, 0, 0, 0, 0, time.UTC)
+
+	mock.ExpectQuery("^UPDATE posts SET status = 'PUBLISHED', updated_at = \\$1\\s+WHERE status = 'SCHEDULED' AND NOT is_deleted AND publish_at <= \\$1\\s+RETURNING id$").
+		WithArgs(now).WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(3))
+
+	published, err := ps.PublishScheduledPosts(now)
+	if err != nil {
+		t.Fatalf("error was not expected while publishing posts: %s", err)
+	}
+	if len(published) != 1 || published[0] != 3 {
+		t.Errorf("unexpected published posts: %v", published)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestDeleteComment(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	ps := NewPostgresStore(db)
+
+	// Удаляется последний ответ: скрываются и он, и удалённый ранее родитель
+	mock.ExpectBegin()
+	mock.ExpectExec("^UPDATE comments SET is_deleted = TRUE, author = '', content = '', updated_at = \\$2\\s+WHERE id = \\$1 AND NOT is_deleted AND post_id IN \\(SELECT id FROM posts WHERE NOT is_deleted\\)$").WithArgs(2, sqlmock.AnyArg()).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("^DELETE FROM comment_revisions WHERE comment_id = \\$1$").WithArgs(2).WillReturnResult(sqlmock.NewResult(0, 2))
+	mock.ExpectExec("^DELETE FROM comment_reactions WHERE comment_id = \\$1$").WithArgs(2).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectQuery("^UPDATE comments c SET is_pruned = TRUE (.+) RETURNING c.parent_id$").WithArgs(2).WillReturnRows(sqlmock.NewRows([]string{"parent_id"}).AddRow(1))
+	mock.ExpectQuery("^UPDATE comments c SET is_pruned = TRUE (.+) RETURNING c.parent_id$").WithArgs(1).WillReturnRows(sqlmock.NewRows([]string{"parent_id"}).AddRow(nil))
+	mock.ExpectCommit()
+
+	if err := ps.DeleteComment(2); err != nil {
+		t.Errorf("error was not expected while deleting comment: %s", err)
+	}
+
+	mock.ExpectBegin()
+	mock.ExpectExec("^UPDATE comments SET is_deleted = TRUE(.+)$").WithArgs(3, sqlmock.AnyArg()).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectRollback()
+
+	if err := ps.DeleteComment(3); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected %v, got %v", ErrNotFound, err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestDeleteAndPurgePost(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	ps := NewPostgresStore(db)
+
+	mock.ExpectExec("^UPDATE posts SET is_deleted = TRUE WHERE id = \\$1 AND NOT is_deleted$").WithArgs(1).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("^UPDATE posts SET is_deleted = TRUE WHERE id = \\$1 AND NOT is_deleted$").WithArgs(2).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec("^DELETE FROM posts WHERE id = \\$1$").WithArgs(1).WillReturnResult(sqlmock.NewResult(0, 1))
+
+	if err := ps.DeletePost(1); err != nil {
+		t.Errorf("error was not expected while deleting post: %s", err)
+	}
+	if err := ps.DeletePost(2); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected %v, got %v", ErrNotFound, err)
+	}
+	if err := ps.PurgePost(1); err != nil {
+		t.Errorf("error was not expected while purging post: %s", err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestVote(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	ps := NewPostgresStore(db)
+
+	// Голос против заменяет прежний голос за
+	mock.ExpectBegin()
+	mock.ExpectQuery("^SELECT c.upvotes, c.downvotes, p.status, p.author FROM comments c JOIN posts p ON p.id = c.post_id\\s+WHERE c.id = \\$1 AND NOT c.is_deleted AND NOT p.is_deleted FOR UPDATE OF c$").WithArgs(1).
+		WillReturnRows(sqlmock.NewRows([]string{"upvotes", "downvotes", "status", "author"}).AddRow(3, 1, "PUBLISHED", "bob"))
+	mock.ExpectQuery("^SELECT value FROM comment_votes WHERE comment_id = \\$1 AND user_id = \\$2$").WithArgs(1, "alice").
+		WillReturnRows(sqlmock.NewRows([]string{"value"}).AddRow(1))
+	mock.ExpectExec("^INSERT INTO comment_votes (.+) ON CONFLICT \\(comment_id, user_id\\) DO UPDATE SET value = EXCLUDED.value$").WithArgs(1, "alice", -1).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("^UPDATE comments SET upvotes = \\$2, downvotes = \\$3, best = \\$4, controversy = \\$5 WHERE id = \\$1$").
+		WithArgs(1, 2, 2, wilson(2, 2), controversy(2, 2)).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	result, err := ps.Vote(model.VoteTargetComment, 1, "alice", -1)
+	if err != nil {
+		t.Fatalf("error was not expected while voting: %s", err)
+	}
+	if result.Score != 0 || result.Upvotes != 2 || result.Downvotes != 2 || result.MyVote != -1 {
+		t.Errorf("unexpected vote result: %+v", result)
+	}
+
+	// Отзыв голоса за пост, за который пользователь не голосовал
+	mock.ExpectBegin()
+	mock.ExpectQuery("^SELECT upvotes, downvotes, status, author FROM posts WHERE id = \\$1 AND NOT is_deleted FOR UPDATE$").WithArgs(2).
+		WillReturnRows(sqlmock.NewRows([]string{"upvotes", "downvotes", "status", "author"}).AddRow(1, 0, "PUBLISHED", "bob"))
+	mock.ExpectQuery("^SELECT value FROM post_votes (.+)$").WithArgs(2, "alice").WillReturnError(sql.ErrNoRows)
+	mock.ExpectExec("^DELETE FROM post_votes WHERE post_id = \\$1 AND user_id = \\$2$").WithArgs(2, "alice").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec("^UPDATE posts SET upvotes = \\$2, downvotes = \\$3 WHERE id = \\$1$").WithArgs(2, 1, 0).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	if result, err := ps.Vote(model.VoteTargetPost, 2, "alice", 0); err != nil || result.Score != 1 {
+		t.Errorf("unexpected vote result: %+v, %v", result, err)
+	}
+
+	mock.ExpectBegin()
+	mock.ExpectQuery("^SELECT upvotes, downvotes, status, author FROM posts (.+) FOR UPDATE$").WithArgs(3).WillReturnError(sql.ErrNoRows)
+	mock.ExpectRollback()
+
+	if _, err := ps.Vote(model.VoteTargetPost, 3, "alice", 1); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected %v, got %v", ErrNotFound, err)
+	}
+
+	// Голосовать за чужой черновик нельзя, как и за несуществующий пост
+	mock.ExpectBegin()
+	mock.ExpectQuery("^SELECT upvotes, downvotes, status, author FROM posts (.+) FOR UPDATE$").WithArgs(4).
+		WillReturnRows(sqlmock.NewRows([]string{"upvotes", "downvotes", "status", "author"}).AddRow(0, 0, "DRAFT", "bob"))
+	mock.ExpectRollback()
+
+	if _, err := ps.Vote(model.VoteTargetPost, 4, "alice", 1); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected %v, got %v", ErrNotFound, err)
+	}
+
+	// Комментарий удалённого поста не находится запросом блокировки
+	mock.ExpectBegin()
+	mock.ExpectQuery("^SELECT c.upvotes, (.+) AND NOT p.is_deleted FOR UPDATE OF c$").WithArgs(5).WillReturnError(sql.ErrNoRows)
+	mock.ExpectRollback()
+
+	if _, err := ps.Vote(model.VoteTargetComment, 5, "alice", 1); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected %v, got %v", ErrNotFound, err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestReactions(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	ps := NewPostgresStore(db)
+	now := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	ps.now = func() time.Time { return now }
+
+	mock.ExpectBegin()
+	mock.ExpectQuery("^SELECT p.status, p.author FROM comments c JOIN posts p ON p.id = c.post_id\\s+WHERE c.id = \\$1 AND NOT c.is_deleted AND NOT p.is_deleted FOR SHARE OF c$").WithArgs(1).
+		WillReturnRows(sqlmock.NewRows([]string{"status", "author"}).AddRow("PUBLISHED", "bob"))
+	mock.ExpectExec("^INSERT INTO comment_reactions (.+) ON CONFLICT \\(comment_id, user_id, emoji\\) DO NOTHING$").
+		WithArgs(1, "alice", "👍", now).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectQuery("^SELECT comment_id, emoji, COUNT\\(\\*\\), BOOL_OR\\(user_id = \\$2\\) FROM comment_reactions (.+) ORDER BY comment_id, MIN\\(created_at\\), emoji$").
+		WithArgs(sqlmock.AnyArg(), "alice").
+		WillReturnRows(sqlmock.NewRows([]string{"comment_id", "emoji", "count", "reacted"}).AddRow(1, "🎉", 2, false).AddRow(1, "👍", 1, true))
+	mock.ExpectCommit()
+
+	reactions, err := ps.AddReaction(1, "alice", "👍")
+	if err != nil {
+		t.Fatalf("error was not expected while adding reaction: %s", err)
+	}
+	if len(reactions) != 2 || reactions[0].Emoji != "🎉" || reactions[0].ReactedByMe || reactions[1].Count != 1 || !reactions[1].ReactedByMe {
+		t.Errorf("unexpected reactions: %+v, %+v", reactions[0], reactions[1])
+	}
+
+	// После снятия последней реакции возвращается пустой список
+	mock.ExpectBegin()
+	mock.ExpectQuery("^SELECT p.status, p.author FROM comments c (.+) FOR SHARE OF c$").WithArgs(2).
+		WillReturnRows(sqlmock.NewRows([]string{"status", "author"}).AddRow("PUBLISHED", "bob"))
+	mock.ExpectExec("^DELETE FROM comment_reactions WHERE comment_id = \\$1 AND user_id = \\$2 AND emoji = \\$3$").
+		WithArgs(2, "alice", "👍").WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectQuery("^SELECT comment_id, emoji, (.+)$").WithArgs(sqlmock.AnyArg(), "alice").
+		WillReturnRows(sqlmock.NewRows([]string{"comment_id", "emoji", "count", "reacted"}))
+	mock.ExpectCommit()
+
+	if reactions, err := ps.RemoveReaction(2, "alice", "👍"); err != nil || reactions == nil || len(reactions) != 0 {
+		t.Errorf("expected no reactions, got %v, %v", reactions, err)
+	}
+
+	mock.ExpectBegin()
+	mock.ExpectQuery("^SELECT p.status, p.author FROM comments c (.+) FOR SHARE OF c$").WithArgs(3).WillReturnError(sql.ErrNoRows)
+	mock.ExpectRollback()
+
+	if _, err := ps.AddReaction(3, "alice", "👍"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected %v, got %v", ErrNotFound, err)
+	}
+
+	mock.ExpectBegin()
+	mock.ExpectQuery("^SELECT p.status, p.author FROM comments c (.+) FOR SHARE OF c$").WithArgs(4).
+		WillReturnRows(sqlmock.NewRows([]string{"status", "author"}).AddRow("DRAFT", "bob"))
+	mock.ExpectRollback()
+
+	if _, err := ps.AddReaction(4, "alice", "👍"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected %v, got %v", ErrNotFound, err)
+	}
+
+	mock.ExpectBegin()
+	mock.ExpectQuery("^SELECT p.status, (.+) AND NOT p.is_deleted FOR SHARE OF c$").WithArgs(5).WillReturnError(sql.ErrNoRows)
+	mock.ExpectRollback()
+
+	if _, err := ps.AddReaction(5, "alice", "👍"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected %v for a comment of a deleted post, got %v", ErrNotFound, err)
 	}
 	if err := mock.ExpectationsWereMet(); err != nil {
 		t.Errorf("there were unfulfilled expectations: %s", err)
 	}
 }
 
-func TestDisableComments(t *testing.T) {
+func TestSearchPosts(t *testing.T) {
 	db, mock, err := sqlmock.New()
 	if err != nil {
 		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
@@ -334,13 +1024,240 @@ func TestDisableComments(t *testing.T) {
 	defer db.Close()
 
 	ps := NewPostgresStore(db)
+	after := encodeKeyCursor(position{id: 3, key: 0.5})
+
+	mock.ExpectQuery("^SELECT COUNT\\(\\*\\) FROM posts, \\(SELECT websearch_to_tsquery\\('russian', \\$1\\) AS q\\) query WHERE NOT is_deleted AND status = 'PUBLISHED' AND search @@ q$").
+		WithArgs("ёлка").WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(3))
+	rows := sqlmock.NewRows([]string{"id", "title", "content", "comments_enabled", "author", "comments_lock_reason", "auto_lock_after_days", "edited_at", "created_at", "updated_at", "upvotes", "downvotes", "status", "publish_at", "rank", "snippet"}).
+		AddRow(4, "Ёлка", "Праздничная ёлка", true, "Author", nil, nil, nil, time.Time{}, time.Time{}, 0, 0, "PUBLISHED", time.Time{}, 0.25, "Праздничная <mark>ёлка</mark>").
+		AddRow(5, "Парк", "Ёлка в парке", true, "Author", nil, nil, nil, time.Time{}, time.Time{}, 0, 0, "PUBLISHED", time.Time{}, 0.1, "<mark>Ёлка</mark> в парке")
+	mock.ExpectQuery("ts_headline\\('russian', replace\\((.+)\\(content, '&', '&amp;'\\)(.+), q, (.+) ts_rank\\(search, q\\)::float8 AS rank (.+) WHERE \\(rank < \\$2 OR rank = \\$2 AND id > \\$3\\) ORDER BY rank DESC, id ASC LIMIT \\$4$").
+		WithArgs("ёлка", 0.5, 3, 2).WillReturnRows(rows)
 
-	mock.ExpectExec("UPDATE posts SET comments_enabled = false WHERE id = \\$1").WithArgs(1).WillReturnResult(sqlmock.NewResult(1, 1))
+	posts, err := ps.SearchPosts("ёлка", 1, after)
+	if err != nil {
+		t.Fatalf("error was not expected while searching posts: %s", err)
+	}
+	if posts.TotalCount != 3 || len(posts.Edges) != 1 || !posts.PageInfo.HasNextPage {
+		t.Fatalf("unexpected search result: %+v", posts)
+	}
+
+	edge := posts.Edges[0]
+	if edge.Node.ID != 4 || edge.Rank != 0.25 || edge.Snippet != "Праздничная <mark>ёлка</mark>" || edge.Cursor != encodeKeyCursor(position{id: 4, key: 0.25}) {
+		t.Errorf("unexpected edge: %+v", edge)
+	}
 
-	err = ps.DisableComments(1)
+	if _, err := ps.SearchPosts("  ", 10, ""); !errors.Is(err, ErrInvalidArgument) {
+		t.Errorf("expected %v, got %v", ErrInvalidArgument, err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestSearchComments(t *testing.T) {
+	db, mock, err := sqlmock.New()
 	if err != nil {
-		t.Errorf("error was not expected while disabling comments: %s", err)
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
 	}
+	defer db.Close()
+
+	ps := NewPostgresStore(db)
+	postID := 1
+
+	mock.ExpectQuery("^SELECT COUNT\\(\\*\\) FROM comments, (.+) WHERE NOT is_deleted AND NOT is_pruned AND NOT is_hidden AND search @@ q AND post_id IN \\(SELECT id FROM posts WHERE NOT is_deleted\\) AND NOT EXISTS \\((.+)WITH RECURSIVE ancestors (.+) SELECT 1 FROM ancestors WHERE is_hidden\\s+\\) AND post_id = \\$2$").
+		WithArgs("ёлка", 1).WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(1))
+	rows := sqlmock.NewRows([]string{"id", "post_id", "author", "content", "parent_id", "is_deleted", "reply_count", "edited_at", "created_at", "updated_at", "upvotes", "downvotes", "is_hidden", "hidden_reply_count", "rank", "snippet"}).
+		AddRow(7, 1, "Author", "Красивая ёлка", nil, false, 0, nil, time.Time{}, time.Time{}, 0, 0, false, 0, 0.1, "Красивая <mark>ёлка</mark>")
+	mock.ExpectQuery("AND post_id = \\$2\\s+\\) c WHERE TRUE ORDER BY c.rank DESC, c.id ASC LIMIT \\$3$").
+		WithArgs("ёлка", 1, 11).WillReturnRows(rows)
+
+	comments, err := ps.SearchComments(&postID, "ёлка", 10, "")
+	if err != nil {
+		t.Fatalf("error was not expected while searching comments: %s", err)
+	}
+	if comments.TotalCount != 1 || len(comments.Edges) != 1 || comments.Edges[0].Node.ID != 7 || comments.PageInfo.HasNextPage {
+		t.Errorf("unexpected search result: %+v", comments)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestGetCommentsSorted(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	ps := NewPostgresStore(db)
+	order, _ := orderFor(model.CommentSortTop)
+	after := order.cursor(&model.Comment{ID: 3, Score: 5})
+
+	mock.ExpectQuery("^SELECT post_id, COUNT\\(\\*\\), COUNT\\(\\*\\) FILTER \\(WHERE \\(\\(upvotes - downvotes\\) < \\$2 OR \\(upvotes - downvotes\\) = \\$2 AND id > \\$3\\)\\) FROM comments (.+)$").
+		WithArgs(sqlmock.AnyArg(), 5.0, 3).WillReturnRows(sqlmock.NewRows([]string{"post_id", "count", "remaining"}).AddRow(1, 3, 1))
+	rows := sqlmock.NewRows([]string{"id", "post_id", "author", "content", "parent_id", "is_deleted", "reply_count", "edited_at", "created_at", "updated_at", "upvotes", "downvotes", "is_hidden", "hidden_reply_count"}).
+		AddRow(4, 1, "Comment author", "Comment content", nil, false, 0, nil, time.Time{}, time.Time{}, 3, 1, false, 0)
+	mock.ExpectQuery("ORDER BY \\(upvotes - downvotes\\) DESC, id ASC\\) AS rn (.+) WHERE c.rn <= \\$4 ORDER BY c.post_id, c.rn$").
+		WithArgs(sqlmock.AnyArg(), 5.0, 3, 2).WillReturnRows(rows)
+
+	pages, err := ps.GetComments([]int{1}, 2, after, model.CommentSortTop, false)
+	if err != nil {
+		t.Fatalf("error was not expected while getting comments: %s", err)
+	}
+
+	page := pages[1]
+	if len(page.Edges) != 1 || page.Edges[0].Node.Score != 2 || page.PageInfo.HasNextPage {
+		t.Errorf("unexpected page: %+v", page)
+	}
+	if pos, _ := order.decodeCursor(*page.PageInfo.EndCursor); pos.id != 4 || pos.key != 2 {
+		t.Errorf("unexpected end cursor position: %+v", pos)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestReportComment(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	ps := NewPostgresStore(db)
+	now := time.Date(2024, 1, 8, 0, 0, 0, 0, time.UTC)
+	ps.now = func() time.Time { return now }
+	reportRows := []string{"id", "comment_id", "reporter", "reason", "status", "action", "resolved_by", "resolved_at", "created_at"}
+
+	mock.ExpectBegin()
+	mock.ExpectQuery("^SELECT c.id FROM comments c JOIN posts p ON p.id = c.post_id\\s+WHERE c.id = \\$1 AND NOT c.is_deleted AND NOT p.is_deleted FOR SHARE OF c$").WithArgs(1).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+	mock.ExpectQuery("^INSERT INTO comment_reports \\(comment_id, reporter, reason, created_at\\) (.+) ON CONFLICT \\(comment_id, reporter\\) DO NOTHING RETURNING (.+)$").
+		WithArgs(1, "alice", "spam", now).
+		WillReturnRows(sqlmock.NewRows(reportRows).AddRow(1, 1, "alice", "spam", "OPEN", nil, nil, nil, now))
+	mock.ExpectCommit()
+
+	report, err := ps.ReportComment(1, "alice", "spam")
+	if err != nil {
+		t.Fatalf("error was not expected while reporting comment: %s", err)
+	}
+	if report.ID != 1 || report.Status != model.ReportStatusOpen || report.Action != nil {
+		t.Errorf("unexpected report: %+v", report)
+	}
+
+	// Повторная жалоба ничего не вставляет
+	mock.ExpectBegin()
+	mock.ExpectQuery("^SELECT c.id FROM comments c (.+) FOR SHARE OF c$").WithArgs(1).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+	mock.ExpectQuery("^INSERT INTO comment_reports (.+)$").WithArgs(1, "alice", "spam", now).WillReturnRows(sqlmock.NewRows(reportRows))
+	mock.ExpectRollback()
+
+	if _, err := ps.ReportComment(1, "alice", "spam"); !errors.Is(err, ErrInvalidArgument) {
+		t.Errorf("expected %v, got %v", ErrInvalidArgument, err)
+	}
+
+	mock.ExpectQuery("^SELECT COUNT\\(\\*\\) FROM comment_reports WHERE status = \\$1$").WithArgs(model.ReportStatusOpen).
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(2))
+	mock.ExpectQuery("^SELECT (.+) FROM comment_reports WHERE status = \\$1 AND id > \\$2 ORDER BY id LIMIT \\$3$").WithArgs(model.ReportStatusOpen, 1, 2).
+		WillReturnRows(sqlmock.NewRows(reportRows).AddRow(2, 1, "bob", "abuse", "OPEN", nil, nil, nil, now))
+
+	queue, err := ps.GetReports(1, encodeCursor(1), model.ReportStatusOpen)
+	if err != nil {
+		t.Fatalf("error was not expected while getting reports: %s", err)
+	}
+	if queue.TotalCount != 2 || len(queue.Edges) != 1 || queue.Edges[0].Node.Reporter != "bob" || queue.PageInfo.HasNextPage {
+		t.Errorf("unexpected queue: %+v", queue)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestResolveReport(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	ps := NewPostgresStore(db)
+	now := time.Date(2024, 1, 8, 0, 0, 0, 0, time.UTC)
+	ps.now = func() time.Time { return now }
+
+	mock.ExpectBegin()
+	mock.ExpectQuery("^SELECT r.comment_id, c.post_id, r.status FROM comment_reports r JOIN comments c ON c.id = r.comment_id\\s+WHERE r.id = \\$1 FOR UPDATE OF r$").WithArgs(1).
+		WillReturnRows(sqlmock.NewRows([]string{"comment_id", "post_id", "status"}).AddRow(5, 2, "OPEN"))
+	mock.ExpectExec("^UPDATE comments SET is_hidden = TRUE WHERE id = \\$1 AND NOT is_deleted$").WithArgs(5).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("^UPDATE comment_reports SET status = \\$2, action = \\$3, resolved_by = \\$4, resolved_at = \\$5\\s+WHERE comment_id = \\$1 AND status = 'OPEN'$").
+		WithArgs(5, model.ReportStatusResolved, model.ReportActionHideComment, "carol", now).WillReturnResult(sqlmock.NewResult(0, 2))
+	mock.ExpectQuery("^SELECT (.+) FROM comment_reports WHERE id = \\$1$").WithArgs(1).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "comment_id", "reporter", "reason", "status", "action", "resolved_by", "resolved_at", "created_at"}).
+			AddRow(1, 5, "alice", "spam", "RESOLVED", "HIDE_COMMENT", "carol", now, now))
+	mock.ExpectCommit()
+
+	report, err := ps.ResolveReport(1, model.ReportActionHideComment, "carol")
+	if err != nil {
+		t.Fatalf("error was not expected while resolving report: %s", err)
+	}
+	if report.Status != model.ReportStatusResolved || report.Action == nil || *report.Action != model.ReportActionHideComment {
+		t.Errorf("unexpected report: %+v", report)
+	}
+
+	mock.ExpectBegin()
+	mock.ExpectQuery("^SELECT r.comment_id, (.+) FOR UPDATE OF r$").WithArgs(2).
+		WillReturnRows(sqlmock.NewRows([]string{"comment_id", "post_id", "status"}).AddRow(5, 2, "OPEN"))
+	mock.ExpectExec("^UPDATE posts SET comments_enabled = FALSE, comments_lock_reason = 'MANUAL', updated_at = \\$2\\s+WHERE id = \\$1 AND NOT is_deleted$").
+		WithArgs(2, now).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectRollback()
+
+	if _, err := ps.ResolveReport(2, model.ReportActionLockPost, "carol"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected %v for a deleted post, got %v", ErrNotFound, err)
+	}
+
+	mock.ExpectBegin()
+	mock.ExpectQuery("^SELECT r.comment_id, (.+) FOR UPDATE OF r$").WithArgs(3).WillReturnError(sql.ErrNoRows)
+	mock.ExpectRollback()
+
+	if _, err := ps.ResolveReport(3, model.ReportActionDismiss, "carol"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected %v, got %v", ErrNotFound, err)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestPersistedQueries(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	ps := NewPostgresStore(db)
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	ps.now = func() time.Time { return now }
+
+	mock.ExpectExec("^INSERT INTO persisted_queries \\(hash, query, created_at\\) VALUES \\(\\$1, \\$2, \\$3\\) ON CONFLICT \\(hash\\) DO NOTHING$").
+		WithArgs("abc", "{ posts { totalCount } }", now).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectQuery("^SELECT query FROM persisted_queries WHERE hash = \\$1$").WithArgs("abc").
+		WillReturnRows(sqlmock.NewRows([]string{"query"}).AddRow("{ posts { totalCount } }"))
+	mock.ExpectQuery("^SELECT query FROM persisted_queries WHERE hash = \\$1$").WithArgs("def").
+		WillReturnError(sql.ErrNoRows)
+
+	if err := ps.SavePersistedQuery("abc", "{ posts { totalCount } }"); err != nil {
+		t.Errorf("error was not expected while saving query: %s", err)
+	}
+	if query, err := ps.GetPersistedQuery("abc"); err != nil || query != "{ posts { totalCount } }" {
+		t.Errorf("unexpected query %q, %v", query, err)
+	}
+	if _, err := ps.GetPersistedQuery("def"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected %v, got %v", ErrNotFound, err)
+	}
+
 	if err := mock.ExpectationsWereMet(); err != nil {
 		t.Errorf("there were unfulfilled expectations: %s", err)
 	}
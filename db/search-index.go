@@ -0,0 +1,201 @@
+package db
+
+import (
+	"fmt"
+	"html"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// Веса вхождений слова в заголовок и текст, как у весов A и B в ts_rank
+const (
+	titleWeight   = 1.0
+	contentWeight = 0.4
+)
+
+// Сколько слов показывать во фрагменте найденного текста и сколько из них до первого найденного слова
+const (
+	snippetWords  = 35
+	snippetBefore = 5
+)
+
+// searchIndex - обратный индекс для in-memory хранилища: для каждого слова
+// хранит документы, в которых оно встречается, с суммарным весом вхождений
+type searchIndex struct {
+	postings map[string]map[int]float64
+	// Слова каждого документа, чтобы убрать их из индекса при изменении
+	words map[int][]string
+}
+
+func newSearchIndex() *searchIndex {
+	return &searchIndex{
+		postings: make(map[string]map[int]float64),
+		words:    make(map[int][]string),
+	}
+}
+
+// set заново индексирует документ id
+func (x *searchIndex) set(id int, title, content string) {
+	x.remove(id)
+
+	weights := make(map[string]float64)
+	for _, w := range tokenize(title) {
+		weights[w.text] += titleWeight
+	}
+	for _, w := range tokenize(content) {
+		weights[w.text] += contentWeight
+	}
+
+	words := make([]string, 0, len(weights))
+	for word, weight := range weights {
+		if x.postings[word] == nil {
+			x.postings[word] = make(map[int]float64)
+		}
+		x.postings[word][id] = weight
+		words = append(words, word)
+	}
+	x.words[id] = words
+}
+
+func (x *searchIndex) remove(id int) {
+	for _, word := range x.words[id] {
+		delete(x.postings[word], id)
+		if len(x.postings[word]) == 0 {
+			delete(x.postings, word)
+		}
+	}
+	delete(x.words, id)
+}
+
+// search возвращает документы, в которых есть все слова terms, с их релевантностью
+func (x *searchIndex) search(terms []string) []position {
+	terms = append([]string(nil), terms...)
+	// Начинаем с самого редкого слова, чтобы перебрать меньше документов
+	sort.Slice(terms, func(i, j int) bool { return len(x.postings[terms[i]]) < len(x.postings[terms[j]]) })
+
+	ranks := make(map[int]float64)
+	for id, weight := range x.postings[terms[0]] {
+		ranks[id] = weight
+	}
+	for _, term := range terms[1:] {
+		postings := x.postings[term]
+		for id := range ranks {
+			weight, ok := postings[id]
+			if !ok {
+				delete(ranks, id)
+				continue
+			}
+			ranks[id] += weight
+		}
+	}
+
+	hits := make([]position, 0, len(ranks))
+	for id, rank := range ranks {
+		hits = append(hits, position{id: id, key: rank})
+	}
+	return hits
+}
+
+// word - слово текста и его границы в байтах
+type word struct {
+	text       string
+	start, end int
+}
+
+// tokenize разбивает текст на слова в нижнем регистре. Словом считается
+// последовательность букв и цифр, ё не отличается от е.
+func tokenize(text string) []word {
+	var words []word
+	start := -1
+	for i, r := range text + " " {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			if start < 0 {
+				start = i
+			}
+			continue
+		}
+		if start >= 0 {
+			words = append(words, word{text: normalizeWord(text[start:i]), start: start, end: i})
+			start = -1
+		}
+	}
+	return words
+}
+
+func normalizeWord(w string) string {
+	return strings.ReplaceAll(strings.ToLower(w), "ё", "е")
+}
+
+// searchTerms возвращает различные слова поискового запроса
+func searchTerms(query string) ([]string, error) {
+	seen := make(map[string]bool)
+	var terms []string
+	for _, w := range tokenize(query) {
+		if !seen[w.text] {
+			seen[w.text] = true
+			terms = append(terms, w.text)
+		}
+	}
+
+	if len(terms) == 0 {
+		return nil, fmt.Errorf("%w: search query is empty", ErrInvalidArgument)
+	}
+	return terms, nil
+}
+
+// snippet возвращает фрагмент текста, начинающийся незадолго до первого
+// найденного слова, и выделяет найденные слова тегами <mark>, как ts_headline
+func snippet(text string, terms []string) string {
+	words := tokenize(text)
+	if len(words) == 0 {
+		return ""
+	}
+
+	matched := make(map[string]bool, len(terms))
+	for _, term := range terms {
+		matched[term] = true
+	}
+
+	first := 0
+	for i, w := range words {
+		if matched[w.text] {
+			first = i
+			break
+		}
+	}
+
+	from := max(first-snippetBefore, 0)
+	to := min(from+snippetWords, len(words))
+
+	// Текст экранируется, теги <mark> - единственная разметка во фрагменте
+	var b strings.Builder
+	pos := words[from].start
+	for _, w := range words[from:to] {
+		if !matched[w.text] {
+			continue
+		}
+		b.WriteString(html.EscapeString(text[pos:w.start]))
+		b.WriteString("<mark>")
+		b.WriteString(html.EscapeString(text[w.start:w.end]))
+		b.WriteString("</mark>")
+		pos = w.end
+	}
+	b.WriteString(html.EscapeString(text[pos:words[to-1].end]))
+
+	return b.String()
+}
+
+// searchPage сортирует результаты поиска по убыванию релевантности
+// и возвращает страницу после позиции after
+func searchPage(hits []position, first int, after *position) ([]position, bool) {
+	sort.Slice(hits, func(i, j int) bool { return rankedBefore(hits[i], hits[j]) })
+
+	start := 0
+	if after != nil {
+		start = sort.Search(len(hits), func(i int) bool { return rankedBefore(*after, hits[i]) })
+	}
+	end := min(start+first, len(hits))
+
+	return hits[start:end], end < len(hits)
+}
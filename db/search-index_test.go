@@ -0,0 +1,67 @@
+package db
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestSnippet(t *testing.T) {
+	tests := []struct {
+		text  string
+		terms []string
+		want  string
+	}{
+		{"Ёжик в тумане, ежик!", []string{"ежик"}, "<mark>Ёжик</mark> в тумане, <mark>ежик</mark>"},
+		{"No match here.", []string{"cat"}, "No match here"},
+		{"one two three four five six seven eight", []string{"eight"}, "three four five six seven <mark>eight</mark>"},
+		{"...", []string{"cat"}, ""},
+		{`<img src=x onerror=alert(1)> hello <b>world</b>`, []string{"world"}, `onerror=alert(1)&gt; hello &lt;b&gt;<mark>world</mark>&lt;/b`},
+	}
+
+	for _, tt := range tests {
+		if got := snippet(tt.text, tt.terms); got != tt.want {
+			t.Errorf("snippet(%q, %v) = %q, want %q", tt.text, tt.terms, got, tt.want)
+		}
+	}
+}
+
+func TestSearchIndex(t *testing.T) {
+	x := newSearchIndex()
+	x.set(1, "Go generics", "Generics in Go 1.18")
+	x.set(2, "", "Go is simple")
+	x.set(3, "Rust", "Nothing about the other language")
+
+	hits, _ := searchPage(x.search([]string{"go"}), 10, nil)
+	want := []position{{id: 1, key: titleWeight + contentWeight}, {id: 2, key: contentWeight}}
+	if !reflect.DeepEqual(hits, want) {
+		t.Errorf("unexpected hits: %v, want %v", hits, want)
+	}
+
+	// Найдены должны быть все слова запроса
+	if hits := x.search([]string{"go", "simple"}); len(hits) != 1 || hits[0].id != 2 {
+		t.Errorf("expected only document 2, got %v", hits)
+	}
+
+	x.set(2, "", "Changed text")
+	x.remove(1)
+	if hits := x.search([]string{"go"}); len(hits) != 0 {
+		t.Errorf("expected no hits after reindexing, got %v", hits)
+	}
+	if _, ok := x.postings["generics"]; ok {
+		t.Error("words of a removed document should leave the index")
+	}
+}
+
+func TestSearchPage(t *testing.T) {
+	hits := []position{{id: 3, key: 1}, {id: 1, key: 2}, {id: 2, key: 1}, {id: 4, key: 0.5}}
+
+	page, hasNext := searchPage(hits, 2, nil)
+	if !reflect.DeepEqual(page, []position{{id: 1, key: 2}, {id: 2, key: 1}}) || !hasNext {
+		t.Errorf("unexpected first page: %v, %v", page, hasNext)
+	}
+
+	page, hasNext = searchPage(hits, 2, &page[1])
+	if !reflect.DeepEqual(page, []position{{id: 3, key: 1}, {id: 4, key: 0.5}}) || hasNext {
+		t.Errorf("unexpected second page: %v, %v", page, hasNext)
+	}
+}
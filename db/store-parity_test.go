@@ -0,0 +1,131 @@
+package db
+
+import (
+	"PostCommentService/graph/model"
+	"database/sql"
+	"errors"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// parityStores возвращает хранилища, которые должны вести себя одинаково. PostgreSQL
+// проверяется, только если задан TEST_DATABASE_URL: тест применяет миграции
+// и очищает таблицы постов и комментариев этой базы.
+func parityStores(t *testing.T) map[string]Store {
+	stores := map[string]Store{"memory": NewMemoryStore()}
+
+	url := os.Getenv("TEST_DATABASE_URL")
+	if url == "" {
+		t.Log("TEST_DATABASE_URL is not set, only the memory store is checked")
+		return stores
+	}
+
+	conn, err := sql.Open("postgres", url)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	if _, err := MigrateUp(conn); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := conn.Exec("TRUNCATE posts, comments RESTART IDENTITY CASCADE"); err != nil {
+		t.Fatal(err)
+	}
+
+	stores["postgres"] = NewPostgresStore(conn)
+	return stores
+}
+
+// TestSearchParity выполняет одни и те же запросы в обоих хранилищах. Запросы
+// подобраны так, чтобы не зависеть от приведения слов к основе, которого
+// нет в in-memory индексе.
+func TestSearchParity(t *testing.T) {
+	tests := []struct {
+		query  string
+		titles []string
+	}{
+		{"garden", []string{"Garden party"}},
+		{"apple pie", []string{"Recipe"}},
+		{"apple garden", nil},
+		{"сад", []string{"Сад"}},
+		{"яблони", []string{"Сад"}},
+		{"banana", nil},
+	}
+
+	for name, store := range parityStores(t) {
+		store.CreatePost("Garden party", "Bring flowers to the garden", "Author", nil, model.PostStatusPublished, nil)
+		store.CreatePost("Сад", "Весной в саду цветут яблони", "Author", nil, model.PostStatusPublished, nil)
+		store.CreatePost("Recipe", "<b>Apple</b> pie with cinnamon", "Author", nil, model.PostStatusPublished, nil)
+		store.CreateComment(1, "Author", "Nice <i>garden</i>", nil)
+
+		for _, tt := range tests {
+			posts, err := store.SearchPosts(tt.query, 10, "")
+			if err != nil {
+				t.Fatalf("%s: error was not expected while searching %q: %s", name, tt.query, err)
+			}
+
+			var titles []string
+			for _, edge := range posts.Edges {
+				titles = append(titles, edge.Node.Title)
+				if strings.Contains(edge.Snippet, "<b>") {
+					t.Errorf("%s: snippet should be escaped, got %q", name, edge.Snippet)
+				}
+			}
+			if !reflect.DeepEqual(titles, tt.titles) {
+				t.Errorf("%s: %q found %v, want %v", name, tt.query, titles, tt.titles)
+			}
+		}
+
+		comments, err := store.SearchComments(nil, "garden", 10, "")
+		if err != nil || comments.TotalCount != 1 {
+			t.Fatalf("%s: expected one comment, got %+v, %v", name, comments, err)
+		}
+		if snippet := comments.Edges[0].Snippet; !strings.HasPrefix(snippet, "Nice &lt;i&gt;<mark>garden</mark>&lt;/i") {
+			t.Errorf("%s: unexpected comment snippet %q", name, snippet)
+		}
+	}
+}
+
+// TestDeletedPostParity проверяет, что вместе с постом недоступны и его комментарии
+func TestDeletedPostParity(t *testing.T) {
+	for name, store := range parityStores(t) {
+		post, _ := store.CreatePost("Title", "Content", "Author", nil, model.PostStatusPublished, nil)
+		comment, _ := store.CreateComment(post.ID, "Author", "Comment", nil)
+		if err := store.DeletePost(post.ID); err != nil {
+			t.Fatalf("%s: error was not expected while deleting post: %s", name, err)
+		}
+
+		calls := map[string]func() error{
+			"GetComment": func() error {
+				_, err := store.GetComment(comment.ID)
+				return err
+			},
+			"UpdateComment": func() error {
+				_, err := store.UpdateComment(comment.ID, "Edited", "Author")
+				return err
+			},
+			"DeleteComment": func() error {
+				return store.DeleteComment(comment.ID)
+			},
+			"ReportComment": func() error {
+				_, err := store.ReportComment(comment.ID, "alice", "spam")
+				return err
+			},
+			"Vote": func() error {
+				_, err := store.Vote(model.VoteTargetComment, comment.ID, "alice", 1)
+				return err
+			},
+			"AddReaction": func() error {
+				_, err := store.AddReaction(comment.ID, "alice", "👍")
+				return err
+			},
+		}
+		for call, fn := range calls {
+			if err := fn(); !errors.Is(err, ErrNotFound) {
+				t.Errorf("%s: %s: expected %v, got %v", name, call, ErrNotFound, err)
+			}
+		}
+	}
+}
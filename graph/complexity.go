@@ -0,0 +1,50 @@
+package graph
+
+import (
+	"PostCommentService/db"
+	"PostCommentService/graph/model"
+)
+
+// NewComplexity оценивает стоимость списков как стоимость одного элемента,
+// умноженную на запрошенный размер страницы. Так comments и child внутри
+// друг друга стоят столько, сколько комментариев может вернуть запрос.
+func NewComplexity() ComplexityRoot {
+	var c ComplexityRoot
+
+	c.Post.Comments = func(childComplexity int, first *int, after *string, sort *model.CommentSort) int {
+		return pageComplexity(childComplexity, first)
+	}
+	c.Comment.Child = func(childComplexity int, first *int, after *string, sort *model.CommentSort) int {
+		return pageComplexity(childComplexity, first)
+	}
+	c.Post.Revisions = func(childComplexity int, first *int, after *string) int {
+		return pageComplexity(childComplexity, first)
+	}
+	c.Comment.Revisions = func(childComplexity int, first *int, after *string) int {
+		return pageComplexity(childComplexity, first)
+	}
+	c.Query.Posts = func(childComplexity int, first *int, after *string, sort *model.PostSort, filter *model.PostFilter) int {
+		return pageComplexity(childComplexity, first)
+	}
+	c.Query.Tags = func(childComplexity int, first *int) int {
+		return pageComplexity(childComplexity, first)
+	}
+	c.Query.SearchPosts = func(childComplexity int, query string, first *int, after *string) int {
+		return pageComplexity(childComplexity, first)
+	}
+	c.Query.SearchComments = func(childComplexity int, postID *int, query string, first *int, after *string) int {
+		return pageComplexity(childComplexity, first)
+	}
+	c.Query.ModerationQueue = func(childComplexity int, first *int, after *string, status *model.ReportStatus) int {
+		return pageComplexity(childComplexity, first)
+	}
+
+	return c
+}
+
+// pageComplexity - стоимость самого поля плюс стоимость вложенных полей
+// для каждого элемента страницы. Размер страницы ограничен так же, как в хранилище.
+func pageComplexity(childComplexity int, first *int) int {
+	size := min(max(intValue(first), 0), db.MaxPageSize)
+	return 1 + childComplexity*size
+}
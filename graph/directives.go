@@ -0,0 +1,94 @@
+package graph
+
+import (
+	"PostCommentService/auth"
+	"PostCommentService/db"
+	"PostCommentService/graph/model"
+	"context"
+	"fmt"
+
+	"github.com/99designs/gqlgen/graphql"
+)
+
+// NewDirectives реализует директивы схемы @auth, @owner и @hasRole.
+// Проверки выполняются до резолвера. @auth и @hasRole смотрят только на пользователя,
+// а @owner для пользователя без роли модератора читает автора поста или комментария
+// из store, поэтому несуществующая цель даёт NOT_FOUND ещё до резолвера.
+func NewDirectives(store db.Store) DirectiveRoot {
+	return DirectiveRoot{
+		Auth: func(ctx context.Context, obj interface{}, next graphql.Resolver) (interface{}, error) {
+			if _, err := auth.RequireUser(ctx); err != nil {
+				return nil, err
+			}
+			return next(ctx)
+		},
+		Owner: func(ctx context.Context, obj interface{}, next graphql.Resolver, entity model.OwnedEntity, idArg string) (interface{}, error) {
+			user, err := auth.RequireUser(ctx)
+			if err != nil {
+				return nil, err
+			}
+
+			if !user.HasRole(auth.RoleModerator, auth.RoleAdmin) {
+				id, ok := graphql.GetFieldContext(ctx).Args[idArg].(int)
+				if !ok {
+					return nil, fmt.Errorf("@owner: argument %q must be Int!", idArg)
+				}
+
+				author, err := authorOf(store, entity, id)
+				if err != nil {
+					return nil, err
+				}
+				if !canManage(user, author) {
+					return nil, auth.ErrForbidden
+				}
+			}
+
+			return next(ctx)
+		},
+		HasRole: func(ctx context.Context, obj interface{}, next graphql.Resolver, role model.Role) (interface{}, error) {
+			user, err := auth.RequireUser(ctx)
+			if err != nil {
+				return nil, err
+			}
+
+			if !user.HasRole(rolesFor(role)...) {
+				return nil, auth.ErrForbidden
+			}
+
+			return next(ctx)
+		},
+	}
+}
+
+// canManage сообщает, может ли user менять сущность автора author
+func canManage(user *auth.User, author string) bool {
+	return user.ID == author || user.HasRole(auth.RoleModerator, auth.RoleAdmin)
+}
+
+// rolesFor возвращает роли токена, достаточные для роли схемы.
+// Администратор может всё, что может модератор.
+func rolesFor(role model.Role) []string {
+	if role == model.RoleModerator {
+		return []string{auth.RoleModerator, auth.RoleAdmin}
+	}
+	return []string{auth.RoleAdmin}
+}
+
+func authorOf(store db.Store, entity model.OwnedEntity, id int) (string, error) {
+	switch entity {
+	case model.OwnedEntityPost:
+		post, err := store.GetPost(id)
+		if err != nil {
+			return "", err
+		}
+		return post.Author, nil
+	case model.OwnedEntityComment:
+		comment, err := store.GetComment(id)
+		if err != nil {
+			return "", err
+		}
+		return comment.Author, nil
+	default:
+		return "", fmt.Errorf("@owner: unknown entity %s", entity)
+	}
+}
@@ -0,0 +1,132 @@
+package graph
+
+import (
+	"PostCommentService/auth"
+	"PostCommentService/db"
+	"PostCommentService/graph/loaders"
+	"PostCommentService/graph/model"
+	"PostCommentService/policy"
+	"PostCommentService/pubsub"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/99designs/gqlgen/client"
+	"github.com/99designs/gqlgen/graphql/handler"
+	"github.com/99designs/gqlgen/graphql/handler/transport"
+)
+
+// newTestClient возвращает клиент, выполняющий запросы от имени пользователя user
+func newTestClient(store db.Store, user *auth.User) *client.Client {
+	srv := handler.New(NewExecutableSchema(Config{
+		Resolvers:  NewResolver(store, pubsub.NewBroker(), DefaultReactions, policy.FromConfig(policy.DefaultConfig)),
+		Directives: NewDirectives(store),
+	}))
+	srv.AddTransport(transport.POST{})
+	srv.AroundOperations(loaders.Middleware(store))
+	srv.SetErrorPresenter(ErrorPresenter)
+
+	return client.New(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if user != nil {
+			r = r.WithContext(auth.WithUser(r.Context(), user))
+		}
+		srv.ServeHTTP(w, r)
+	}))
+}
+
+func TestOwnerDirective(t *testing.T) {
+	store := db.NewMemoryStore()
+	post, _ := store.CreatePost("Title", "Content", "alice", nil, model.PostStatusPublished, nil)
+	comment, _ := store.CreateComment(post.ID, "alice", "Content", nil)
+
+	tests := []struct {
+		name  string
+		user  *auth.User
+		query string
+		code  string
+	}{
+		{"anonymous", nil, `mutation { disableComments(postId: 1) { id } }`, CodeUnauthenticated},
+		{"stranger disables comments", &auth.User{ID: "bob"}, `mutation { disableComments(postId: 1) { id } }`, CodeForbidden},
+		{"stranger updates post", &auth.User{ID: "bob"}, `mutation { updatePost(id: 1, title: "T", content: "C") { id } }`, CodeForbidden},
+		{"stranger updates comment", &auth.User{ID: "bob"}, `mutation { updateComment(id: 1, content: "C") { id } }`, CodeForbidden},
+		{"missing post", &auth.User{ID: "bob"}, `mutation { updatePost(id: 42, title: "T", content: "C") { id } }`, CodeNotFound},
+		{"owner updates post", &auth.User{ID: "alice"}, `mutation { updatePost(id: 1, title: "T", content: "C") { id } }`, ""},
+		{"owner updates comment", &auth.User{ID: "alice"}, `mutation { updateComment(id: 1, content: "C") { id } }`, ""},
+		{"moderator disables comments", &auth.User{ID: "carol", Roles: []string{auth.RoleModerator}}, `mutation { disableComments(postId: 1) { id } }`, ""},
+	}
+
+	for _, tt := range tests {
+		var resp map[string]interface{}
+		err := newTestClient(store, tt.user).Post(tt.query, &resp)
+
+		if tt.code == "" {
+			if err != nil {
+				t.Errorf("%s: error was not expected: %s", tt.name, err)
+			}
+			continue
+		}
+		if err == nil || !strings.Contains(err.Error(), `"code":"`+tt.code+`"`) {
+			t.Errorf("%s: expected %s, got %v", tt.name, tt.code, err)
+		}
+	}
+
+	updated, _ := store.GetComment(comment.ID)
+	if updated.Content != "C" {
+		t.Errorf("comment should be updated by its author, got %q", updated.Content)
+	}
+}
+
+func TestAuthDirective(t *testing.T) {
+	store := db.NewMemoryStore()
+
+	var resp map[string]interface{}
+	err := newTestClient(store, nil).Post(`mutation { createPost(title: "T", content: "C") { id } }`, &resp)
+	if err == nil || !strings.Contains(err.Error(), CodeUnauthenticated) {
+		t.Errorf("expected %s, got %v", CodeUnauthenticated, err)
+	}
+
+	var created struct {
+		CreatePost struct{ Author string }
+	}
+	c := newTestClient(store, &auth.User{ID: "alice"})
+	if err := c.Post(`mutation { createPost(title: "T", content: "C") { author } }`, &created); err != nil {
+		t.Fatalf("error was not expected: %s", err)
+	}
+	if created.CreatePost.Author != "alice" {
+		t.Errorf("author should be taken from the token, got %q", created.CreatePost.Author)
+	}
+}
+
+func TestHasRoleDirective(t *testing.T) {
+	store := db.NewMemoryStore()
+	store.CreatePost("Title", "Content", "alice", nil, model.PostStatusPublished, nil)
+
+	tests := []struct {
+		name string
+		user *auth.User
+		code string
+	}{
+		{"author", &auth.User{ID: "alice"}, CodeForbidden},
+		{"moderator", &auth.User{ID: "carol", Roles: []string{auth.RoleModerator}}, CodeForbidden},
+		{"admin", &auth.User{ID: "dave", Roles: []string{auth.RoleAdmin}}, ""},
+	}
+
+	for _, tt := range tests {
+		var resp map[string]interface{}
+		err := newTestClient(store, tt.user).Post(`mutation { purgePost(id: 1) }`, &resp)
+
+		if tt.code == "" {
+			if err != nil {
+				t.Errorf("%s: error was not expected: %s", tt.name, err)
+			}
+			continue
+		}
+		if err == nil || !strings.Contains(err.Error(), `"code":"`+tt.code+`"`) {
+			t.Errorf("%s: expected %s, got %v", tt.name, tt.code, err)
+		}
+	}
+
+	if _, err := store.GetPost(1); err == nil {
+		t.Error("post should be purged by admin")
+	}
+}
@@ -0,0 +1,78 @@
+package graph
+
+import (
+	"PostCommentService/auth"
+	"PostCommentService/db"
+	"strings"
+	"testing"
+)
+
+func TestDrafts(t *testing.T) {
+	store := db.NewMemoryStore()
+	alice := newTestClient(store, &auth.User{ID: "alice"})
+	bob := newTestClient(store, &auth.User{ID: "bob"})
+
+	var created struct {
+		CreatePost struct {
+			ID        int
+			Status    string
+			PublishAt *string
+		}
+	}
+	if err := alice.Post(`mutation { createPost(title: "Title", content: "Content", status: DRAFT) { id status publishAt } }`, &created); err != nil {
+		t.Fatalf("error was not expected while creating draft: %s", err)
+	}
+	if created.CreatePost.Status != "DRAFT" || created.CreatePost.PublishAt != nil {
+		t.Errorf("unexpected draft: %+v", created.CreatePost)
+	}
+
+	// Некорректное время - ошибка клиента, а не сервера
+	var resp map[string]interface{}
+	err := alice.Post(`mutation { createPost(title: "Title", content: "Content", status: SCHEDULED, publishAt: "garbage") { id } }`, &resp)
+	if err == nil || !strings.Contains(err.Error(), CodeBadUserInput) || !strings.Contains(err.Error(), "RFC 3339") {
+		t.Errorf("expected %s, got %v", CodeBadUserInput, err)
+	}
+
+	// Черновик не виден другим пользователям и закрыт для комментариев
+	err = bob.Post(`{ post(id: 1) { id } }`, &resp)
+	if err == nil || !strings.Contains(err.Error(), CodeNotFound) {
+		t.Errorf("expected %s, got %v", CodeNotFound, err)
+	}
+	err = bob.Post(`mutation { createComment(postId: 1, content: "Comment") { id } }`, &resp)
+	if err == nil || !strings.Contains(err.Error(), CodeNotFound) {
+		t.Errorf("expected %s, got %v", CodeNotFound, err)
+	}
+	err = bob.Post(`mutation { vote(targetType: POST, targetId: 1, value: 1) { score } }`, &resp)
+	if err == nil || !strings.Contains(err.Error(), CodeNotFound) {
+		t.Errorf("expected %s, got %v", CodeNotFound, err)
+	}
+	err = alice.Post(`mutation { createComment(postId: 1, content: "Comment") { id } }`, &resp)
+	if err == nil || !strings.Contains(err.Error(), CodePostNotPublished) {
+		t.Errorf("expected %s, got %v", CodePostNotPublished, err)
+	}
+	err = bob.Post(`mutation { publishPost(id: 1) { id } }`, &resp)
+	if err == nil || !strings.Contains(err.Error(), CodeForbidden) {
+		t.Errorf("expected %s, got %v", CodeForbidden, err)
+	}
+
+	if err := alice.Post(`{ post(id: 1) { id } }`, &resp); err != nil {
+		t.Errorf("author should see the draft: %s", err)
+	}
+
+	var published struct {
+		PublishPost struct{ Status string }
+	}
+	if err := alice.Post(`mutation { publishPost(id: 1) { status } }`, &published); err != nil {
+		t.Fatalf("error was not expected while publishing: %s", err)
+	}
+	if published.PublishPost.Status != "PUBLISHED" {
+		t.Errorf("unexpected status: %s", published.PublishPost.Status)
+	}
+
+	var posts struct {
+		Posts struct{ TotalCount int }
+	}
+	if err := bob.Post(`{ posts { totalCount } }`, &posts); err != nil || posts.Posts.TotalCount != 1 {
+		t.Errorf("published post should be visible, got %d, %v", posts.Posts.TotalCount, err)
+	}
+}
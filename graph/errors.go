@@ -0,0 +1,99 @@
+package graph
+
+import (
+	"PostCommentService/auth"
+	"PostCommentService/db"
+	"PostCommentService/graph/model"
+	"PostCommentService/policy"
+	"PostCommentService/ratelimit"
+	"context"
+	"errors"
+	"log"
+
+	"github.com/99designs/gqlgen/graphql"
+	"github.com/vektah/gqlparser/v2/gqlerror"
+)
+
+// Коды ошибок, которые клиент получает в extensions.code
+const (
+	CodeNotFound         = "NOT_FOUND"
+	CodeCommentsDisabled = "COMMENTS_DISABLED"
+	CodePostNotPublished = "POST_NOT_PUBLISHED"
+	CodeContentTooLong   = "CONTENT_TOO_LONG"
+	CodePolicyViolation  = "POLICY_VIOLATION"
+	CodeInvalidParent    = "INVALID_PARENT"
+	CodeBadUserInput     = "BAD_USER_INPUT"
+	CodeUnauthenticated  = "UNAUTHENTICATED"
+	CodeForbidden        = "FORBIDDEN"
+	CodeRateLimited      = "RATE_LIMITED"
+	CodeQueryTooDeep     = "QUERY_TOO_DEEP"
+	CodeQueryTooComplex  = "QUERY_TOO_COMPLEX"
+	CodeInternal         = "INTERNAL"
+)
+
+var errorCodes = []struct {
+	err  error
+	code string
+}{
+	{db.ErrNotFound, CodeNotFound},
+	{db.ErrCommentsDisabled, CodeCommentsDisabled},
+	{db.ErrPostNotPublished, CodePostNotPublished},
+	{policy.ErrTooLong, CodeContentTooLong},
+	{policy.ErrViolation, CodePolicyViolation},
+	{db.ErrInvalidParent, CodeInvalidParent},
+	{db.ErrInvalidArgument, CodeBadUserInput},
+	{model.ErrInvalidInput, CodeBadUserInput},
+	{auth.ErrUnauthenticated, CodeUnauthenticated},
+	{auth.ErrInvalidToken, CodeUnauthenticated},
+	{auth.ErrForbidden, CodeForbidden},
+	{ratelimit.ErrLimited, CodeRateLimited},
+}
+
+// ErrorPresenter проставляет ошибкам предметной области код в extensions.code.
+// Остальные ошибки резолверов (например, ошибки базы данных) логируются
+// и отдаются клиенту без подробностей.
+func ErrorPresenter(ctx context.Context, err error) *gqlerror.Error {
+	gqlErr := graphql.DefaultErrorPresenter(ctx, err)
+
+	// Ошибки разбора и валидации запроса gqlgen формирует сам, их не трогаем
+	if gqlErr.Err == nil {
+		return gqlErr
+	}
+	if _, ok := gqlErr.Extensions["code"]; ok {
+		return gqlErr
+	}
+
+	code := ""
+	for _, c := range errorCodes {
+		if errors.Is(err, c.err) {
+			code = c.code
+			break
+		}
+	}
+
+	if code == "" {
+		log.Printf("graphql: %s: %v", gqlErr.Path, err)
+		gqlErr.Message = "internal server error"
+		code = CodeInternal
+	}
+
+	if gqlErr.Extensions == nil {
+		gqlErr.Extensions = map[string]interface{}{}
+	}
+	gqlErr.Extensions["code"] = code
+
+	// Нарушение правил контента сообщает, какое правило и в каком поле нарушено
+	var violation *policy.Violation
+	if errors.As(err, &violation) {
+		gqlErr.Extensions["rule"] = violation.Rule
+		gqlErr.Extensions["field"] = violation.Field
+	}
+
+	// Ограничение частоты сообщает, через сколько секунд можно повторить запрос
+	var limited *ratelimit.LimitedError
+	if errors.As(err, &limited) {
+		gqlErr.Extensions["retryAfter"] = limited.Seconds()
+	}
+
+	return gqlErr
+}
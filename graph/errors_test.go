@@ -0,0 +1,58 @@
+package graph
+
+import (
+	"PostCommentService/auth"
+	"PostCommentService/db"
+	"PostCommentService/policy"
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/vektah/gqlparser/v2/gqlerror"
+)
+
+func TestErrorPresenterCodes(t *testing.T) {
+	tests := []struct {
+		err     error
+		code    string
+		message string
+	}{
+		{&db.NotFoundError{Entity: "post", ID: 1}, CodeNotFound, "post 1 not found"},
+		{db.ErrCommentsDisabled, CodeCommentsDisabled, db.ErrCommentsDisabled.Error()},
+		{policy.ErrTooLong, CodeContentTooLong, policy.ErrTooLong.Error()},
+		{db.ErrInvalidParent, CodeInvalidParent, db.ErrInvalidParent.Error()},
+		{db.ErrInvalidCursor, CodeBadUserInput, db.ErrInvalidCursor.Error()},
+		{fmt.Errorf("load comments: %w", db.ErrInvalidCursor), CodeBadUserInput, "load comments: invalid argument: invalid cursor"},
+		{auth.ErrUnauthenticated, CodeUnauthenticated, auth.ErrUnauthenticated.Error()},
+		{errors.New("pq: connection refused"), CodeInternal, "internal server error"},
+	}
+
+	for _, tt := range tests {
+		gqlErr := ErrorPresenter(context.Background(), tt.err)
+		if gqlErr.Extensions["code"] != tt.code || gqlErr.Message != tt.message {
+			t.Errorf("%v: expected %s %q, got %v %q", tt.err, tt.code, tt.message, gqlErr.Extensions["code"], gqlErr.Message)
+		}
+		if !errors.Is(gqlErr, tt.err) {
+			t.Errorf("%v: original error should be kept for logging", tt.err)
+		}
+	}
+}
+
+func TestErrorPresenterKeepsValidationErrors(t *testing.T) {
+	err := gqlerror.Errorf("Cannot query field \"foo\" on type \"Query\".")
+
+	gqlErr := ErrorPresenter(context.Background(), err)
+	if gqlErr.Message != err.Message || gqlErr.Extensions != nil {
+		t.Errorf("validation error should be returned as is, got %+v", gqlErr)
+	}
+}
+
+func TestErrorPresenterPolicyViolation(t *testing.T) {
+	err := policy.New(policy.MaxLinks(0)).Check(policy.Content{Kind: policy.KindComment, Content: "https://example.com"})
+
+	gqlErr := ErrorPresenter(context.Background(), err)
+	if gqlErr.Extensions["code"] != CodePolicyViolation || gqlErr.Extensions["rule"] != "maxLinks" || gqlErr.Extensions["field"] != policy.FieldContent {
+		t.Errorf("unexpected extensions: %v", gqlErr.Extensions)
+	}
+}
@@ -0,0 +1,76 @@
+package graph
+
+import (
+	"context"
+	"strings"
+
+	"github.com/99designs/gqlgen/complexity"
+	"github.com/99designs/gqlgen/graphql"
+	"github.com/vektah/gqlparser/v2/ast"
+	"github.com/vektah/gqlparser/v2/gqlerror"
+)
+
+// QueryLimits отклоняет до выполнения запросы глубже MaxDepth или дороже
+// MaxComplexity. Стоимость считается функциями из NewComplexity.
+// Нулевое значение отключает соответствующую проверку.
+type QueryLimits struct {
+	MaxDepth      int
+	MaxComplexity int
+
+	es graphql.ExecutableSchema
+}
+
+var _ interface {
+	graphql.HandlerExtension
+	graphql.OperationContextMutator
+} = &QueryLimits{}
+
+func (l *QueryLimits) ExtensionName() string {
+	return "QueryLimits"
+}
+
+func (l *QueryLimits) Validate(schema graphql.ExecutableSchema) error {
+	l.es = schema
+	return nil
+}
+
+func (l *QueryLimits) MutateOperationContext(ctx context.Context, rc *graphql.OperationContext) *gqlerror.Error {
+	if l.MaxDepth > 0 {
+		if depth := selectionDepth(rc.Operation.SelectionSet); depth > l.MaxDepth {
+			err := gqlerror.Errorf("query depth %d exceeds the limit of %d", depth, l.MaxDepth)
+			err.Extensions = map[string]interface{}{"code": CodeQueryTooDeep, "depth": depth, "limit": l.MaxDepth}
+			return err
+		}
+	}
+
+	if l.MaxComplexity > 0 {
+		if cost := complexity.Calculate(l.es, rc.Operation, rc.Variables); cost > l.MaxComplexity {
+			err := gqlerror.Errorf("query cost %d exceeds the limit of %d", cost, l.MaxComplexity)
+			err.Extensions = map[string]interface{}{"code": CodeQueryTooComplex, "cost": cost, "limit": l.MaxComplexity}
+			return err
+		}
+	}
+
+	return nil
+}
+
+// selectionDepth возвращает число вложенных уровней полей. Фрагменты уровня
+// не добавляют, служебные поля интроспекции не учитываются.
+func selectionDepth(set ast.SelectionSet) int {
+	depth := 0
+	for _, sel := range set {
+		switch sel := sel.(type) {
+		case *ast.Field:
+			if !strings.HasPrefix(sel.Name, "__") {
+				depth = max(depth, 1+selectionDepth(sel.SelectionSet))
+			}
+		case *ast.InlineFragment:
+			depth = max(depth, selectionDepth(sel.SelectionSet))
+		case *ast.FragmentSpread:
+			if sel.Definition != nil {
+				depth = max(depth, selectionDepth(sel.Definition.SelectionSet))
+			}
+		}
+	}
+	return depth
+}
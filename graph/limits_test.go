@@ -0,0 +1,90 @@
+package graph
+
+import (
+	"PostCommentService/auth"
+	"PostCommentService/db"
+	"PostCommentService/graph/loaders"
+	"PostCommentService/graph/model"
+	"PostCommentService/policy"
+	"PostCommentService/pubsub"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/99designs/gqlgen/client"
+	"github.com/99designs/gqlgen/graphql/handler"
+	"github.com/99designs/gqlgen/graphql/handler/extension"
+	"github.com/99designs/gqlgen/graphql/handler/transport"
+)
+
+func newLimitedClient(store db.Store, limits *QueryLimits) *client.Client {
+	srv := handler.New(NewExecutableSchema(Config{
+		Resolvers:  NewResolver(store, pubsub.NewBroker(), DefaultReactions, policy.FromConfig(policy.DefaultConfig)),
+		Directives: NewDirectives(store),
+		Complexity: NewComplexity(),
+	}))
+	srv.AddTransport(transport.POST{})
+	srv.AroundOperations(loaders.Middleware(store))
+	srv.SetErrorPresenter(ErrorPresenter)
+	srv.Use(extension.Introspection{})
+	srv.Use(limits)
+
+	return client.New(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		srv.ServeHTTP(w, r.WithContext(auth.WithUser(r.Context(), &auth.User{ID: "alice"})))
+	}))
+}
+
+func TestQueryLimits(t *testing.T) {
+	store := db.NewMemoryStore()
+	store.CreatePost("Title", "Content", "alice", nil, model.PostStatusPublished, nil)
+
+	c := newLimitedClient(store, &QueryLimits{MaxDepth: 8, MaxComplexity: 300})
+	var resp map[string]interface{}
+
+	// post(1) + comments(1 + 10 * (edges(1 + node(1 + id(1))))) = 32
+	if err := c.Post(`{ post(id: 1) { comments { edges { node { id } } } } }`, &resp); err != nil {
+		t.Errorf("error was not expected for a small query: %s", err)
+	}
+
+	// Каждый уровень child умножает стоимость на размер страницы
+	err := c.Post(`{ post(id: 1) { comments(first: 10) { edges { node { child(first: 10) { edges { node { id } } } } } } } }`, &resp)
+	if err == nil || !strings.Contains(err.Error(), CodeQueryTooComplex) || !strings.Contains(err.Error(), `"cost":332`) {
+		t.Errorf("expected %s with the cost, got %v", CodeQueryTooComplex, err)
+	}
+
+	// Размер страницы из переменной тоже учитывается
+	query := `query($first: Int) { post(id: 1) { comments(first: $first) { edges { node { child(first: $first) { edges { node { id } } } } } } } }`
+	if err := c.Post(query, &resp, client.Var("first", 5)); err != nil {
+		t.Errorf("error was not expected for smaller pages: %s", err)
+	}
+
+	// Глубина считается и через фрагменты
+	err = c.Post(`{ post(id: 1) { ...Thread } } fragment Thread on Post { comments(first: 1) { edges { node { child(first: 1) { edges { node { child(first: 1) { totalCount } } } } } } } }`, &resp)
+	if err == nil || !strings.Contains(err.Error(), CodeQueryTooDeep) || !strings.Contains(err.Error(), `"depth":9`) {
+		t.Errorf("expected %s with the depth, got %v", CodeQueryTooDeep, err)
+	}
+
+	// Интроспекция не упирается в глубину
+	if err := c.Post(`{ __schema { types { fields { type { ofType { ofType { ofType { name } } } } } } } }`, &resp); err != nil {
+		t.Errorf("introspection should not count towards depth: %s", err)
+	}
+}
+
+func TestPageComplexity(t *testing.T) {
+	first, tooMany, negative := 10, 1000, -1
+	tests := []struct {
+		first *int
+		cost  int
+	}{
+		{nil, 1},
+		{&first, 31},
+		{&tooMany, 1 + 3*db.MaxPageSize},
+		{&negative, 1},
+	}
+
+	for _, tt := range tests {
+		if cost := pageComplexity(3, tt.first); cost != tt.cost {
+			t.Errorf("expected %d, got %d", tt.cost, cost)
+		}
+	}
+}
@@ -0,0 +1,211 @@
+package loaders
+
+import (
+	"PostCommentService/auth"
+	"PostCommentService/db"
+	"PostCommentService/graph/model"
+	"context"
+	"time"
+
+	"github.com/99designs/gqlgen/graphql"
+	"github.com/graph-gophers/dataloader/v7"
+)
+
+// Сколько ждать остальные запросы перед отправкой пакета в хранилище
+const batchWait = 2 * time.Millisecond
+
+// PageKey - запрос страницы комментариев поста (ID поста) или ответов
+// на комментарий (ID родителя) с аргументами пагинации поля
+type PageKey struct {
+	ID    int
+	First int
+	After string
+	Sort  model.CommentSort
+}
+
+// VoteKey - запрос голоса текущего пользователя за пост или комментарий
+type VoteKey struct {
+	Target model.VoteTarget
+	ID     int
+}
+
+type Loaders struct {
+	Comments *dataloader.Loader[PageKey, *model.CommentConnection]
+	Replies  *dataloader.Loader[PageKey, *model.CommentConnection]
+	MyVotes  *dataloader.Loader[VoteKey, int]
+	// Реакции на комментарий по его ID
+	Reactions *dataloader.Loader[int, []*model.Reaction]
+	// Теги поста по его ID
+	Tags *dataloader.Loader[int, []string]
+}
+
+type pageFetcher func(ids []int, first int, after string, sort model.CommentSort, withHidden bool) (map[int]*model.CommentConnection, error)
+
+type voteFetcher func(target model.VoteTarget, ids []int, userID string) (map[int]int, error)
+
+type reactionFetcher func(commentIDs []int, userID string) (map[int][]*model.Reaction, error)
+
+func New(store db.Store) *Loaders {
+	return &Loaders{
+		Comments: newPageLoader(store.GetComments),
+		Replies:  newPageLoader(store.GetReplies),
+		MyVotes:  dataloader.NewBatchedLoader(batchVotes(store.GetVotes), dataloader.WithWait[VoteKey, int](batchWait)),
+		Reactions: dataloader.NewBatchedLoader(batchReactions(store.GetReactions),
+			dataloader.WithWait[int, []*model.Reaction](batchWait)),
+		Tags: dataloader.NewBatchedLoader(batchTags(store.GetPostTags), dataloader.WithWait[int, []string](batchWait)),
+	}
+}
+
+func newPageLoader(fetch pageFetcher) *dataloader.Loader[PageKey, *model.CommentConnection] {
+	return dataloader.NewBatchedLoader(batchPages(fetch), dataloader.WithWait[PageKey, *model.CommentConnection](batchWait))
+}
+
+// batchPages группирует ключи по аргументам пагинации, чтобы одинаковые
+// поля на соседних объектах загружались одним запросом к хранилищу.
+// Скрытые комментарии загружаются только для модераторов и администраторов.
+func batchPages(fetch pageFetcher) dataloader.BatchFunc[PageKey, *model.CommentConnection] {
+	return func(ctx context.Context, keys []PageKey) []*dataloader.Result[*model.CommentConnection] {
+		user := auth.ForContext(ctx)
+		withHidden := user != nil && user.HasRole(auth.RoleModerator, auth.RoleAdmin)
+
+		type args struct {
+			first int
+			after string
+			sort  model.CommentSort
+		}
+
+		groups := make(map[args][]int)
+		for _, key := range keys {
+			a := args{first: key.First, after: key.After, sort: key.Sort}
+			groups[a] = append(groups[a], key.ID)
+		}
+
+		pages := make(map[PageKey]*model.CommentConnection, len(keys))
+		errs := make(map[args]error)
+		for a, ids := range groups {
+			result, err := fetch(ids, a.first, a.after, a.sort, withHidden)
+			if err != nil {
+				errs[a] = err
+				continue
+			}
+			for id, page := range result {
+				pages[PageKey{ID: id, First: a.first, After: a.after, Sort: a.sort}] = page
+			}
+		}
+
+		results := make([]*dataloader.Result[*model.CommentConnection], len(keys))
+		for i, key := range keys {
+			if err := errs[args{first: key.First, after: key.After, sort: key.Sort}]; err != nil {
+				results[i] = &dataloader.Result[*model.CommentConnection]{Error: err}
+				continue
+			}
+			results[i] = &dataloader.Result[*model.CommentConnection]{Data: pages[key]}
+		}
+
+		return results
+	}
+}
+
+// batchVotes загружает голоса пользователя запроса одним обращением
+// к хранилищу на каждый тип цели. У анонимного пользователя голосов нет.
+func batchVotes(fetch voteFetcher) dataloader.BatchFunc[VoteKey, int] {
+	return func(ctx context.Context, keys []VoteKey) []*dataloader.Result[int] {
+		results := make([]*dataloader.Result[int], len(keys))
+
+		user := auth.ForContext(ctx)
+		if user == nil {
+			for i := range keys {
+				results[i] = &dataloader.Result[int]{}
+			}
+			return results
+		}
+
+		groups := make(map[model.VoteTarget][]int)
+		for _, key := range keys {
+			groups[key.Target] = append(groups[key.Target], key.ID)
+		}
+
+		votes := make(map[VoteKey]int, len(keys))
+		errs := make(map[model.VoteTarget]error)
+		for target, ids := range groups {
+			result, err := fetch(target, ids, user.ID)
+			if err != nil {
+				errs[target] = err
+				continue
+			}
+			for id, value := range result {
+				votes[VoteKey{Target: target, ID: id}] = value
+			}
+		}
+
+		for i, key := range keys {
+			results[i] = &dataloader.Result[int]{Data: votes[key], Error: errs[key.Target]}
+		}
+
+		return results
+	}
+}
+
+// batchReactions загружает реакции на комментарии одним обращением к хранилищу.
+// Реакции анонимного пользователя не отмечаются как его собственные.
+func batchReactions(fetch reactionFetcher) dataloader.BatchFunc[int, []*model.Reaction] {
+	return func(ctx context.Context, ids []int) []*dataloader.Result[[]*model.Reaction] {
+		var userID string
+		if user := auth.ForContext(ctx); user != nil {
+			userID = user.ID
+		}
+
+		reactions, err := fetch(ids, userID)
+
+		results := make([]*dataloader.Result[[]*model.Reaction], len(ids))
+		for i, id := range ids {
+			if err != nil {
+				results[i] = &dataloader.Result[[]*model.Reaction]{Error: err}
+				continue
+			}
+			data := reactions[id]
+			if data == nil {
+				data = []*model.Reaction{}
+			}
+			results[i] = &dataloader.Result[[]*model.Reaction]{Data: data}
+		}
+
+		return results
+	}
+}
+
+// batchTags загружает теги постов одним обращением к хранилищу
+func batchTags(fetch func(postIDs []int) (map[int][]string, error)) dataloader.BatchFunc[int, []string] {
+	return func(ctx context.Context, ids []int) []*dataloader.Result[[]string] {
+		tags, err := fetch(ids)
+
+		results := make([]*dataloader.Result[[]string], len(ids))
+		for i, id := range ids {
+			if err != nil {
+				results[i] = &dataloader.Result[[]string]{Error: err}
+				continue
+			}
+			data := tags[id]
+			if data == nil {
+				data = []string{}
+			}
+			results[i] = &dataloader.Result[[]string]{Data: data}
+		}
+
+		return results
+	}
+}
+
+type contextKey struct{}
+
+// Middleware создаёт новые загрузчики для каждой GraphQL-операции,
+// чтобы кэш загрузчиков не переживал запрос
+func Middleware(store db.Store) graphql.OperationMiddleware {
+	return func(ctx context.Context, next graphql.OperationHandler) graphql.ResponseHandler {
+		return next(context.WithValue(ctx, contextKey{}, New(store)))
+	}
+}
+
+func For(ctx context.Context) *Loaders {
+	return ctx.Value(contextKey{}).(*Loaders)
+}
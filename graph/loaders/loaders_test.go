@@ -0,0 +1,154 @@
+package loaders
+
+import (
+	"PostCommentService/auth"
+	"PostCommentService/graph/model"
+	"context"
+	"errors"
+	"sort"
+	"testing"
+)
+
+func TestBatchPagesGroupsByArguments(t *testing.T) {
+	var calls [][]int
+	fetch := func(ids []int, first int, after string, _ model.CommentSort, _ bool) (map[int]*model.CommentConnection, error) {
+		sorted := append([]int(nil), ids...)
+		sort.Ints(sorted)
+		calls = append(calls, sorted)
+
+		if after == "broken" {
+			return nil, errors.New("invalid cursor")
+		}
+
+		result := make(map[int]*model.CommentConnection, len(ids))
+		for _, id := range ids {
+			result[id] = &model.CommentConnection{TotalCount: id * first}
+		}
+		return result, nil
+	}
+
+	keys := []PageKey{
+		{ID: 1, First: 10},
+		{ID: 2, First: 10},
+		{ID: 3, First: 5},
+		{ID: 4, First: 10, After: "broken"},
+	}
+
+	results := batchPages(fetch)(context.Background(), keys)
+
+	if len(calls) != 3 {
+		t.Errorf("expected 3 store calls, got %d: %v", len(calls), calls)
+	}
+
+	if results[0].Data.TotalCount != 10 || results[1].Data.TotalCount != 20 || results[2].Data.TotalCount != 15 {
+		t.Errorf("unexpected results: %+v, %+v, %+v", results[0].Data, results[1].Data, results[2].Data)
+	}
+
+	if results[3].Error == nil {
+		t.Error("expected an error for the broken cursor")
+	}
+}
+
+func TestLoaderBatchesConcurrentLoads(t *testing.T) {
+	calls := 0
+	loader := newPageLoader(func(ids []int, first int, after string, _ model.CommentSort, _ bool) (map[int]*model.CommentConnection, error) {
+		calls++
+		result := make(map[int]*model.CommentConnection, len(ids))
+		for _, id := range ids {
+			result[id] = &model.CommentConnection{TotalCount: id}
+		}
+		return result, nil
+	})
+
+	ctx := context.Background()
+	first := loader.Load(ctx, PageKey{ID: 1, First: 10})
+	second := loader.Load(ctx, PageKey{ID: 2, First: 10})
+
+	page, err := first()
+	if err != nil || page.TotalCount != 1 {
+		t.Errorf("unexpected first page: %+v, %v", page, err)
+	}
+
+	page, err = second()
+	if err != nil || page.TotalCount != 2 {
+		t.Errorf("unexpected second page: %+v, %v", page, err)
+	}
+
+	if calls != 1 {
+		t.Errorf("expected loads to be batched into 1 call, got %d", calls)
+	}
+}
+
+func TestBatchVotes(t *testing.T) {
+	var calls []model.VoteTarget
+	fetch := func(target model.VoteTarget, ids []int, userID string) (map[int]int, error) {
+		calls = append(calls, target)
+		if userID != "alice" {
+			t.Errorf("unexpected user %q", userID)
+		}
+		return map[int]int{1: 1, 2: -1}, nil
+	}
+
+	keys := []VoteKey{
+		{Target: model.VoteTargetPost, ID: 1},
+		{Target: model.VoteTargetComment, ID: 2},
+		{Target: model.VoteTargetComment, ID: 3},
+	}
+
+	results := batchVotes(fetch)(auth.WithUser(context.Background(), &auth.User{ID: "alice"}), keys)
+	if len(calls) != 2 {
+		t.Errorf("expected 1 store call per target, got %v", calls)
+	}
+	if results[0].Data != 1 || results[1].Data != -1 || results[2].Data != 0 {
+		t.Errorf("unexpected votes: %d, %d, %d", results[0].Data, results[1].Data, results[2].Data)
+	}
+
+	// Анонимному пользователю голоса не загружаются
+	calls = nil
+	results = batchVotes(fetch)(context.Background(), keys)
+	if len(calls) != 0 || results[0].Data != 0 {
+		t.Errorf("anonymous votes should not be loaded, got calls %v", calls)
+	}
+}
+
+func TestBatchReactions(t *testing.T) {
+	var users []string
+	fetch := func(ids []int, userID string) (map[int][]*model.Reaction, error) {
+		users = append(users, userID)
+		return map[int][]*model.Reaction{1: {{Emoji: "👍", Count: 2, ReactedByMe: userID != ""}}}, nil
+	}
+
+	results := batchReactions(fetch)(auth.WithUser(context.Background(), &auth.User{ID: "alice"}), []int{1, 2})
+	if len(users) != 1 || users[0] != "alice" {
+		t.Errorf("expected 1 store call for alice, got %v", users)
+	}
+	if len(results[0].Data) != 1 || !results[0].Data[0].ReactedByMe {
+		t.Errorf("unexpected reactions: %+v", results[0].Data)
+	}
+	// Комментарий без реакций получает пустой список, а не null
+	if results[1].Data == nil || len(results[1].Data) != 0 {
+		t.Errorf("expected empty reactions, got %v", results[1].Data)
+	}
+
+	results = batchReactions(fetch)(context.Background(), []int{1})
+	if users[1] != "" || results[0].Data[0].ReactedByMe {
+		t.Errorf("anonymous reactions should not be marked, got %+v", results[0].Data[0])
+	}
+}
+
+func TestBatchPagesWithHidden(t *testing.T) {
+	var flags []bool
+	fetch := func(ids []int, first int, after string, _ model.CommentSort, withHidden bool) (map[int]*model.CommentConnection, error) {
+		flags = append(flags, withHidden)
+		return map[int]*model.CommentConnection{}, nil
+	}
+
+	keys := []PageKey{{ID: 1, First: 10}}
+	batchPages(fetch)(context.Background(), keys)
+	batchPages(fetch)(auth.WithUser(context.Background(), &auth.User{ID: "alice"}), keys)
+	batchPages(fetch)(auth.WithUser(context.Background(), &auth.User{ID: "carol", Roles: []string{auth.RoleModerator}}), keys)
+
+	if len(flags) != 3 || flags[0] || flags[1] || !flags[2] {
+		t.Errorf("hidden comments should be loaded only for moderators, got %v", flags)
+	}
+}
@@ -0,0 +1,33 @@
+package model
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"time"
+
+	"github.com/99designs/gqlgen/graphql"
+)
+
+// ErrInvalidInput - значение скаляра в аргументах запроса не удалось разобрать
+var ErrInvalidInput = errors.New("invalid input")
+
+// MarshalDateTime отдаёт время строкой RFC 3339 в UTC
+func MarshalDateTime(t time.Time) graphql.Marshaler {
+	return graphql.WriterFunc(func(w io.Writer) {
+		io.WriteString(w, strconv.Quote(t.UTC().Format(time.RFC3339Nano)))
+	})
+}
+
+func UnmarshalDateTime(v interface{}) (time.Time, error) {
+	s, ok := v.(string)
+	if !ok {
+		return time.Time{}, fmt.Errorf("%w: DateTime must be an RFC 3339 string", ErrInvalidInput)
+	}
+	t, err := time.Parse(time.RFC3339, s)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("%w: DateTime must be an RFC 3339 string, got %q", ErrInvalidInput, s)
+	}
+	return t, nil
+}
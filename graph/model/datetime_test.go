@@ -0,0 +1,30 @@
+package model
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestDateTime(t *testing.T) {
+	moscow := time.FixedZone("MSK", 3*60*60)
+	value := time.Date(2024, 1, 1, 3, 0, 0, 0, moscow)
+
+	var buf bytes.Buffer
+	MarshalDateTime(value).MarshalGQL(&buf)
+	if buf.String() != `"2024-01-01T00:00:00Z"` {
+		t.Errorf("expected UTC time, got %s", buf.String())
+	}
+
+	parsed, err := UnmarshalDateTime("2024-01-01T03:00:00+03:00")
+	if err != nil || !parsed.Equal(value) {
+		t.Errorf("unexpected value %s, %v", parsed, err)
+	}
+
+	for _, v := range []interface{}{42, "garbage", "2024-01-01"} {
+		if _, err := UnmarshalDateTime(v); !errors.Is(err, ErrInvalidInput) {
+			t.Errorf("%v: expected %v, got %v", v, ErrInvalidInput, err)
+		}
+	}
+}
@@ -2,29 +2,615 @@
 
 package model
 
+import (
+	"fmt"
+	"io"
+	"strconv"
+	"time"
+)
+
 type Comment struct {
-	ID       int        `json:"id"`
-	PostID   int        `json:"postId"`
-	Author   string     `json:"author"`
-	Content  string     `json:"content"`
-	ParentID *int       `json:"parentId,omitempty"`
-	Child    []*Comment `json:"child,omitempty"`
+	ID        int        `json:"id"`
+	PostID    int        `json:"postId"`
+	Author    string     `json:"author"`
+	Content   string     `json:"content"`
+	ParentID  *int       `json:"parentId,omitempty"`
+	IsDeleted bool       `json:"isDeleted"`
+	IsHidden  bool       `json:"isHidden"`
+	CreatedAt time.Time  `json:"createdAt"`
+	UpdatedAt time.Time  `json:"updatedAt"`
+	Score     int        `json:"score"`
+	Upvotes   int        `json:"upvotes"`
+	Downvotes int        `json:"downvotes"`
+	EditedAt  *time.Time `json:"editedAt,omitempty"`
+	// Сколько из ответов скрыто модератором
+	HiddenReplyCount int `json:"-"`
+	// Число ответов, включая скрытые модератором
+	ReplyCount int `json:"-"`
+}
+
+type CommentConnection struct {
+	Edges      []*CommentEdge `json:"edges"`
+	PageInfo   *PageInfo      `json:"pageInfo"`
+	TotalCount int            `json:"totalCount"`
+}
+
+type CommentEdge struct {
+	Cursor string   `json:"cursor"`
+	Node   *Comment `json:"node"`
+}
+
+type CommentSearchConnection struct {
+	Edges      []*CommentSearchEdge `json:"edges"`
+	PageInfo   *PageInfo            `json:"pageInfo"`
+	TotalCount int                  `json:"totalCount"`
+}
+
+type CommentSearchEdge struct {
+	Cursor  string   `json:"cursor"`
+	Node    *Comment `json:"node"`
+	Rank    float64  `json:"rank"`
+	Snippet string   `json:"snippet"`
+}
+
+type DiffLine struct {
+	Op   DiffOp `json:"op"`
+	Text string `json:"text"`
 }
 
 type Mutation struct {
 }
 
+type PageInfo struct {
+	HasNextPage bool    `json:"hasNextPage"`
+	EndCursor   *string `json:"endCursor,omitempty"`
+}
+
 type Post struct {
-	ID              int        `json:"id"`
-	Title           string     `json:"title"`
-	Content         string     `json:"content"`
-	Comments        []*Comment `json:"comments,omitempty"`
-	CommentsEnabled bool       `json:"commentsEnabled"`
-	Author          string     `json:"author"`
+	ID                 int                `json:"id"`
+	Title              string             `json:"title"`
+	Content            string             `json:"content"`
+	CommentsEnabled    bool               `json:"commentsEnabled"`
+	CommentsLockReason *CommentLockReason `json:"commentsLockReason,omitempty"`
+	AutoLockAfterDays  *int               `json:"autoLockAfterDays,omitempty"`
+	Author             string             `json:"author"`
+	Status             PostStatus         `json:"status"`
+	PublishAt          *time.Time         `json:"publishAt,omitempty"`
+	CreatedAt          time.Time          `json:"createdAt"`
+	UpdatedAt          time.Time          `json:"updatedAt"`
+	Score              int                `json:"score"`
+	Upvotes            int                `json:"upvotes"`
+	Downvotes          int                `json:"downvotes"`
+	EditedAt           *time.Time         `json:"editedAt,omitempty"`
+}
+
+type PostConnection struct {
+	Edges      []*PostEdge `json:"edges"`
+	PageInfo   *PageInfo   `json:"pageInfo"`
+	TotalCount int         `json:"totalCount"`
+}
+
+type PostEdge struct {
+	Cursor string `json:"cursor"`
+	Node   *Post  `json:"node"`
+}
+
+type PostFilter struct {
+	Author          *string  `json:"author,omitempty"`
+	CommentsEnabled *bool    `json:"commentsEnabled,omitempty"`
+	Tags            []string `json:"tags,omitempty"`
+}
+
+type PostSearchConnection struct {
+	Edges      []*PostSearchEdge `json:"edges"`
+	PageInfo   *PageInfo         `json:"pageInfo"`
+	TotalCount int               `json:"totalCount"`
+}
+
+type PostSearchEdge struct {
+	Cursor  string  `json:"cursor"`
+	Node    *Post   `json:"node"`
+	Rank    float64 `json:"rank"`
+	Snippet string  `json:"snippet"`
 }
 
 type Query struct {
 }
 
+type Reaction struct {
+	Emoji       string `json:"emoji"`
+	Count       int    `json:"count"`
+	ReactedByMe bool   `json:"reactedByMe"`
+}
+
+type Report struct {
+	ID         int           `json:"id"`
+	CommentID  int           `json:"commentId"`
+	Reporter   string        `json:"reporter"`
+	Reason     string        `json:"reason"`
+	Status     ReportStatus  `json:"status"`
+	Action     *ReportAction `json:"action,omitempty"`
+	ResolvedBy *string       `json:"resolvedBy,omitempty"`
+	ResolvedAt *time.Time    `json:"resolvedAt,omitempty"`
+	CreatedAt  time.Time     `json:"createdAt"`
+}
+
+type ReportConnection struct {
+	Edges      []*ReportEdge `json:"edges"`
+	PageInfo   *PageInfo     `json:"pageInfo"`
+	TotalCount int           `json:"totalCount"`
+}
+
+type ReportEdge struct {
+	Cursor string  `json:"cursor"`
+	Node   *Report `json:"node"`
+}
+
+type Revision struct {
+	Number   int       `json:"number"`
+	Title    *string   `json:"title,omitempty"`
+	Content  string    `json:"content"`
+	Editor   string    `json:"editor"`
+	EditedAt time.Time `json:"editedAt"`
+}
+
+type RevisionConnection struct {
+	Edges      []*RevisionEdge `json:"edges"`
+	PageInfo   *PageInfo       `json:"pageInfo"`
+	TotalCount int             `json:"totalCount"`
+}
+
+type RevisionDiff struct {
+	From    int         `json:"from"`
+	To      *int        `json:"to,omitempty"`
+	Title   []*DiffLine `json:"title"`
+	Content []*DiffLine `json:"content"`
+}
+
+type RevisionEdge struct {
+	Cursor string    `json:"cursor"`
+	Node   *Revision `json:"node"`
+}
+
 type Subscription struct {
 }
+
+type Tag struct {
+	Name  string `json:"name"`
+	Count int    `json:"count"`
+}
+
+type VoteResult struct {
+	TargetType VoteTarget `json:"targetType"`
+	TargetID   int        `json:"targetId"`
+	Score      int        `json:"score"`
+	Upvotes    int        `json:"upvotes"`
+	Downvotes  int        `json:"downvotes"`
+	MyVote     int        `json:"myVote"`
+}
+
+type CommentLockReason string
+
+const (
+	CommentLockReasonManual CommentLockReason = "MANUAL"
+	CommentLockReasonAuto   CommentLockReason = "AUTO"
+)
+
+var AllCommentLockReason = []CommentLockReason{
+	CommentLockReasonManual,
+	CommentLockReasonAuto,
+}
+
+func (e CommentLockReason) IsValid() bool {
+	switch e {
+	case CommentLockReasonManual, CommentLockReasonAuto:
+		return true
+	}
+	return false
+}
+
+func (e CommentLockReason) String() string {
+	return string(e)
+}
+
+func (e *CommentLockReason) UnmarshalGQL(v interface{}) error {
+	str, ok := v.(string)
+	if !ok {
+		return fmt.Errorf("enums must be strings")
+	}
+
+	*e = CommentLockReason(str)
+	if !e.IsValid() {
+		return fmt.Errorf("%s is not a valid CommentLockReason", str)
+	}
+	return nil
+}
+
+func (e CommentLockReason) MarshalGQL(w io.Writer) {
+	fmt.Fprint(w, strconv.Quote(e.String()))
+}
+
+type CommentSort string
+
+const (
+	CommentSortOld           CommentSort = "OLD"
+	CommentSortNew           CommentSort = "NEW"
+	CommentSortTop           CommentSort = "TOP"
+	CommentSortBest          CommentSort = "BEST"
+	CommentSortControversial CommentSort = "CONTROVERSIAL"
+)
+
+var AllCommentSort = []CommentSort{
+	CommentSortOld,
+	CommentSortNew,
+	CommentSortTop,
+	CommentSortBest,
+	CommentSortControversial,
+}
+
+func (e CommentSort) IsValid() bool {
+	switch e {
+	case CommentSortOld, CommentSortNew, CommentSortTop, CommentSortBest, CommentSortControversial:
+		return true
+	}
+	return false
+}
+
+func (e CommentSort) String() string {
+	return string(e)
+}
+
+func (e *CommentSort) UnmarshalGQL(v interface{}) error {
+	str, ok := v.(string)
+	if !ok {
+		return fmt.Errorf("enums must be strings")
+	}
+
+	*e = CommentSort(str)
+	if !e.IsValid() {
+		return fmt.Errorf("%s is not a valid CommentSort", str)
+	}
+	return nil
+}
+
+func (e CommentSort) MarshalGQL(w io.Writer) {
+	fmt.Fprint(w, strconv.Quote(e.String()))
+}
+
+type DiffOp string
+
+const (
+	DiffOpEqual  DiffOp = "EQUAL"
+	DiffOpInsert DiffOp = "INSERT"
+	DiffOpDelete DiffOp = "DELETE"
+)
+
+var AllDiffOp = []DiffOp{
+	DiffOpEqual,
+	DiffOpInsert,
+	DiffOpDelete,
+}
+
+func (e DiffOp) IsValid() bool {
+	switch e {
+	case DiffOpEqual, DiffOpInsert, DiffOpDelete:
+		return true
+	}
+	return false
+}
+
+func (e DiffOp) String() string {
+	return string(e)
+}
+
+func (e *DiffOp) UnmarshalGQL(v interface{}) error {
+	str, ok := v.(string)
+	if !ok {
+		return fmt.Errorf("enums must be strings")
+	}
+
+	*e = DiffOp(str)
+	if !e.IsValid() {
+		return fmt.Errorf("%s is not a valid DiffOp", str)
+	}
+	return nil
+}
+
+func (e DiffOp) MarshalGQL(w io.Writer) {
+	fmt.Fprint(w, strconv.Quote(e.String()))
+}
+
+type OwnedEntity string
+
+const (
+	OwnedEntityPost    OwnedEntity = "POST"
+	OwnedEntityComment OwnedEntity = "COMMENT"
+)
+
+var AllOwnedEntity = []OwnedEntity{
+	OwnedEntityPost,
+	OwnedEntityComment,
+}
+
+func (e OwnedEntity) IsValid() bool {
+	switch e {
+	case OwnedEntityPost, OwnedEntityComment:
+		return true
+	}
+	return false
+}
+
+func (e OwnedEntity) String() string {
+	return string(e)
+}
+
+func (e *OwnedEntity) UnmarshalGQL(v interface{}) error {
+	str, ok := v.(string)
+	if !ok {
+		return fmt.Errorf("enums must be strings")
+	}
+
+	*e = OwnedEntity(str)
+	if !e.IsValid() {
+		return fmt.Errorf("%s is not a valid OwnedEntity", str)
+	}
+	return nil
+}
+
+func (e OwnedEntity) MarshalGQL(w io.Writer) {
+	fmt.Fprint(w, strconv.Quote(e.String()))
+}
+
+type PostSort string
+
+const (
+	PostSortID     PostSort = "ID"
+	PostSortNewest PostSort = "NEWEST"
+)
+
+var AllPostSort = []PostSort{
+	PostSortID,
+	PostSortNewest,
+}
+
+func (e PostSort) IsValid() bool {
+	switch e {
+	case PostSortID, PostSortNewest:
+		return true
+	}
+	return false
+}
+
+func (e PostSort) String() string {
+	return string(e)
+}
+
+func (e *PostSort) UnmarshalGQL(v interface{}) error {
+	str, ok := v.(string)
+	if !ok {
+		return fmt.Errorf("enums must be strings")
+	}
+
+	*e = PostSort(str)
+	if !e.IsValid() {
+		return fmt.Errorf("%s is not a valid PostSort", str)
+	}
+	return nil
+}
+
+func (e PostSort) MarshalGQL(w io.Writer) {
+	fmt.Fprint(w, strconv.Quote(e.String()))
+}
+
+type PostStatus string
+
+const (
+	PostStatusDraft     PostStatus = "DRAFT"
+	PostStatusScheduled PostStatus = "SCHEDULED"
+	PostStatusPublished PostStatus = "PUBLISHED"
+)
+
+var AllPostStatus = []PostStatus{
+	PostStatusDraft,
+	PostStatusScheduled,
+	PostStatusPublished,
+}
+
+func (e PostStatus) IsValid() bool {
+	switch e {
+	case PostStatusDraft, PostStatusScheduled, PostStatusPublished:
+		return true
+	}
+	return false
+}
+
+func (e PostStatus) String() string {
+	return string(e)
+}
+
+func (e *PostStatus) UnmarshalGQL(v interface{}) error {
+	str, ok := v.(string)
+	if !ok {
+		return fmt.Errorf("enums must be strings")
+	}
+
+	*e = PostStatus(str)
+	if !e.IsValid() {
+		return fmt.Errorf("%s is not a valid PostStatus", str)
+	}
+	return nil
+}
+
+func (e PostStatus) MarshalGQL(w io.Writer) {
+	fmt.Fprint(w, strconv.Quote(e.String()))
+}
+
+type ReportAction string
+
+const (
+	ReportActionDismiss       ReportAction = "DISMISS"
+	ReportActionHideComment   ReportAction = "HIDE_COMMENT"
+	ReportActionDeleteComment ReportAction = "DELETE_COMMENT"
+	ReportActionLockPost      ReportAction = "LOCK_POST"
+)
+
+var AllReportAction = []ReportAction{
+	ReportActionDismiss,
+	ReportActionHideComment,
+	ReportActionDeleteComment,
+	ReportActionLockPost,
+}
+
+func (e ReportAction) IsValid() bool {
+	switch e {
+	case ReportActionDismiss, ReportActionHideComment, ReportActionDeleteComment, ReportActionLockPost:
+		return true
+	}
+	return false
+}
+
+func (e ReportAction) String() string {
+	return string(e)
+}
+
+func (e *ReportAction) UnmarshalGQL(v interface{}) error {
+	str, ok := v.(string)
+	if !ok {
+		return fmt.Errorf("enums must be strings")
+	}
+
+	*e = ReportAction(str)
+	if !e.IsValid() {
+		return fmt.Errorf("%s is not a valid ReportAction", str)
+	}
+	return nil
+}
+
+func (e ReportAction) MarshalGQL(w io.Writer) {
+	fmt.Fprint(w, strconv.Quote(e.String()))
+}
+
+type ReportStatus string
+
+const (
+	ReportStatusOpen      ReportStatus = "OPEN"
+	ReportStatusDismissed ReportStatus = "DISMISSED"
+	ReportStatusResolved  ReportStatus = "RESOLVED"
+)
+
+var AllReportStatus = []ReportStatus{
+	ReportStatusOpen,
+	ReportStatusDismissed,
+	ReportStatusResolved,
+}
+
+func (e ReportStatus) IsValid() bool {
+	switch e {
+	case ReportStatusOpen, ReportStatusDismissed, ReportStatusResolved:
+		return true
+	}
+	return false
+}
+
+func (e ReportStatus) String() string {
+	return string(e)
+}
+
+func (e *ReportStatus) UnmarshalGQL(v interface{}) error {
+	str, ok := v.(string)
+	if !ok {
+		return fmt.Errorf("enums must be strings")
+	}
+
+	*e = ReportStatus(str)
+	if !e.IsValid() {
+		return fmt.Errorf("%s is not a valid ReportStatus", str)
+	}
+	return nil
+}
+
+func (e ReportStatus) MarshalGQL(w io.Writer) {
+	fmt.Fprint(w, strconv.Quote(e.String()))
+}
+
+type Role string
+
+const (
+	RoleModerator Role = "MODERATOR"
+	RoleAdmin     Role = "ADMIN"
+)
+
+var AllRole = []Role{
+	RoleModerator,
+	RoleAdmin,
+}
+
+func (e Role) IsValid() bool {
+	switch e {
+	case RoleModerator, RoleAdmin:
+		return true
+	}
+	return false
+}
+
+func (e Role) String() string {
+	return string(e)
+}
+
+func (e *Role) UnmarshalGQL(v interface{}) error {
+	str, ok := v.(string)
+	if !ok {
+		return fmt.Errorf("enums must be strings")
+	}
+
+	*e = Role(str)
+	if !e.IsValid() {
+		return fmt.Errorf("%s is not a valid Role", str)
+	}
+	return nil
+}
+
+func (e Role) MarshalGQL(w io.Writer) {
+	fmt.Fprint(w, strconv.Quote(e.String()))
+}
+
+type VoteTarget string
+
+const (
+	VoteTargetPost    VoteTarget = "POST"
+	VoteTargetComment VoteTarget = "COMMENT"
+)
+
+var AllVoteTarget = []VoteTarget{
+	VoteTargetPost,
+	VoteTargetComment,
+}
+
+func (e VoteTarget) IsValid() bool {
+	switch e {
+	case VoteTargetPost, VoteTargetComment:
+		return true
+	}
+	return false
+}
+
+func (e VoteTarget) String() string {
+	return string(e)
+}
+
+func (e *VoteTarget) UnmarshalGQL(v interface{}) error {
+	str, ok := v.(string)
+	if !ok {
+		return fmt.Errorf("enums must be strings")
+	}
+
+	*e = VoteTarget(str)
+	if !e.IsValid() {
+		return fmt.Errorf("%s is not a valid VoteTarget", str)
+	}
+	return nil
+}
+
+func (e VoteTarget) MarshalGQL(w io.Writer) {
+	fmt.Fprint(w, strconv.Quote(e.String()))
+}
@@ -0,0 +1,139 @@
+package graph
+
+import (
+	"PostCommentService/auth"
+	"PostCommentService/db"
+	"PostCommentService/graph/model"
+	"strings"
+	"testing"
+
+	"github.com/99designs/gqlgen/client"
+)
+
+func TestModeration(t *testing.T) {
+	store := db.NewMemoryStore()
+	store.CreatePost("Title", "Content", "alice", nil, model.PostStatusPublished, nil)
+	store.CreateComment(1, "alice", "Spam", nil)
+	store.CreateComment(1, "alice", "Fine", nil)
+	fine := 2
+	store.CreateComment(1, "dave", "Reply", &fine)
+	store.CreateComment(1, "dave", "Rude reply", &fine)
+
+	bob := newTestClient(store, &auth.User{ID: "bob"})
+	moderator := newTestClient(store, &auth.User{ID: "carol", Roles: []string{auth.RoleModerator}})
+
+	var reported struct {
+		ReportComment struct {
+			ID     int
+			Status string
+		}
+	}
+	if err := bob.Post(`mutation { reportComment(id: 1, reason: "spam") { id status } }`, &reported); err != nil {
+		t.Fatalf("error was not expected while reporting comment: %s", err)
+	}
+	if reported.ReportComment.Status != "OPEN" {
+		t.Errorf("unexpected report: %+v", reported.ReportComment)
+	}
+
+	var resp map[string]interface{}
+	err := bob.Post(`{ moderationQueue { totalCount } }`, &resp)
+	if err == nil || !strings.Contains(err.Error(), CodeForbidden) {
+		t.Errorf("expected %s, got %v", CodeForbidden, err)
+	}
+
+	var queue struct {
+		ModerationQueue struct {
+			TotalCount int
+			Edges      []struct {
+				Node struct {
+					Reason  string
+					Comment struct{ Content string }
+				}
+			}
+		}
+	}
+	if err := moderator.Post(`{ moderationQueue { totalCount edges { node { reason comment { content } } } } }`, &queue); err != nil {
+		t.Fatalf("error was not expected while reading queue: %s", err)
+	}
+	if queue.ModerationQueue.TotalCount != 1 || queue.ModerationQueue.Edges[0].Node.Comment.Content != "Spam" {
+		t.Errorf("unexpected queue: %+v", queue.ModerationQueue)
+	}
+
+	var resolved struct {
+		ResolveReport struct {
+			Status     string
+			Action     string
+			ResolvedBy string
+		}
+	}
+	if err := moderator.Post(`mutation { resolveReport(id: 1, action: HIDE_COMMENT) { status action resolvedBy } }`, &resolved); err != nil {
+		t.Fatalf("error was not expected while resolving report: %s", err)
+	}
+	if resolved.ResolveReport.Status != "RESOLVED" || resolved.ResolveReport.ResolvedBy != "carol" {
+		t.Errorf("unexpected report: %+v", resolved.ResolveReport)
+	}
+
+	// Скрытый комментарий видят только модераторы
+	var comments struct {
+		Post struct {
+			Comments struct {
+				TotalCount int
+				Edges      []struct {
+					Node struct{ IsHidden bool }
+				}
+			}
+		}
+	}
+	query := `{ post(id: 1) { comments { totalCount edges { node { isHidden } } } } }`
+	if err := bob.Post(query, &comments); err != nil || comments.Post.Comments.TotalCount != 1 {
+		t.Errorf("expected 1 visible comment for bob, got %+v, %v", comments.Post.Comments, err)
+	}
+	if err := moderator.Post(query, &comments); err != nil || comments.Post.Comments.TotalCount != 2 || !comments.Post.Comments.Edges[0].Node.IsHidden {
+		t.Errorf("expected the hidden comment for the moderator, got %+v, %v", comments.Post.Comments, err)
+	}
+
+	// Ответ в скрытой ветке ушёл бы подписчикам commentAdded, поэтому он запрещён
+	err = bob.Post(`mutation { createComment(postId: 1, parentId: 1, content: "Me too") { id } }`, &resp)
+	if err == nil || !strings.Contains(err.Error(), CodeInvalidParent) {
+		t.Errorf("expected %s for a reply to a hidden comment, got %v", CodeInvalidParent, err)
+	}
+
+	// replyCount не выдаёт скрытые ответы, как и child
+	if err := moderator.Post(`mutation { reportComment(id: 4, reason: "rude") { id } }`, &resp); err != nil {
+		t.Fatalf("error was not expected while reporting reply: %s", err)
+	}
+	if err := moderator.Post(`mutation { resolveReport(id: 2, action: HIDE_COMMENT) { id } }`, &resp); err != nil {
+		t.Fatalf("error was not expected while hiding reply: %s", err)
+	}
+	var thread struct {
+		Post struct {
+			Comments struct {
+				Edges []struct {
+					Node struct {
+						ID         int
+						ReplyCount int
+						Child      struct{ TotalCount int }
+					}
+				}
+			}
+		}
+	}
+	threadQuery := `{ post(id: 1) { comments { edges { node { id replyCount child { totalCount } } } } } }`
+	replies := func(viewer *client.Client) (int, int, error) {
+		if err := viewer.Post(threadQuery, &thread); err != nil {
+			return 0, 0, err
+		}
+		for _, edge := range thread.Post.Comments.Edges {
+			if edge.Node.ID == fine {
+				return edge.Node.ReplyCount, edge.Node.Child.TotalCount, nil
+			}
+		}
+		return 0, 0, nil
+	}
+	if count, total, err := replies(bob); err != nil || count != 1 || total != 1 {
+		t.Errorf("expected 1 visible reply for bob, got %d/%d, %v", count, total, err)
+	}
+	if count, total, err := replies(moderator); err != nil || count != 2 || total != 2 {
+		t.Errorf("expected 2 replies for the moderator, got %d/%d, %v", count, total, err)
+	}
+}
@@ -0,0 +1,29 @@
+package graph
+
+import (
+	"PostCommentService/auth"
+	"PostCommentService/db"
+	"PostCommentService/graph/model"
+	"strings"
+	"testing"
+
+	"github.com/99designs/gqlgen/client"
+)
+
+func TestCommentLengthInCharacters(t *testing.T) {
+	store := db.NewMemoryStore()
+	store.CreatePost("Title", "Content", "alice", nil, model.PostStatusPublished, nil)
+	c := newTestClient(store, &auth.User{ID: "bob"})
+
+	// 2000 кириллических символов занимают 4000 байт, но укладываются в ограничение
+	var resp map[string]interface{}
+	query := `mutation($content: String!) { createComment(postId: 1, content: $content) { id } }`
+	if err := c.Post(query, &resp, client.Var("content", strings.Repeat("я", 2000))); err != nil {
+		t.Errorf("error was not expected: %s", err)
+	}
+
+	err := c.Post(query, &resp, client.Var("content", strings.Repeat("я", 2001)))
+	if err == nil || !strings.Contains(err.Error(), CodeContentTooLong) || !strings.Contains(err.Error(), `"rule":"maxLength"`) {
+		t.Errorf("expected %s for maxLength, got %v", CodeContentTooLong, err)
+	}
+}
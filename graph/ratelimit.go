@@ -0,0 +1,55 @@
+package graph
+
+import (
+	"PostCommentService/auth"
+	"PostCommentService/ratelimit"
+	"context"
+	"errors"
+	"log"
+
+	"github.com/99designs/gqlgen/graphql"
+)
+
+// RateLimit ограничивает частоту мутаций отдельно для пользователя (users)
+// и для адреса клиента (ips). Проверка выполняется до директив, поэтому
+// запросы без токена тоже учитываются по адресу. Общий пользователь
+// анонимного режима ограничивается только по адресу.
+func RateLimit(limiter ratelimit.Limiter, users, ips ratelimit.Limits) graphql.FieldMiddleware {
+	return func(ctx context.Context, next graphql.Resolver) (interface{}, error) {
+		fc := graphql.GetFieldContext(ctx)
+		if fc == nil || fc.Object != "Mutation" {
+			return next(ctx)
+		}
+		name := fc.Field.Name
+
+		// Адрес проверяется первым: отказ по общей корзине адреса не должен
+		// тратить личную корзину пользователя
+		if limit, ok := ips[name]; ok {
+			if ip := ratelimit.ClientIPFromContext(ctx); ip != "" {
+				if err := take(limiter, name+":ip:"+ip, limit); err != nil {
+					return nil, err
+				}
+			}
+		}
+		if limit, ok := users[name]; ok {
+			if user := auth.ForContext(ctx); user != nil && user != auth.Anonymous {
+				if err := take(limiter, name+":user:"+user.ID, limit); err != nil {
+					return nil, err
+				}
+			}
+		}
+
+		return next(ctx)
+	}
+}
+
+// take пропускает запрос, если хранилище корзин недоступно:
+// сбой ограничителя не должен останавливать запись
+func take(limiter ratelimit.Limiter, key string, limit ratelimit.Limit) error {
+	err := limiter.Take(key, limit)
+	if err != nil && !errors.Is(err, ratelimit.ErrLimited) {
+		log.Printf("rate limiter: %v", err)
+		return nil
+	}
+	return err
+}
@@ -0,0 +1,108 @@
+package graph
+
+import (
+	"PostCommentService/auth"
+	"PostCommentService/db"
+	"PostCommentService/graph/loaders"
+	"PostCommentService/graph/model"
+	"PostCommentService/policy"
+	"PostCommentService/pubsub"
+	"PostCommentService/ratelimit"
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/99designs/gqlgen/client"
+	"github.com/99designs/gqlgen/graphql/handler"
+	"github.com/99designs/gqlgen/graphql/handler/transport"
+)
+
+func newRateLimitedClient(store db.Store, limiter ratelimit.Limiter, users, ips ratelimit.Limits, user *auth.User, ip string) *client.Client {
+	srv := handler.New(NewExecutableSchema(Config{
+		Resolvers:  NewResolver(store, pubsub.NewBroker(), DefaultReactions, policy.FromConfig(policy.DefaultConfig)),
+		Directives: NewDirectives(store),
+	}))
+	srv.AddTransport(transport.POST{})
+	srv.AroundOperations(loaders.Middleware(store))
+	srv.AroundFields(RateLimit(limiter, users, ips))
+	srv.SetErrorPresenter(ErrorPresenter)
+
+	return client.New(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := ratelimit.WithClientIP(r.Context(), ip)
+		if user != nil {
+			ctx = auth.WithUser(ctx, user)
+		}
+		srv.ServeHTTP(w, r.WithContext(ctx))
+	}))
+}
+
+func TestRateLimit(t *testing.T) {
+	store := db.NewMemoryStore()
+	store.CreatePost("Title", "Content", "alice", nil, model.PostStatusPublished, nil)
+
+	limiter := ratelimit.NewMemoryLimiter()
+	users := ratelimit.Limits{"createComment": {Requests: 1, Per: time.Minute}}
+	ips := ratelimit.Limits{"createComment": {Requests: 3, Per: time.Minute}}
+	alice := newRateLimitedClient(store, limiter, users, ips, &auth.User{ID: "alice"}, "10.0.0.1")
+	bob := newRateLimitedClient(store, limiter, users, ips, &auth.User{ID: "bob"}, "10.0.0.1")
+	anonymous := newRateLimitedClient(store, limiter, users, ips, nil, "10.0.0.1")
+
+	var resp map[string]interface{}
+	mutation := `mutation { createComment(postId: 1, content: "Comment") { id } }`
+	if err := alice.Post(mutation, &resp); err != nil {
+		t.Fatalf("first comment should be allowed: %s", err)
+	}
+
+	err := alice.Post(mutation, &resp)
+	if err == nil || !strings.Contains(err.Error(), CodeRateLimited) || !strings.Contains(err.Error(), `"retryAfter":60`) {
+		t.Errorf("expected %s with retryAfter, got %v", CodeRateLimited, err)
+	}
+
+	// Запросы, которые не ограничены, проходят
+	if err := alice.Post(`{ post(id: 1) { id } }`, &resp); err != nil {
+		t.Errorf("queries should not be limited: %s", err)
+	}
+
+	// У bob своя корзина, но адрес общий: отклонённый запрос alice тоже потратил корзину адреса,
+	// поэтому комментарий bob с этого адреса - последний
+	if err := bob.Post(mutation, &resp); err != nil {
+		t.Errorf("bob should not be limited by alice: %s", err)
+	}
+
+	// Запрос без токена тоже тратит корзину адреса до проверки @auth
+	err = anonymous.Post(mutation, &resp)
+	if err == nil || !strings.Contains(err.Error(), CodeRateLimited) {
+		t.Errorf("expected %s for the exhausted address, got %v", CodeRateLimited, err)
+	}
+
+	// Отказ по адресу не тратит корзину пользователя: с другого адреса carol ещё может писать
+	carol := newRateLimitedClient(store, limiter, users, ips, &auth.User{ID: "carol"}, "10.0.0.1")
+	err = carol.Post(mutation, &resp)
+	if err == nil || !strings.Contains(err.Error(), CodeRateLimited) {
+		t.Errorf("expected %s for the exhausted address, got %v", CodeRateLimited, err)
+	}
+	carol = newRateLimitedClient(store, limiter, users, ips, &auth.User{ID: "carol"}, "10.0.0.2")
+	if err := carol.Post(mutation, &resp); err != nil {
+		t.Errorf("carol's own bucket should be untouched: %s", err)
+	}
+}
+
+type failingLimiter struct{}
+
+func (failingLimiter) Take(string, ratelimit.Limit) error {
+	return errors.New("connection refused")
+}
+
+func TestRateLimitFailsOpen(t *testing.T) {
+	store := db.NewMemoryStore()
+	store.CreatePost("Title", "Content", "alice", nil, model.PostStatusPublished, nil)
+	limits := ratelimit.Limits{"createComment": {Requests: 1, Per: time.Minute}}
+	c := newRateLimitedClient(store, failingLimiter{}, limits, limits, &auth.User{ID: "alice"}, "10.0.0.1")
+
+	var resp map[string]interface{}
+	if err := c.Post(`mutation { createComment(postId: 1, content: "Comment") { id } }`, &resp); err != nil {
+		t.Errorf("limiter errors should not block requests: %s", err)
+	}
+}
@@ -0,0 +1,70 @@
+package graph
+
+import (
+	"PostCommentService/auth"
+	"PostCommentService/db"
+	"PostCommentService/graph/model"
+	"strings"
+	"testing"
+)
+
+func TestReactions(t *testing.T) {
+	store := db.NewMemoryStore()
+	store.CreatePost("Title", "Content", "alice", nil, model.PostStatusPublished, nil)
+	store.CreateComment(1, "alice", "Comment", nil)
+
+	bob := newTestClient(store, &auth.User{ID: "bob"})
+	var added struct {
+		AddReaction []struct {
+			Emoji       string
+			Count       int
+			ReactedByMe bool
+		}
+	}
+	if err := bob.Post(`mutation { addReaction(commentId: 1, emoji: "🚀") { emoji count reactedByMe } }`, &added); err != nil {
+		t.Fatalf("error was not expected while adding reaction: %s", err)
+	}
+	if len(added.AddReaction) != 1 || added.AddReaction[0].Count != 1 || !added.AddReaction[0].ReactedByMe {
+		t.Errorf("unexpected reactions: %+v", added.AddReaction)
+	}
+
+	var invalid map[string]interface{}
+	err := bob.Post(`mutation { addReaction(commentId: 1, emoji: "🦄") { count } }`, &invalid)
+	if err == nil || !strings.Contains(err.Error(), CodeBadUserInput) {
+		t.Errorf("expected %s, got %v", CodeBadUserInput, err)
+	}
+
+	var resp struct {
+		Post struct {
+			Comments struct {
+				Edges []struct {
+					Node struct {
+						Reactions []struct {
+							Emoji       string
+							Count       int
+							ReactedByMe bool
+						}
+					}
+				}
+			}
+		}
+	}
+	query := `{ post(id: 1) { comments { edges { node { reactions { emoji count reactedByMe } } } } } }`
+	if err := newTestClient(store, &auth.User{ID: "carol"}).Post(query, &resp); err != nil {
+		t.Fatalf("error was not expected: %s", err)
+	}
+	reactions := resp.Post.Comments.Edges[0].Node.Reactions
+	if len(reactions) != 1 || reactions[0].Emoji != "🚀" || reactions[0].Count != 1 || reactions[0].ReactedByMe {
+		t.Errorf("unexpected reactions for carol: %+v", reactions)
+	}
+
+	var removed struct {
+		RemoveReaction []struct{ Count int }
+	}
+	if err := bob.Post(`mutation { removeReaction(commentId: 1, emoji: "🚀") { count } }`, &removed); err != nil {
+		t.Fatalf("error was not expected while removing reaction: %s", err)
+	}
+	if len(removed.RemoveReaction) != 0 {
+		t.Errorf("expected no reactions, got %+v", removed.RemoveReaction)
+	}
+}
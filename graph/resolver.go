@@ -1,14 +1,67 @@
 package graph
 
 import (
+	"PostCommentService/auth"
 	"PostCommentService/db"
+	"PostCommentService/graph/loaders"
+	"PostCommentService/graph/model"
+	"PostCommentService/policy"
+	"PostCommentService/pubsub"
+	"context"
 )
 
+// DefaultReactions - набор реакций на комментарии по умолчанию
+var DefaultReactions = []string{"👍", "👎", "😄", "🎉", "😕", "❤️", "🚀", "👀"}
+
 type Resolver struct {
-	store db.Store
+	store  db.Store
+	events pubsub.PubSub
+	// Реакции, которые можно поставить комментарию
+	reactions map[string]bool
+	// Правила, по которым проверяются посты и комментарии перед записью
+	policy *policy.Policy
+}
+
+func NewResolver(store db.Store, events pubsub.PubSub, reactions []string, contentPolicy *policy.Policy) *Resolver {
+	allowed := make(map[string]bool, len(reactions))
+	for _, emoji := range reactions {
+		allowed[emoji] = true
+	}
+
+	return &Resolver{
+		store:     store,
+		events:    events,
+		reactions: allowed,
+		policy:    contentPolicy,
+	}
+}
+
+// viewerID возвращает ID пользователя запроса, у анонимного запроса - пустую строку
+func viewerID(ctx context.Context) string {
+	if user := auth.ForContext(ctx); user != nil {
+		return user.ID
+	}
+	return ""
+}
+
+func intValue(v *int) int {
+	if v == nil {
+		return 0
+	}
+	return *v
+}
+
+func stringValue(v *string) string {
+	if v == nil {
+		return ""
+	}
+	return *v
 }
 
-func NewResolver(useMemory bool) *Resolver {
-	store := db.NewStore(useMemory)
-	return &Resolver{store: store}
+func pageKey(id int, first *int, after *string, sort *model.CommentSort) loaders.PageKey {
+	key := loaders.PageKey{ID: id, First: intValue(first), After: stringValue(after), Sort: model.CommentSortOld}
+	if sort != nil {
+		key.Sort = *sort
+	}
+	return key
 }
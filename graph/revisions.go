@@ -0,0 +1,36 @@
+package graph
+
+import (
+	"PostCommentService/auth"
+	"PostCommentService/diff"
+	"PostCommentService/graph/model"
+	"context"
+)
+
+// requireHistoryAccess пропускает к истории правок автора, модераторов и администраторов:
+// в ней остаётся текст, который автор мог убрать из публичной версии
+func requireHistoryAccess(ctx context.Context, author string) error {
+	user, err := auth.RequireUser(ctx)
+	if err != nil {
+		return err
+	}
+	if !canManage(user, author) {
+		return auth.ErrForbidden
+	}
+	return nil
+}
+
+var diffOps = map[diff.Op]model.DiffOp{
+	diff.Equal:  model.DiffOpEqual,
+	diff.Insert: model.DiffOpInsert,
+	diff.Delete: model.DiffOpDelete,
+}
+
+func diffLines(a, b string) []*model.DiffLine {
+	lines := diff.Lines(a, b)
+	result := make([]*model.DiffLine, 0, len(lines))
+	for _, line := range lines {
+		result = append(result, &model.DiffLine{Op: diffOps[line.Op], Text: line.Text})
+	}
+	return result
+}
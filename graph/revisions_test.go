@@ -0,0 +1,73 @@
+package graph
+
+import (
+	"PostCommentService/auth"
+	"PostCommentService/db"
+	"PostCommentService/graph/model"
+	"strings"
+	"testing"
+)
+
+func TestRevisions(t *testing.T) {
+	store := db.NewMemoryStore()
+	store.CreatePost("Title", "one\ntwo", "alice", nil, model.PostStatusPublished, nil)
+	alice := newTestClient(store, &auth.User{ID: "alice"})
+
+	var updated struct {
+		UpdatePost struct{ EditedAt *string }
+	}
+	if err := alice.Post(`mutation { updatePost(id: 1, title: "Title", content: "one\nthree") { editedAt } }`, &updated); err != nil {
+		t.Fatalf("error was not expected while updating post: %s", err)
+	}
+	if updated.UpdatePost.EditedAt == nil {
+		t.Error("editedAt should be set after update")
+	}
+
+	var resp struct {
+		Post struct {
+			Revisions struct {
+				TotalCount int
+				Edges      []struct {
+					Node struct {
+						Number  int
+						Content string
+						Editor  string
+					}
+				}
+			}
+		}
+		PostRevisionDiff struct {
+			Content []struct {
+				Op   string
+				Text string
+			}
+		}
+	}
+	query := `{
+		post(id: 1) { revisions { totalCount edges { node { number content editor } } } }
+		postRevisionDiff(id: 1, from: 1) { content { op text } }
+	}`
+	if err := alice.Post(query, &resp); err != nil {
+		t.Fatalf("error was not expected while reading revisions: %s", err)
+	}
+
+	revisions := resp.Post.Revisions
+	if revisions.TotalCount != 1 || revisions.Edges[0].Node.Content != "one\ntwo" || revisions.Edges[0].Node.Editor != "alice" {
+		t.Errorf("unexpected revisions: %+v", revisions)
+	}
+
+	var ops []string
+	for _, line := range resp.PostRevisionDiff.Content {
+		ops = append(ops, line.Op+" "+line.Text)
+	}
+	if got := strings.Join(ops, ", "); got != "EQUAL one, DELETE two, INSERT three" {
+		t.Errorf("unexpected diff: %s", got)
+	}
+
+	// Чужому пользователю история недоступна
+	var denied map[string]interface{}
+	err := newTestClient(store, &auth.User{ID: "bob"}).Post(`{ post(id: 1) { revisions { totalCount } } }`, &denied)
+	if err == nil || !strings.Contains(err.Error(), `"code":"`+CodeForbidden+`"`) {
+		t.Errorf("expected %s, got %v", CodeForbidden, err)
+	}
+}
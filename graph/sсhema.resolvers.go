@@ -5,64 +5,371 @@ package graph
 // Code generated by github.com/99designs/gqlgen version v0.17.47
 
 import (
+	"PostCommentService/auth"
+	"PostCommentService/db"
+	"PostCommentService/graph/loaders"
 	"PostCommentService/graph/model"
+	"PostCommentService/policy"
 	"context"
+	"errors"
 	"fmt"
+	"time"
 )
 
+// ReplyCount is the resolver for the replyCount field.
+func (r *commentResolver) ReplyCount(ctx context.Context, obj *model.Comment) (int, error) {
+	// Скрытые ответы видят только модераторы, как и в списке child
+	if user := auth.ForContext(ctx); user != nil && user.HasRole(auth.RoleModerator, auth.RoleAdmin) {
+		return obj.ReplyCount, nil
+	}
+	return obj.ReplyCount - obj.HiddenReplyCount, nil
+}
+
+// Child is the resolver for the child field.
+func (r *commentResolver) Child(ctx context.Context, obj *model.Comment, first *int, after *string, sort *model.CommentSort) (*model.CommentConnection, error) {
+	return loaders.For(ctx).Replies.Load(ctx, pageKey(obj.ID, first, after, sort))()
+}
+
+// MyVote is the resolver for the myVote field.
+func (r *commentResolver) MyVote(ctx context.Context, obj *model.Comment) (int, error) {
+	return loaders.For(ctx).MyVotes.Load(ctx, loaders.VoteKey{Target: model.VoteTargetComment, ID: obj.ID})()
+}
+
+// Reactions is the resolver for the reactions field.
+func (r *commentResolver) Reactions(ctx context.Context, obj *model.Comment) ([]*model.Reaction, error) {
+	return loaders.For(ctx).Reactions.Load(ctx, obj.ID)()
+}
+
+// Revisions is the resolver for the revisions field.
+func (r *commentResolver) Revisions(ctx context.Context, obj *model.Comment, first *int, after *string) (*model.RevisionConnection, error) {
+	if err := requireHistoryAccess(ctx, obj.Author); err != nil {
+		return nil, err
+	}
+	return r.store.GetCommentRevisions(obj.ID, intValue(first), stringValue(after))
+}
+
 // CreatePost is the resolver for the createPost field.
-func (r *mutationResolver) CreatePost(ctx context.Context, title string, content string, author string) (*model.Post, error) {
-	return r.store.CreatePost(title, content, author)
+func (r *mutationResolver) CreatePost(ctx context.Context, title string, content string, tags []string, status *model.PostStatus, publishAt *time.Time) (*model.Post, error) {
+	user, err := auth.RequireUser(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if err := r.policy.Check(policy.Content{Kind: policy.KindPost, Title: title, Content: content}); err != nil {
+		return nil, err
+	}
+	postStatus := model.PostStatusPublished
+	if status != nil {
+		postStatus = *status
+	}
+	return r.store.CreatePost(title, content, user.ID, tags, postStatus, publishAt)
 }
 
-// UpdatePost is the resolver for the updatePost field.
-func (r *mutationResolver) UpdatePost(ctx context.Context, id int, title string, content string) (*model.Post, error) {
-	return r.store.UpdatePost(id, title, content)
+// PublishPost is the resolver for the publishPost field.
+func (r *mutationResolver) PublishPost(ctx context.Context, id int, publishAt *time.Time) (*model.Post, error) {
+	return r.store.PublishPost(id, publishAt)
 }
 
-// DisableComments is the resolver for the disableComments field.
-func (r *mutationResolver) DisableComments(ctx context.Context, postID int) (*model.Post, error) {
-	err := r.store.DisableComments(postID)
+// UpdatePost is the resolver for the updatePost field.
+func (r *mutationResolver) UpdatePost(ctx context.Context, id int, title string, content string, tags []string) (*model.Post, error) {
+	user, err := auth.RequireUser(ctx)
 	if err != nil {
 		return nil, err
 	}
-	return r.store.GetPost(postID, 0, 1)
+	if err := r.policy.Check(policy.Content{Kind: policy.KindPost, Title: title, Content: content}); err != nil {
+		return nil, err
+	}
+	return r.store.UpdatePost(id, title, content, tags, user.ID)
+}
+
+// DisableComments is the resolver for the disableComments field.
+func (r *mutationResolver) DisableComments(ctx context.Context, postID int) (*model.Post, error) {
+	return r.store.SetCommentsEnabled(postID, false)
+}
+
+// SetCommentsEnabled is the resolver for the setCommentsEnabled field.
+func (r *mutationResolver) SetCommentsEnabled(ctx context.Context, postID int, enabled bool) (*model.Post, error) {
+	return r.store.SetCommentsEnabled(postID, enabled)
+}
+
+// SetAutoLock is the resolver for the setAutoLock field.
+func (r *mutationResolver) SetAutoLock(ctx context.Context, postID int, afterDays *int) (*model.Post, error) {
+	return r.store.SetAutoLock(postID, afterDays)
 }
 
 // CreateComment is the resolver for the createComment field.
-func (r *mutationResolver) CreateComment(ctx context.Context, postID int, author string, content string, parentID *int) (*model.Comment, error) {
-	return r.store.CreateComment(postID, author, content, parentID)
+func (r *mutationResolver) CreateComment(ctx context.Context, postID int, content string, parentID *int) (*model.Comment, error) {
+	user, err := auth.RequireUser(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if err := r.policy.Check(policy.Content{Kind: policy.KindComment, Content: content}); err != nil {
+		return nil, err
+	}
+	comment, err := r.store.CreateComment(postID, user.ID, content, parentID)
+	if err != nil {
+		return nil, err
+	}
+	r.events.Publish(comment)
+	return comment, nil
 }
 
 // UpdateComment is the resolver for the updateComment field.
 func (r *mutationResolver) UpdateComment(ctx context.Context, id int, content string) (*model.Comment, error) {
-	return r.store.UpdateComment(id, content)
+	user, err := auth.RequireUser(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if err := r.policy.Check(policy.Content{Kind: policy.KindComment, Content: content}); err != nil {
+		return nil, err
+	}
+	return r.store.UpdateComment(id, content, user.ID)
+}
+
+// DeletePost is the resolver for the deletePost field.
+func (r *mutationResolver) DeletePost(ctx context.Context, id int) (bool, error) {
+	if err := r.store.DeletePost(id); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// DeleteComment is the resolver for the deleteComment field.
+func (r *mutationResolver) DeleteComment(ctx context.Context, id int) (bool, error) {
+	if err := r.store.DeleteComment(id); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// Vote is the resolver for the vote field.
+func (r *mutationResolver) Vote(ctx context.Context, targetType model.VoteTarget, targetID int, value int) (*model.VoteResult, error) {
+	user, err := auth.RequireUser(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return r.store.Vote(targetType, targetID, user.ID, value)
+}
+
+// AddReaction is the resolver for the addReaction field.
+func (r *mutationResolver) AddReaction(ctx context.Context, commentID int, emoji string) ([]*model.Reaction, error) {
+	user, err := auth.RequireUser(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if !r.reactions[emoji] {
+		return nil, fmt.Errorf("%w: reaction %q is not allowed", db.ErrInvalidArgument, emoji)
+	}
+	return r.store.AddReaction(commentID, user.ID, emoji)
+}
+
+// RemoveReaction is the resolver for the removeReaction field.
+func (r *mutationResolver) RemoveReaction(ctx context.Context, commentID int, emoji string) ([]*model.Reaction, error) {
+	user, err := auth.RequireUser(ctx)
+	if err != nil {
+		return nil, err
+	}
+	// Набор не проверяется, чтобы реакцию, исключённую из него, можно было снять
+	return r.store.RemoveReaction(commentID, user.ID, emoji)
+}
+
+// ReportComment is the resolver for the reportComment field.
+func (r *mutationResolver) ReportComment(ctx context.Context, id int, reason string) (*model.Report, error) {
+	user, err := auth.RequireUser(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return r.store.ReportComment(id, user.ID, reason)
+}
+
+// ResolveReport is the resolver for the resolveReport field.
+func (r *mutationResolver) ResolveReport(ctx context.Context, id int, action model.ReportAction) (*model.Report, error) {
+	user, err := auth.RequireUser(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return r.store.ResolveReport(id, action, user.ID)
+}
+
+// PurgePost is the resolver for the purgePost field.
+func (r *mutationResolver) PurgePost(ctx context.Context, id int) (bool, error) {
+	if err := r.store.PurgePost(id); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// PurgeComment is the resolver for the purgeComment field.
+func (r *mutationResolver) PurgeComment(ctx context.Context, id int) (bool, error) {
+	if err := r.store.PurgeComment(id); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// Comments is the resolver for the comments field.
+func (r *postResolver) Comments(ctx context.Context, obj *model.Post, first *int, after *string, sort *model.CommentSort) (*model.CommentConnection, error) {
+	return loaders.For(ctx).Comments.Load(ctx, pageKey(obj.ID, first, after, sort))()
+}
+
+// Tags is the resolver for the tags field.
+func (r *postResolver) Tags(ctx context.Context, obj *model.Post) ([]string, error) {
+	return loaders.For(ctx).Tags.Load(ctx, obj.ID)()
+}
+
+// MyVote is the resolver for the myVote field.
+func (r *postResolver) MyVote(ctx context.Context, obj *model.Post) (int, error) {
+	return loaders.For(ctx).MyVotes.Load(ctx, loaders.VoteKey{Target: model.VoteTargetPost, ID: obj.ID})()
+}
+
+// Revisions is the resolver for the revisions field.
+func (r *postResolver) Revisions(ctx context.Context, obj *model.Post, first *int, after *string) (*model.RevisionConnection, error) {
+	if err := requireHistoryAccess(ctx, obj.Author); err != nil {
+		return nil, err
+	}
+	return r.store.GetPostRevisions(obj.ID, intValue(first), stringValue(after))
 }
 
 // Posts is the resolver for the posts field.
-func (r *queryResolver) Posts(ctx context.Context) ([]*model.Post, error) {
-	return r.store.GetPosts()
+func (r *queryResolver) Posts(ctx context.Context, first *int, after *string, sort *model.PostSort, filter *model.PostFilter) (*model.PostConnection, error) {
+	postSort := model.PostSortID
+	if sort != nil {
+		postSort = *sort
+	}
+	return r.store.GetPosts(intValue(first), stringValue(after), postSort, filter, viewerID(ctx))
 }
 
 // Post is the resolver for the post field.
-func (r *queryResolver) Post(ctx context.Context, id int, commentsOffset *int, commentsLimit *int) (*model.Post, error) {
-	return r.store.GetPost(id, *commentsOffset, *commentsLimit)
+func (r *queryResolver) Post(ctx context.Context, id int) (*model.Post, error) {
+	post, err := r.store.GetPost(id)
+	if err != nil {
+		return nil, err
+	}
+	// Неопубликованный пост для всех, кроме автора, не существует
+	if post.Status != model.PostStatusPublished && post.Author != viewerID(ctx) {
+		return nil, &db.NotFoundError{Entity: "post", ID: id}
+	}
+	return post, nil
+}
+
+// Tags is the resolver for the tags field.
+func (r *queryResolver) Tags(ctx context.Context, first *int) ([]*model.Tag, error) {
+	return r.store.GetTags(intValue(first))
+}
+
+// SearchPosts is the resolver for the searchPosts field.
+func (r *queryResolver) SearchPosts(ctx context.Context, query string, first *int, after *string) (*model.PostSearchConnection, error) {
+	return r.store.SearchPosts(query, intValue(first), stringValue(after))
+}
+
+// SearchComments is the resolver for the searchComments field.
+func (r *queryResolver) SearchComments(ctx context.Context, postID *int, query string, first *int, after *string) (*model.CommentSearchConnection, error) {
+	return r.store.SearchComments(postID, query, intValue(first), stringValue(after))
+}
+
+// PostRevisionDiff is the resolver for the postRevisionDiff field.
+func (r *queryResolver) PostRevisionDiff(ctx context.Context, id int, from int, to *int) (*model.RevisionDiff, error) {
+	older, err := r.store.GetPostRevision(id, from)
+	if err != nil {
+		return nil, err
+	}
+
+	var title, content string
+	if to == nil {
+		post, err := r.store.GetPost(id)
+		if err != nil {
+			return nil, err
+		}
+		title, content = post.Title, post.Content
+	} else {
+		newer, err := r.store.GetPostRevision(id, *to)
+		if err != nil {
+			return nil, err
+		}
+		title, content = stringValue(newer.Title), newer.Content
+	}
+
+	return &model.RevisionDiff{
+		From:    from,
+		To:      to,
+		Title:   diffLines(stringValue(older.Title), title),
+		Content: diffLines(older.Content, content),
+	}, nil
+}
+
+// CommentRevisionDiff is the resolver for the commentRevisionDiff field.
+func (r *queryResolver) CommentRevisionDiff(ctx context.Context, id int, from int, to *int) (*model.RevisionDiff, error) {
+	older, err := r.store.GetCommentRevision(id, from)
+	if err != nil {
+		return nil, err
+	}
+
+	var content string
+	if to == nil {
+		comment, err := r.store.GetComment(id)
+		if err != nil {
+			return nil, err
+		}
+		content = comment.Content
+	} else {
+		newer, err := r.store.GetCommentRevision(id, *to)
+		if err != nil {
+			return nil, err
+		}
+		content = newer.Content
+	}
+
+	return &model.RevisionDiff{
+		From:    from,
+		To:      to,
+		Title:   []*model.DiffLine{},
+		Content: diffLines(older.Content, content),
+	}, nil
+}
+
+// ModerationQueue is the resolver for the moderationQueue field.
+func (r *queryResolver) ModerationQueue(ctx context.Context, first *int, after *string, status *model.ReportStatus) (*model.ReportConnection, error) {
+	reportStatus := model.ReportStatusOpen
+	if status != nil {
+		reportStatus = *status
+	}
+	return r.store.GetReports(intValue(first), stringValue(after), reportStatus)
+}
+
+// Comment is the resolver for the comment field.
+func (r *reportResolver) Comment(ctx context.Context, obj *model.Report) (*model.Comment, error) {
+	comment, err := r.store.GetComment(obj.CommentID)
+	if errors.Is(err, db.ErrNotFound) {
+		return nil, nil
+	}
+	return comment, err
 }
 
 // CommentAdded is the resolver for the commentAdded field.
 func (r *subscriptionResolver) CommentAdded(ctx context.Context, postID int) (<-chan *model.Comment, error) {
-	panic(fmt.Errorf("not implemented: CommentAdded - commentAdded"))
+	return r.events.Subscribe(ctx, postID), nil
 }
 
+// Comment returns CommentResolver implementation.
+func (r *Resolver) Comment() CommentResolver { return &commentResolver{r} }
+
 // Mutation returns MutationResolver implementation.
 func (r *Resolver) Mutation() MutationResolver { return &mutationResolver{r} }
 
+// Post returns PostResolver implementation.
+func (r *Resolver) Post() PostResolver { return &postResolver{r} }
+
 // Query returns QueryResolver implementation.
 func (r *Resolver) Query() QueryResolver { return &queryResolver{r} }
 
+// Report returns ReportResolver implementation.
+func (r *Resolver) Report() ReportResolver { return &reportResolver{r} }
+
 // Subscription returns SubscriptionResolver implementation.
 func (r *Resolver) Subscription() SubscriptionResolver { return &subscriptionResolver{r} }
 
+type commentResolver struct{ *Resolver }
 type mutationResolver struct{ *Resolver }
+type postResolver struct{ *Resolver }
 type queryResolver struct{ *Resolver }
+type reportResolver struct{ *Resolver }
 type subscriptionResolver struct{ *Resolver }
@@ -0,0 +1,43 @@
+package graph
+
+import (
+	"PostCommentService/auth"
+	"PostCommentService/db"
+	"PostCommentService/graph/model"
+	"testing"
+)
+
+func TestTags(t *testing.T) {
+	store := db.NewMemoryStore()
+	c := newTestClient(store, &auth.User{ID: "alice"})
+
+	var created struct {
+		CreatePost struct{ Tags []string }
+	}
+	if err := c.Post(`mutation { createPost(title: "Title", content: "Content", tags: ["Go", " web  dev "]) { tags } }`, &created); err != nil {
+		t.Fatalf("error was not expected while creating post: %s", err)
+	}
+	if len(created.CreatePost.Tags) != 2 || created.CreatePost.Tags[1] != "web dev" {
+		t.Errorf("unexpected tags: %v", created.CreatePost.Tags)
+	}
+	store.CreatePost("Untagged", "Content", "alice", nil, model.PostStatusPublished, nil)
+
+	var resp struct {
+		Posts struct {
+			TotalCount int
+			Edges      []struct {
+				Node struct{ Tags []string }
+			}
+		}
+		Tags []struct {
+			Name  string
+			Count int
+		}
+	}
+	if err := c.Post(`{ posts(filter: {tags: ["GO"]}) { totalCount edges { node { tags } } } tags { name count } }`, &resp); err != nil {
+		t.Fatalf("error was not expected: %s", err)
+	}
+	if resp.Posts.TotalCount != 1 || len(resp.Tags) != 2 || resp.Tags[0].Name != "go" || resp.Tags[0].Count != 1 {
+		t.Errorf("unexpected response: %+v", resp)
+	}
+}
@@ -0,0 +1,70 @@
+package graph
+
+import (
+	"PostCommentService/auth"
+	"PostCommentService/db"
+	"PostCommentService/graph/model"
+	"strings"
+	"testing"
+)
+
+func TestVote(t *testing.T) {
+	store := db.NewMemoryStore()
+	store.CreatePost("Title", "Content", "alice", nil, model.PostStatusPublished, nil)
+	store.CreateComment(1, "alice", "Comment", nil)
+
+	var anonymous map[string]interface{}
+	err := newTestClient(store, nil).Post(`mutation { vote(targetType: POST, targetId: 1, value: 1) { score } }`, &anonymous)
+	if err == nil || !strings.Contains(err.Error(), CodeUnauthenticated) {
+		t.Errorf("expected %s, got %v", CodeUnauthenticated, err)
+	}
+
+	bob := newTestClient(store, &auth.User{ID: "bob"})
+	var voted struct {
+		Vote struct {
+			Score  int
+			MyVote int
+		}
+	}
+	if err := bob.Post(`mutation { vote(targetType: COMMENT, targetId: 1, value: -1) { score myVote } }`, &voted); err != nil {
+		t.Fatalf("error was not expected while voting: %s", err)
+	}
+	if voted.Vote.Score != -1 || voted.Vote.MyVote != -1 {
+		t.Errorf("unexpected vote result: %+v", voted.Vote)
+	}
+
+	var invalid map[string]interface{}
+	err = bob.Post(`mutation { vote(targetType: POST, targetId: 1, value: 5) { score } }`, &invalid)
+	if err == nil || !strings.Contains(err.Error(), CodeBadUserInput) {
+		t.Errorf("expected %s, got %v", CodeBadUserInput, err)
+	}
+
+	query := `{ post(id: 1) { myVote comments(sort: TOP) { edges { node { score myVote } } } } }`
+	var resp struct {
+		Post struct {
+			MyVote   int
+			Comments struct {
+				Edges []struct {
+					Node struct {
+						Score  int
+						MyVote int
+					}
+				}
+			}
+		}
+	}
+	if err := bob.Post(query, &resp); err != nil {
+		t.Fatalf("error was not expected: %s", err)
+	}
+	if node := resp.Post.Comments.Edges[0].Node; resp.Post.MyVote != 0 || node.Score != -1 || node.MyVote != -1 {
+		t.Errorf("unexpected votes for bob: %+v", resp.Post)
+	}
+
+	// Другой пользователь видит общий рейтинг, но не чужой голос
+	if err := newTestClient(store, &auth.User{ID: "carol"}).Post(query, &resp); err != nil {
+		t.Fatalf("error was not expected: %s", err)
+	}
+	if node := resp.Post.Comments.Edges[0].Node; node.Score != -1 || node.MyVote != 0 {
+		t.Errorf("unexpected votes for carol: %+v", node)
+	}
+}
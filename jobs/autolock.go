@@ -0,0 +1,57 @@
+package jobs
+
+import (
+	"context"
+	"log"
+	"time"
+)
+
+// AutoLocker закрывает комментарии к постам с истёкшим сроком автоматического закрытия
+type AutoLocker interface {
+	LockExpiredPosts(now time.Time) ([]int, error)
+}
+
+// AutoLockSweeper периодически применяет правила автоматического закрытия комментариев
+type AutoLockSweeper struct {
+	store    AutoLocker
+	interval time.Duration
+	now      func() time.Time
+}
+
+func NewAutoLockSweeper(store AutoLocker, interval time.Duration) *AutoLockSweeper {
+	return &AutoLockSweeper{
+		store:    store,
+		interval: interval,
+		now:      time.Now,
+	}
+}
+
+// Run закрывает комментарии к постам с истёкшим сроком сразу после запуска и затем
+// каждые interval до завершения ctx. Пост, который не удалось закрыть из-за ошибки,
+// закроет следующий проход: срок по-прежнему истёк.
+func (s *AutoLockSweeper) Run(ctx context.Context) {
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+
+	for {
+		s.Sweep()
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (s *AutoLockSweeper) Sweep() {
+	locked, err := s.store.LockExpiredPosts(s.now())
+	if err != nil {
+		log.Printf("auto-lock: %v", err)
+		return
+	}
+
+	if len(locked) > 0 {
+		log.Printf("auto-lock: comments locked for posts %v", locked)
+	}
+}
@@ -0,0 +1,71 @@
+package jobs
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+type fakeLocker struct {
+	calls []time.Time
+	err   error
+	mu    sync.Mutex
+}
+
+func (f *fakeLocker) LockExpiredPosts(now time.Time) ([]int, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.calls = append(f.calls, now)
+	return []int{1}, f.err
+}
+
+func (f *fakeLocker) count() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	return len(f.calls)
+}
+
+func TestAutoLockSweeperUsesClock(t *testing.T) {
+	locker := &fakeLocker{}
+	now := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+
+	sweeper := NewAutoLockSweeper(locker, time.Minute)
+	sweeper.now = func() time.Time { return now }
+	sweeper.Sweep()
+
+	if len(locker.calls) != 1 || !locker.calls[0].Equal(now) {
+		t.Errorf("expected one call at %s, got %v", now, locker.calls)
+	}
+}
+
+func TestAutoLockSweeperRunsUntilCancelled(t *testing.T) {
+	locker := &fakeLocker{err: errors.New("connection refused")}
+	ctx, cancel := context.WithCancel(context.Background())
+
+	done := make(chan struct{})
+	go func() {
+		NewAutoLockSweeper(locker, time.Millisecond).Run(ctx)
+		close(done)
+	}()
+
+	// Ошибки не останавливают периодические проходы
+	deadline := time.After(time.Second)
+	for locker.count() < 3 {
+		select {
+		case <-deadline:
+			t.Fatalf("expected at least 3 sweeps, got %d", locker.count())
+		case <-time.After(time.Millisecond):
+		}
+	}
+
+	cancel()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("sweeper should stop after the context is cancelled")
+	}
+}
@@ -0,0 +1,57 @@
+package jobs
+
+import (
+	"context"
+	"log"
+	"time"
+)
+
+// ScheduledPublisher публикует отложенные посты, время публикации которых наступило
+type ScheduledPublisher interface {
+	PublishScheduledPosts(now time.Time) ([]int, error)
+}
+
+// Publisher периодически публикует отложенные посты
+type Publisher struct {
+	store    ScheduledPublisher
+	interval time.Duration
+	now      func() time.Time
+}
+
+func NewPublisher(store ScheduledPublisher, interval time.Duration) *Publisher {
+	return &Publisher{
+		store:    store,
+		interval: interval,
+		now:      time.Now,
+	}
+}
+
+// Run публикует отложенные посты, время которых наступило, сразу после запуска
+// (чтобы выпустить посты, пропущенные, пока сервис не работал) и затем каждые
+// interval до завершения ctx. Ошибка прохода только логируется.
+func (p *Publisher) Run(ctx context.Context) {
+	ticker := time.NewTicker(p.interval)
+	defer ticker.Stop()
+
+	for {
+		p.Publish()
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (p *Publisher) Publish() {
+	published, err := p.store.PublishScheduledPosts(p.now())
+	if err != nil {
+		log.Printf("publisher: %v", err)
+		return
+	}
+
+	if len(published) > 0 {
+		log.Printf("publisher: published posts %v", published)
+	}
+}
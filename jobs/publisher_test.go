@@ -0,0 +1,37 @@
+package jobs
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+type fakePublisher struct {
+	calls []time.Time
+	err   error
+}
+
+func (f *fakePublisher) PublishScheduledPosts(now time.Time) ([]int, error) {
+	f.calls = append(f.calls, now)
+	return []int{1}, f.err
+}
+
+func TestPublisherUsesClock(t *testing.T) {
+	store := &fakePublisher{}
+	now := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+
+	publisher := NewPublisher(store, time.Minute)
+	publisher.now = func() time.Time { return now }
+	publisher.Publish()
+
+	if len(store.calls) != 1 || !store.calls[0].Equal(now) {
+		t.Errorf("expected one call at %s, got %v", now, store.calls)
+	}
+
+	// Ошибка прохода только логируется
+	store.err = errors.New("connection refused")
+	publisher.Publish()
+	if len(store.calls) != 2 {
+		t.Errorf("expected 2 calls, got %d", len(store.calls))
+	}
+}
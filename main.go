@@ -1,25 +1,221 @@
 package main
 
 import (
+	"context"
 	"flag"
 	"log"
 	"net/http"
+	"os"
+	"strings"
+	"time"
 
+	"PostCommentService/auth"
+	"PostCommentService/db"
 	"PostCommentService/graph"
+	"PostCommentService/graph/loaders"
+	"PostCommentService/jobs"
+	"PostCommentService/policy"
+	"PostCommentService/pubsub"
+	"PostCommentService/ratelimit"
+	"PostCommentService/server"
 
-	"github.com/99designs/gqlgen/graphql/handler"
 	"github.com/99designs/gqlgen/graphql/playground"
 )
 
 func main() {
+	if len(os.Args) > 1 && os.Args[1] == "migrate" {
+		runMigrate(os.Args[2:])
+		return
+	}
+
+	cfg := server.DefaultConfig()
+
 	useMemory := flag.Bool("useMemory", false, "Use in-memory storage")
+	autoMigrate := flag.Bool("autoMigrate", false, "Apply pending database migrations at startup")
+	flag.DurationVar(&cfg.KeepAliveInterval, "wsKeepAlive", cfg.KeepAliveInterval, "Keepalive interval for the graphql-ws protocol")
+	flag.DurationVar(&cfg.PingPongInterval, "wsPingPong", cfg.PingPongInterval, "Ping interval for the graphql-transport-ws protocol")
+	flag.DurationVar(&cfg.InitTimeout, "wsInitTimeout", cfg.InitTimeout, "How long to wait for connection_init")
+	flag.IntVar(&cfg.MaxConnections, "wsMaxConnections", cfg.MaxConnections, "Maximum number of websocket connections, 0 for unlimited")
+	allowedOrigins := flag.String("allowedOrigins", "", "Comma-separated list of allowed websocket origins, * for any")
+	flag.Int64Var(&cfg.MaxUploadSize, "maxUploadSize", cfg.MaxUploadSize, "Maximum size of a multipart request in bytes")
+	jwtSecret := flag.String("jwtSecret", os.Getenv("JWT_SECRET"), "Shared secret for HS256 tokens")
+	jwksFile := flag.String("jwksFile", "", "Path to a JWK Set with RSA keys for RS256 tokens")
+	jwtIssuer := flag.String("jwtIssuer", "", "Required iss claim of tokens")
+	jwtAudience := flag.String("jwtAudience", "", "Required aud claim of tokens")
+	autoLockInterval := flag.Duration("autoLockInterval", time.Minute, "How often to apply auto-lock rules, 0 to disable")
+	publishInterval := flag.Duration("publishInterval", time.Minute, "How often to publish scheduled posts, 0 to disable")
+	reactions := flag.String("reactions", strings.Join(graph.DefaultReactions, ","), "Comma-separated list of allowed comment reactions")
+	contentPolicy := policy.DefaultConfig
+	flag.IntVar(&contentPolicy.MaxCommentLength, "maxCommentLength", contentPolicy.MaxCommentLength, "Maximum comment length in characters, 0 for unlimited")
+	flag.IntVar(&contentPolicy.MaxPostLength, "maxPostLength", contentPolicy.MaxPostLength, "Maximum post length in characters, 0 for unlimited")
+	flag.IntVar(&contentPolicy.MaxTitleLength, "maxTitleLength", contentPolicy.MaxTitleLength, "Maximum post title length in characters, 0 for unlimited")
+	flag.IntVar(&contentPolicy.MaxLinks, "maxLinks", contentPolicy.MaxLinks, "Maximum number of links in a post or comment, 0 for unlimited")
+	flag.IntVar(&contentPolicy.MaxNewlines, "maxNewlines", contentPolicy.MaxNewlines, "Maximum number of line breaks in a post or comment, 0 for unlimited")
+	bannedWords := flag.String("bannedWords", "", "Comma-separated list of banned words")
+	bannedWordsFile := flag.String("bannedWordsFile", "", "Path to a file with banned words, one per line")
+	forbiddenDomains := flag.String("forbiddenDomains", "", "Comma-separated list of domains that links must not point to")
+	userLimits := flag.String("rateLimits", ratelimit.DefaultUserLimits.String(), "Per-user mutation rate limits, e.g. createComment=20/1m")
+	ipLimits := flag.String("ipRateLimits", ratelimit.DefaultIPLimits.String(), "Per-IP mutation rate limits, e.g. createComment=60/1m")
+	rateLimitStore := flag.String("rateLimitStore", "memory", "Where to keep rate limit buckets: memory or postgres")
+	trustProxy := flag.Bool("trustProxy", false, "Take the client IP from the last X-Forwarded-For address")
+	queryLimits := &graph.QueryLimits{MaxDepth: 15, MaxComplexity: 5000}
+	flag.IntVar(&queryLimits.MaxDepth, "maxQueryDepth", queryLimits.MaxDepth, "Maximum nesting depth of a query, 0 for unlimited")
+	flag.IntVar(&queryLimits.MaxComplexity, "maxQueryComplexity", queryLimits.MaxComplexity, "Maximum calculated cost of a query, 0 for unlimited")
+	flag.IntVar(&cfg.APQCacheSize, "apqCacheSize", cfg.APQCacheSize, "Number of automatic persisted queries kept in memory")
+	apqStore := flag.String("apqStore", "memory", "Where to keep automatic persisted queries: memory or postgres")
+	allowlist := flag.String("allowlist", "", "Path to an operation manifest; when set, only operations from it are executed")
+	anonymous := flag.Bool("anonymous", false, "Allow requests without a token (development only, requires -useMemory)")
 	flag.Parse()
-	resolver := graph.NewResolver(*useMemory)
-	srv := handler.NewDefaultServer(graph.NewExecutableSchema(graph.Config{Resolvers: resolver}))
+
+	cfg.AllowedOrigins = splitList(*allowedOrigins)
+	contentPolicy.ForbiddenDomains = splitList(*forbiddenDomains)
+	contentPolicy.BannedWords = splitList(*bannedWords)
+	if *bannedWordsFile != "" {
+		words, err := readLines(*bannedWordsFile)
+		if err != nil {
+			log.Fatal(err)
+		}
+		contentPolicy.BannedWords = append(contentPolicy.BannedWords, words...)
+	}
+
+	users, err := ratelimit.ParseLimits(*userLimits)
+	if err != nil {
+		log.Fatal(err)
+	}
+	ips, err := ratelimit.ParseLimits(*ipLimits)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if *rateLimitStore != "memory" && (*rateLimitStore != "postgres" || *useMemory) {
+		log.Fatal("-rateLimitStore must be memory or postgres, postgres requires a database")
+	}
+
+	if *apqStore != "memory" && (*apqStore != "postgres" || *useMemory) {
+		log.Fatal("-apqStore must be memory or postgres, postgres requires a database")
+	}
+	if *allowlist != "" {
+		cfg.Allowlist, err = server.LoadAllowlist(*allowlist)
+		if err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	if *anonymous && !*useMemory {
+		log.Fatal("-anonymous can only be used with -useMemory")
+	}
+
+	authenticator, err := newAuthenticator(auth.VerifierConfig{
+		Secret:   []byte(*jwtSecret),
+		Issuer:   *jwtIssuer,
+		Audience: *jwtAudience,
+	}, *jwksFile, *anonymous)
+	if err != nil {
+		log.Fatal(err)
+	}
+	cfg.Authenticate = authenticator.Authenticate
+
+	store := db.NewStore(*useMemory, *autoMigrate)
+	if *autoLockInterval > 0 {
+		go jobs.NewAutoLockSweeper(store, *autoLockInterval).Run(context.Background())
+	}
+	if *publishInterval > 0 {
+		go jobs.NewPublisher(store, *publishInterval).Run(context.Background())
+	}
+	if pg, ok := store.(*db.PostgresStore); ok && *apqStore == "postgres" {
+		cfg.PersistedQueries = pg
+	}
+	resolver := graph.NewResolver(store, newEvents(store), splitList(*reactions), policy.FromConfig(contentPolicy))
+	srv := server.New(graph.NewExecutableSchema(graph.Config{
+		Resolvers:  resolver,
+		Directives: graph.NewDirectives(store),
+		Complexity: graph.NewComplexity(),
+	}), cfg)
+	srv.SetErrorPresenter(graph.ErrorPresenter)
+	srv.Use(queryLimits)
+	srv.AroundOperations(loaders.Middleware(store))
+	srv.AroundFields(graph.RateLimit(newLimiter(*rateLimitStore), users, ips))
 
 	http.Handle("/", playground.Handler("GraphQL playground", "/query"))
-	http.Handle("/query", srv)
+	http.Handle("/query", ratelimit.Middleware(*trustProxy, authenticator.Middleware(srv)))
 
 	log.Printf("connect to http://localhost:8080/ for GraphQL playground")
 	log.Fatal(http.ListenAndServe(":8080", nil))
 }
+
+// splitList разбирает список значений через запятую, пропуская пустые
+func splitList(list string) []string {
+	var values []string
+	for _, value := range strings.Split(list, ",") {
+		if value = strings.TrimSpace(value); value != "" {
+			values = append(values, value)
+		}
+	}
+	return values
+}
+
+// readLines читает непустые строки файла без пробелов по краям
+func readLines(path string) ([]string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var lines []string
+	for _, line := range strings.Split(string(data), "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			lines = append(lines, line)
+		}
+	}
+	return lines, nil
+}
+
+// newAuthenticator настраивает проверку JWT. В анонимном режиме ключи
+// можно не указывать, тогда все запросы выполняются от имени auth.Anonymous.
+func newAuthenticator(cfg auth.VerifierConfig, jwksFile string, anonymous bool) (*auth.Authenticator, error) {
+	if jwksFile != "" {
+		keys, err := auth.LoadJWKS(jwksFile)
+		if err != nil {
+			return nil, err
+		}
+		cfg.Keys = keys
+	}
+
+	if anonymous && len(cfg.Secret) == 0 && len(cfg.Keys) == 0 {
+		return auth.NewAuthenticator(nil, true), nil
+	}
+
+	verifier, err := auth.NewVerifier(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	return auth.NewAuthenticator(verifier, anonymous), nil
+}
+
+// newEvents выбирает способ доставки событий о новых комментариях.
+// С PostgreSQL события рассылаются между репликами через LISTEN/NOTIFY,
+// в in-memory режиме достаточно локального брокера.
+func newEvents(store db.Store) pubsub.PubSub {
+	pg, ok := store.(*db.PostgresStore)
+	if !ok {
+		return pubsub.NewBroker()
+	}
+
+	listener := pubsub.NewPGListener(db.ConnString(), pg)
+	go func() {
+		if err := listener.Run(context.Background()); err != nil {
+			log.Fatal(err)
+		}
+	}()
+
+	return listener
+}
+
+// newLimiter выбирает хранилище корзин для ограничения частоты мутаций.
+// В памяти каждая реплика считает запросы отдельно, в PostgreSQL - все вместе.
+func newLimiter(kind string) ratelimit.Limiter {
+	if kind == "postgres" {
+		return ratelimit.NewPostgresLimiter(db.Open())
+	}
+	return ratelimit.NewMemoryLimiter()
+}
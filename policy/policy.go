@@ -0,0 +1,127 @@
+package policy
+
+import (
+	"errors"
+	"fmt"
+)
+
+var (
+	ErrViolation = errors.New("content policy violation")
+	// ErrTooLong - нарушение ограничения на длину текста или заголовка
+	ErrTooLong = fmt.Errorf("%w: content is too long", ErrViolation)
+)
+
+type Kind int
+
+const (
+	KindPost Kind = iota
+	KindComment
+)
+
+func (k Kind) String() string {
+	if k == KindPost {
+		return "post"
+	}
+	return "comment"
+}
+
+// Поля проверяемого текста, которые попадают в Violation.Field
+const (
+	FieldTitle   = "title"
+	FieldContent = "content"
+)
+
+// Content - текст поста или комментария перед записью в хранилище.
+// У комментария заголовка нет.
+type Content struct {
+	Kind    Kind
+	Title   string
+	Content string
+}
+
+// Violation - нарушение правила Rule в поле Field.
+// errors.Is(err, ErrViolation) для него возвращает true.
+type Violation struct {
+	Rule    string
+	Field   string
+	Message string
+	err     error
+}
+
+func (v *Violation) Error() string {
+	return v.Message
+}
+
+func (v *Violation) Unwrap() error {
+	return v.err
+}
+
+func violation(rule, field, format string, args ...interface{}) *Violation {
+	return &Violation{Rule: rule, Field: field, Message: fmt.Sprintf(format, args...), err: ErrViolation}
+}
+
+// Rule - правило проверки текста
+type Rule interface {
+	// Check возвращает нарушение правила или nil
+	Check(c Content) *Violation
+}
+
+// Policy проверяет текст по правилам в порядке их добавления
+type Policy struct {
+	rules []Rule
+}
+
+func New(rules ...Rule) *Policy {
+	return &Policy{rules: rules}
+}
+
+// Check возвращает первое нарушенное правило как *Violation или nil
+func (p *Policy) Check(c Content) error {
+	for _, rule := range p.rules {
+		if v := rule.Check(c); v != nil {
+			return v
+		}
+	}
+	return nil
+}
+
+// Config - настройки правил по умолчанию. Нулевые ограничения и пустые списки отключают правило.
+type Config struct {
+	MaxCommentLength int
+	MaxPostLength    int
+	MaxTitleLength   int
+	MaxLinks         int
+	MaxNewlines      int
+	BannedWords      []string
+	ForbiddenDomains []string
+}
+
+// DefaultConfig ограничивает только длину комментария, как раньше, но в символах, а не в байтах
+var DefaultConfig = Config{MaxCommentLength: 2000}
+
+// FromConfig собирает политику из включённых в cfg правил
+func FromConfig(cfg Config) *Policy {
+	var rules []Rule
+	if cfg.MaxTitleLength > 0 {
+		rules = append(rules, MaxTitleLength(cfg.MaxTitleLength))
+	}
+	if cfg.MaxPostLength > 0 {
+		rules = append(rules, MaxLength{Kind: KindPost, Limit: cfg.MaxPostLength})
+	}
+	if cfg.MaxCommentLength > 0 {
+		rules = append(rules, MaxLength{Kind: KindComment, Limit: cfg.MaxCommentLength})
+	}
+	if cfg.MaxNewlines > 0 {
+		rules = append(rules, MaxNewlines(cfg.MaxNewlines))
+	}
+	if cfg.MaxLinks > 0 {
+		rules = append(rules, MaxLinks(cfg.MaxLinks))
+	}
+	if len(cfg.ForbiddenDomains) > 0 {
+		rules = append(rules, NewForbiddenDomains(cfg.ForbiddenDomains))
+	}
+	if len(cfg.BannedWords) > 0 {
+		rules = append(rules, NewBannedWords(cfg.BannedWords))
+	}
+	return New(rules...)
+}
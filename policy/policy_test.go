@@ -0,0 +1,70 @@
+package policy
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestCheck(t *testing.T) {
+	p := FromConfig(Config{
+		MaxCommentLength: 10,
+		MaxTitleLength:   5,
+		MaxLinks:         1,
+		MaxNewlines:      2,
+		BannedWords:      []string{"Ёлки", "Купи  слона"},
+		ForbiddenDomains: []string{"Spam.example."},
+	})
+
+	tests := []struct {
+		name    string
+		content Content
+		rule    string
+		field   string
+	}{
+		{"ok", Content{Kind: KindComment, Content: "Привет"}, "", ""},
+		{"runes, not bytes", Content{Kind: KindComment, Content: strings.Repeat("я", 10)}, "", ""},
+		{"comment too long", Content{Kind: KindComment, Content: strings.Repeat("я", 11)}, "maxLength", FieldContent},
+		{"post length is not limited", Content{Kind: KindPost, Content: strings.Repeat("я", 11)}, "", ""},
+		{"title too long", Content{Kind: KindPost, Title: "Заголовок"}, "maxTitleLength", FieldTitle},
+		{"newlines", Content{Kind: KindPost, Content: "a\nb\nc\nd"}, "maxNewlines", FieldContent},
+		{"links", Content{Kind: KindPost, Content: "https://a.example www.b.example"}, "maxLinks", FieldContent},
+		{"forbidden subdomain", Content{Kind: KindPost, Content: "see http://www.SPAM.example/offer"}, "forbiddenDomains", FieldContent},
+		{"similar domain", Content{Kind: KindPost, Content: "see https://notspam.example"}, "", ""},
+		{"banned word in title", Content{Kind: KindPost, Title: "елки"}, "bannedWords", FieldTitle},
+		{"banned word inside another word", Content{Kind: KindPost, Content: "ёлкипалки"}, "", ""},
+		{"banned phrase", Content{Kind: KindPost, Content: "Все говорят: купи, слона!"}, "bannedWords", FieldContent},
+		{"word of a banned phrase", Content{Kind: KindPost, Content: "Купи хлеба"}, "", ""},
+		{"banned phrase words apart", Content{Kind: KindPost, Content: "слона не купи"}, "", ""},
+	}
+
+	for _, tt := range tests {
+		err := p.Check(tt.content)
+		if tt.rule == "" {
+			if err != nil {
+				t.Errorf("%s: unexpected violation %v", tt.name, err)
+			}
+			continue
+		}
+
+		var v *Violation
+		if !errors.As(err, &v) || v.Rule != tt.rule || v.Field != tt.field {
+			t.Errorf("%s: expected %s in %s, got %v", tt.name, tt.rule, tt.field, err)
+		}
+		if !errors.Is(err, ErrViolation) {
+			t.Errorf("%s: violation should match %v", tt.name, ErrViolation)
+		}
+	}
+}
+
+func TestTooLong(t *testing.T) {
+	err := FromConfig(DefaultConfig).Check(Content{Kind: KindComment, Content: strings.Repeat("a", 2001)})
+	if !errors.Is(err, ErrTooLong) || err.Error() != "comment is longer than 2000 characters" {
+		t.Errorf("expected %v, got %v", ErrTooLong, err)
+	}
+
+	err = New(MaxNewlines(0)).Check(Content{Content: "a\nb"})
+	if errors.Is(err, ErrTooLong) {
+		t.Errorf("only length rules should match %v", ErrTooLong)
+	}
+}
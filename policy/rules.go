@@ -0,0 +1,159 @@
+package policy
+
+import (
+	"net/url"
+	"regexp"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// MaxLength ограничивает число символов в тексте поста или комментария
+type MaxLength struct {
+	Kind  Kind
+	Limit int
+}
+
+func (r MaxLength) Check(c Content) *Violation {
+	if c.Kind != r.Kind || utf8.RuneCountInString(c.Content) <= r.Limit {
+		return nil
+	}
+	v := violation("maxLength", FieldContent, "%s is longer than %d characters", c.Kind, r.Limit)
+	v.err = ErrTooLong
+	return v
+}
+
+// MaxTitleLength ограничивает число символов в заголовке поста
+type MaxTitleLength int
+
+func (r MaxTitleLength) Check(c Content) *Violation {
+	if utf8.RuneCountInString(c.Title) <= int(r) {
+		return nil
+	}
+	v := violation("maxTitleLength", FieldTitle, "title is longer than %d characters", int(r))
+	v.err = ErrTooLong
+	return v
+}
+
+// MaxNewlines ограничивает число переводов строки в тексте
+type MaxNewlines int
+
+func (r MaxNewlines) Check(c Content) *Violation {
+	if strings.Count(c.Content, "\n") <= int(r) {
+		return nil
+	}
+	return violation("maxNewlines", FieldContent, "%s has more than %d line breaks", c.Kind, int(r))
+}
+
+// linkPattern находит ссылки вида http://..., https://... и www....
+var linkPattern = regexp.MustCompile(`(?i)\b(?:https?://|www\.)[^\s<>"'()]+`)
+
+// links возвращает ссылки в тексте
+func links(text string) []string {
+	return linkPattern.FindAllString(text, -1)
+}
+
+// MaxLinks ограничивает число ссылок в тексте
+type MaxLinks int
+
+func (r MaxLinks) Check(c Content) *Violation {
+	if len(links(c.Content)) <= int(r) {
+		return nil
+	}
+	return violation("maxLinks", FieldContent, "%s has more than %d links", c.Kind, int(r))
+}
+
+// ForbiddenDomains запрещает ссылки на домены из списка и их поддомены
+type ForbiddenDomains struct {
+	domains []string
+}
+
+func NewForbiddenDomains(domains []string) ForbiddenDomains {
+	r := ForbiddenDomains{}
+	for _, d := range domains {
+		if d = strings.Trim(strings.ToLower(strings.TrimSpace(d)), "."); d != "" {
+			r.domains = append(r.domains, d)
+		}
+	}
+	return r
+}
+
+func (r ForbiddenDomains) Check(c Content) *Violation {
+	for _, link := range links(c.Content) {
+		host := linkHost(link)
+		for _, d := range r.domains {
+			if host == d || strings.HasSuffix(host, "."+d) {
+				return violation("forbiddenDomains", FieldContent, "links to %s are not allowed", d)
+			}
+		}
+	}
+	return nil
+}
+
+// linkHost возвращает хост ссылки в нижнем регистре или пустую строку, если ссылку не разобрать
+func linkHost(link string) string {
+	if !strings.Contains(link, "://") {
+		link = "http://" + link
+	}
+	u, err := url.Parse(link)
+	if err != nil {
+		return ""
+	}
+	return strings.ToLower(u.Hostname())
+}
+
+// BannedWords запрещает слова и фразы из списка в заголовке и тексте. Слова сравниваются
+// без учёта регистра, ё не отличается от е. Фраза из нескольких слов запрещена, только
+// если её слова идут в тексте подряд.
+type BannedWords struct {
+	// phrases по первому слову фразы
+	phrases map[string][][]string
+}
+
+func NewBannedWords(words []string) BannedWords {
+	r := BannedWords{phrases: make(map[string][][]string, len(words))}
+	for _, w := range words {
+		if phrase := splitWords(w); len(phrase) > 0 {
+			r.phrases[phrase[0]] = append(r.phrases[phrase[0]], phrase)
+		}
+	}
+	return r
+}
+
+func (r BannedWords) Check(c Content) *Violation {
+	for _, field := range []struct{ name, text string }{{FieldTitle, c.Title}, {FieldContent, c.Content}} {
+		words := splitWords(field.text)
+		for i, word := range words {
+			for _, phrase := range r.phrases[word] {
+				if hasPrefix(words[i:], phrase) {
+					return violation("bannedWords", field.name, "%s contains a banned word", field.name)
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// hasPrefix сообщает, начинается ли words со слов prefix
+func hasPrefix(words, prefix []string) bool {
+	if len(words) < len(prefix) {
+		return false
+	}
+	for i, w := range prefix {
+		if words[i] != w {
+			return false
+		}
+	}
+	return true
+}
+
+// splitWords разбивает текст на слова из букв и цифр в нижнем регистре
+func splitWords(text string) []string {
+	words := strings.FieldsFunc(text, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	for i, w := range words {
+		words[i] = strings.ReplaceAll(strings.ToLower(w), "ё", "е")
+	}
+	return words
+}
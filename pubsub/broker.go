@@ -0,0 +1,68 @@
+package pubsub
+
+import (
+	"PostCommentService/graph/model"
+	"context"
+	"sync"
+)
+
+// Размер буфера канала подписчика. Если подписчик не успевает читать,
+// новые события для него отбрасываются, чтобы не блокировать публикацию.
+const subscriberBuffer = 16
+
+type Broker struct {
+	subscribers map[int]map[chan *model.Comment]struct{}
+	mu          sync.RWMutex
+}
+
+func NewBroker() *Broker {
+	return &Broker{
+		subscribers: make(map[int]map[chan *model.Comment]struct{}),
+	}
+}
+
+// Subscribe возвращает канал с новыми комментариями к посту postID.
+// Канал закрывается и удаляется из брокера после завершения ctx.
+func (b *Broker) Subscribe(ctx context.Context, postID int) <-chan *model.Comment {
+	ch := make(chan *model.Comment, subscriberBuffer)
+
+	b.mu.Lock()
+	if b.subscribers[postID] == nil {
+		b.subscribers[postID] = make(map[chan *model.Comment]struct{})
+	}
+	b.subscribers[postID][ch] = struct{}{}
+	b.mu.Unlock()
+
+	go func() {
+		<-ctx.Done()
+		b.unsubscribe(postID, ch)
+	}()
+
+	return ch
+}
+
+func (b *Broker) unsubscribe(postID int, ch chan *model.Comment) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	delete(b.subscribers[postID], ch)
+	if len(b.subscribers[postID]) == 0 {
+		delete(b.subscribers, postID)
+	}
+	close(ch)
+}
+
+func (b *Broker) Publish(comment *model.Comment) {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	for ch := range b.subscribers[comment.PostID] {
+		// Каждый подписчик получает свою копию, чтобы последующие
+		// изменения комментария в хранилище не попадали в уже отправленное событие
+		c := *comment
+		select {
+		case ch <- &c:
+		default:
+		}
+	}
+}
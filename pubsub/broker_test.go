@@ -0,0 +1,79 @@
+package pubsub
+
+import (
+	"PostCommentService/graph/model"
+	"context"
+	"testing"
+	"time"
+)
+
+func TestBrokerPublishFiltersByPost(t *testing.T) {
+	broker := NewBroker()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	ch := broker.Subscribe(ctx, 1)
+
+	broker.Publish(&model.Comment{ID: 1, PostID: 2, Content: "Other post"})
+	broker.Publish(&model.Comment{ID: 2, PostID: 1, Content: "Content"})
+
+	select {
+	case comment := <-ch:
+		if comment.ID != 2 || comment.PostID != 1 {
+			t.Errorf("unexpected comment: %+v", comment)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("comment was not delivered")
+	}
+
+	select {
+	case comment := <-ch:
+		t.Errorf("unexpected extra comment: %+v", comment)
+	default:
+	}
+}
+
+func TestBrokerUnsubscribeOnContextDone(t *testing.T) {
+	broker := NewBroker()
+	ctx, cancel := context.WithCancel(context.Background())
+
+	ch := broker.Subscribe(ctx, 1)
+	cancel()
+
+	select {
+	case _, ok := <-ch:
+		if ok {
+			t.Errorf("channel should be closed without events")
+		}
+	case <-time.After(time.Second):
+		t.Fatal("channel was not closed after context cancellation")
+	}
+
+	broker.mu.RLock()
+	defer broker.mu.RUnlock()
+	if len(broker.subscribers) != 0 {
+		t.Errorf("expected no subscribers, got %d", len(broker.subscribers))
+	}
+}
+
+func TestBrokerSlowSubscriberDoesNotBlock(t *testing.T) {
+	broker := NewBroker()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	broker.Subscribe(ctx, 1)
+
+	done := make(chan struct{})
+	go func() {
+		for i := 0; i < subscriberBuffer*2; i++ {
+			broker.Publish(&model.Comment{ID: i + 1, PostID: 1})
+		}
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("publish blocked on a slow subscriber")
+	}
+}
@@ -0,0 +1,189 @@
+package pubsub
+
+import (
+	"PostCommentService/db"
+	"PostCommentService/graph/model"
+	"context"
+	"encoding/json"
+	"log"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+const (
+	minReconnectInterval = time.Second
+	maxReconnectInterval = time.Minute
+	pingInterval         = 90 * time.Second
+
+	// Сколько комментариев догружаем за один запрос после переподключения
+	catchUpBatch = 500
+	// Насколько ниже lastID начинается догрузка. ID выдаются до фиксации, поэтому
+	// параллельные транзакции фиксируются не по порядку ID: комментарий с меньшим
+	// ID может появиться в базе уже после комментария с большим
+	catchUpLookback = 200
+	// Сколько последних ID помним, чтобы не отправить комментарий дважды
+	// (через догрузку и через NOTIFY одновременно). Должно быть заметно
+	// больше catchUpLookback: повторно догруженные ID ищутся здесь
+	recentCapacity = 1024
+)
+
+type CommentSource interface {
+	GetComment(id int) (*model.Comment, error)
+	GetCommentsAfter(id, limit int) ([]*model.Comment, error)
+	LastCommentID() (int, error)
+}
+
+// PGListener получает уведомления о новых комментариях через LISTEN/NOTIFY
+// и раздаёт их локальным подписчикам. Благодаря этому подписчики получают
+// комментарии, созданные на любой реплике сервиса.
+type PGListener struct {
+	broker   *Broker
+	listener *pq.Listener
+	source   CommentSource
+
+	lastID int
+	recent map[int]struct{}
+	order  []int
+}
+
+func NewPGListener(connStr string, source CommentSource) *PGListener {
+	listener := pq.NewListener(connStr, minReconnectInterval, maxReconnectInterval, func(event pq.ListenerEventType, err error) {
+		if err != nil {
+			log.Printf("comment listener: %v", err)
+		}
+	})
+
+	return &PGListener{
+		broker:   NewBroker(),
+		listener: listener,
+		source:   source,
+		recent:   make(map[int]struct{}),
+	}
+}
+
+func (l *PGListener) Subscribe(ctx context.Context, postID int) <-chan *model.Comment {
+	return l.broker.Subscribe(ctx, postID)
+}
+
+// Publish ничего не делает: PostgresStore сам отправляет NOTIFY при создании
+// комментария, и событие придёт всем репликам, включая текущую.
+func (l *PGListener) Publish(comment *model.Comment) {}
+
+// Run слушает канал уведомлений до завершения ctx
+func (l *PGListener) Run(ctx context.Context) error {
+	defer l.listener.Close()
+
+	if err := l.listener.Listen(db.CommentAddedChannel); err != nil {
+		return err
+	}
+
+	lastID, err := l.source.LastCommentID()
+	if err != nil {
+		return err
+	}
+	l.lastID = lastID
+	// Уже существующие комментарии из окна догрузки подписчикам не отправляются
+	existing, err := l.source.GetCommentsAfter(lookbackFrom(lastID), catchUpLookback)
+	if err != nil {
+		return err
+	}
+	for _, comment := range existing {
+		l.remember(comment.ID)
+	}
+
+	ticker := time.NewTicker(pingInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case n := <-l.listener.Notify:
+			// nil приходит после переподключения: уведомления, отправленные
+			// пока соединения не было, потеряны, поэтому догружаем их из базы
+			if n == nil {
+				l.catchUp()
+				continue
+			}
+			l.handle(n.Extra)
+		case <-ticker.C:
+			go l.listener.Ping()
+		}
+	}
+}
+
+func (l *PGListener) handle(payload string) {
+	var event db.CommentEvent
+	if err := json.Unmarshal([]byte(payload), &event); err != nil {
+		log.Printf("comment listener: invalid payload %q: %v", payload, err)
+		return
+	}
+
+	if l.seen(event.ID) {
+		return
+	}
+
+	comment, err := l.source.GetComment(event.ID)
+	if err != nil {
+		log.Printf("comment listener: load comment %d: %v", event.ID, err)
+		return
+	}
+
+	l.publish(comment)
+}
+
+// catchUp догружает комментарии начиная с окна ниже lastID, уже отправленные
+// отсеиваются по recent
+func (l *PGListener) catchUp() {
+	after := lookbackFrom(l.lastID)
+	for {
+		comments, err := l.source.GetCommentsAfter(after, catchUpBatch)
+		if err != nil {
+			log.Printf("comment listener: catch up after %d: %v", after, err)
+			return
+		}
+
+		for _, comment := range comments {
+			if !l.seen(comment.ID) {
+				l.publish(comment)
+			}
+			l.advance(comment.ID)
+			after = comment.ID
+		}
+
+		if len(comments) < catchUpBatch {
+			return
+		}
+	}
+}
+
+func lookbackFrom(lastID int) int {
+	return max(lastID-catchUpLookback, 0)
+}
+
+func (l *PGListener) publish(comment *model.Comment) {
+	l.remember(comment.ID)
+	l.advance(comment.ID)
+	l.broker.Publish(comment)
+}
+
+func (l *PGListener) advance(id int) {
+	if id > l.lastID {
+		l.lastID = id
+	}
+}
+
+func (l *PGListener) seen(id int) bool {
+	_, ok := l.recent[id]
+	return ok
+}
+
+func (l *PGListener) remember(id int) {
+	l.recent[id] = struct{}{}
+	l.order = append(l.order, id)
+	if len(l.order) > recentCapacity {
+		delete(l.recent, l.order[0])
+		l.order = l.order[1:]
+	}
+}
@@ -0,0 +1,142 @@
+package pubsub
+
+import (
+	"PostCommentService/graph/model"
+	"context"
+	"errors"
+	"sort"
+	"testing"
+)
+
+type fakeSource struct {
+	comments map[int]*model.Comment
+}
+
+func (s *fakeSource) GetComment(id int) (*model.Comment, error) {
+	comment, ok := s.comments[id]
+	if !ok {
+		return nil, errors.New("comment not found")
+	}
+	return comment, nil
+}
+
+func (s *fakeSource) GetCommentsAfter(id, limit int) ([]*model.Comment, error) {
+	var ids []int
+	for commentID := range s.comments {
+		if commentID > id {
+			ids = append(ids, commentID)
+		}
+	}
+	sort.Ints(ids)
+
+	var comments []*model.Comment
+	for _, commentID := range ids {
+		if len(comments) == limit {
+			break
+		}
+		comments = append(comments, s.comments[commentID])
+	}
+	return comments, nil
+}
+
+func (s *fakeSource) LastCommentID() (int, error) {
+	return len(s.comments), nil
+}
+
+func newTestListener(source CommentSource) *PGListener {
+	return &PGListener{
+		broker: NewBroker(),
+		source: source,
+		recent: make(map[int]struct{}),
+	}
+}
+
+func drain(ch <-chan *model.Comment) []int {
+	var ids []int
+	for {
+		select {
+		case comment := <-ch:
+			ids = append(ids, comment.ID)
+		default:
+			return ids
+		}
+	}
+}
+
+func TestPGListenerHandle(t *testing.T) {
+	source := &fakeSource{comments: map[int]*model.Comment{
+		1: {ID: 1, PostID: 1, Content: "Content"},
+	}}
+	listener := newTestListener(source)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	ch := listener.Subscribe(ctx, 1)
+
+	listener.handle(`{"id":1,"postId":1}`)
+	listener.handle(`{"id":1,"postId":1}`)
+	listener.handle(`not json`)
+
+	ids := drain(ch)
+	if len(ids) != 1 || ids[0] != 1 {
+		t.Errorf("expected comment 1 to be delivered once, got %v", ids)
+	}
+
+	if listener.lastID != 1 {
+		t.Errorf("expected last id to be '1', got '%d'", listener.lastID)
+	}
+}
+
+func TestPGListenerCatchUp(t *testing.T) {
+	source := &fakeSource{comments: map[int]*model.Comment{
+		1: {ID: 1, PostID: 1},
+		2: {ID: 2, PostID: 1},
+		3: {ID: 3, PostID: 2},
+		4: {ID: 4, PostID: 1},
+	}}
+	listener := newTestListener(source)
+	listener.lastID = 1
+	listener.remember(1)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	ch := listener.Subscribe(ctx, 1)
+
+	listener.catchUp()
+	// Комментарий 4 создан после повторного LISTEN, поэтому он приходит
+	// и в догрузке, и через NOTIFY
+	listener.handle(`{"id":4,"postId":1}`)
+
+	ids := drain(ch)
+	if len(ids) != 2 || ids[0] != 2 || ids[1] != 4 {
+		t.Errorf("expected comments [2 4], got %v", ids)
+	}
+
+	if listener.lastID != 4 {
+		t.Errorf("expected last id to be '4', got '%d'", listener.lastID)
+	}
+}
+
+func TestPGListenerCatchUpOutOfOrder(t *testing.T) {
+	source := &fakeSource{comments: map[int]*model.Comment{
+		1: {ID: 1, PostID: 1},
+		3: {ID: 3, PostID: 1},
+	}}
+	listener := newTestListener(source)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	ch := listener.Subscribe(ctx, 1)
+
+	listener.handle(`{"id":1,"postId":1}`)
+	listener.handle(`{"id":3,"postId":1}`)
+
+	// Комментарий 2 зафиксирован позже комментария 3, пока соединения не было
+	source.comments[2] = &model.Comment{ID: 2, PostID: 1}
+	listener.catchUp()
+
+	ids := drain(ch)
+	if len(ids) != 3 || ids[2] != 2 {
+		t.Errorf("expected comments [1 3 2], got %v", ids)
+	}
+}
@@ -0,0 +1,12 @@
+package pubsub
+
+import (
+	"PostCommentService/graph/model"
+	"context"
+)
+
+// PubSub доставляет события о новых комментариях подписчикам
+type PubSub interface {
+	Publish(comment *model.Comment)
+	Subscribe(ctx context.Context, postID int) <-chan *model.Comment
+}
@@ -0,0 +1,65 @@
+package ratelimit
+
+import (
+	"sync"
+	"time"
+)
+
+// Как часто удалять заполнившиеся корзины
+const sweepInterval = time.Minute
+
+type memoryBucket struct {
+	bucket
+	// Когда корзина заполнится и её можно удалить
+	expires time.Time
+}
+
+// MemoryLimiter хранит корзины в памяти процесса. Каждая реплика
+// считает запросы отдельно.
+type MemoryLimiter struct {
+	mu        sync.Mutex
+	buckets   map[string]*memoryBucket
+	lastSweep time.Time
+	now       func() time.Time
+}
+
+func NewMemoryLimiter() *MemoryLimiter {
+	return &MemoryLimiter{
+		buckets:   make(map[string]*memoryBucket),
+		lastSweep: time.Now(),
+		now:       time.Now,
+	}
+}
+
+func (l *MemoryLimiter) Take(key string, limit Limit) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.now()
+	if now.Sub(l.lastSweep) >= sweepInterval {
+		l.sweep(now)
+	}
+
+	b, ok := l.buckets[key]
+	if !ok {
+		b = &memoryBucket{bucket: newBucket(limit, now)}
+		l.buckets[key] = b
+	}
+
+	retryAfter := b.take(limit, now)
+	b.expires = b.fullAt(limit)
+	if retryAfter > 0 {
+		return &LimitedError{RetryAfter: retryAfter}
+	}
+	return nil
+}
+
+// sweep удаляет заполнившиеся корзины: новая корзина для того же ключа будет такой же
+func (l *MemoryLimiter) sweep(now time.Time) {
+	for key, b := range l.buckets {
+		if !now.Before(b.expires) {
+			delete(l.buckets, key)
+		}
+	}
+	l.lastSweep = now
+}
@@ -0,0 +1,88 @@
+package ratelimit
+
+import (
+	"database/sql"
+	"log"
+	"sync"
+	"time"
+)
+
+// PostgresLimiter хранит корзины в таблице rate_limit_buckets, поэтому
+// ограничения общие для всех реплик. Время берётся из базы, чтобы
+// расхождение часов между репликами не влияло на пополнение корзин.
+type PostgresLimiter struct {
+	db *sql.DB
+
+	mu        sync.Mutex
+	lastSweep time.Time
+	// Нужен только для очистки: корзины пополняются по часам базы
+	now func() time.Time
+}
+
+func NewPostgresLimiter(db *sql.DB) *PostgresLimiter {
+	return &PostgresLimiter{
+		db:        db,
+		lastSweep: time.Now(),
+		now:       time.Now,
+	}
+}
+
+func (l *PostgresLimiter) Take(key string, limit Limit) error {
+	l.sweep()
+
+	tx, err := l.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	// Новая корзина создаётся заполненной. ON CONFLICT DO NOTHING позволяет
+	// двум репликам одновременно создать корзину и затем заблокировать одну строку.
+	_, err = tx.Exec(`INSERT INTO rate_limit_buckets (key, tokens, updated_at, expires_at)
+		VALUES ($1, $2, now(), now()) ON CONFLICT (key) DO NOTHING`, key, float64(limit.Requests))
+	if err != nil {
+		return err
+	}
+
+	var b bucket
+	var now time.Time
+	err = tx.QueryRow(`SELECT tokens, updated_at, now() FROM rate_limit_buckets WHERE key = $1 FOR UPDATE`, key).
+		Scan(&b.tokens, &b.updated, &now)
+	if err != nil {
+		return err
+	}
+
+	retryAfter := b.take(limit, now)
+	_, err = tx.Exec(`UPDATE rate_limit_buckets SET tokens = $2, updated_at = $3, expires_at = $4 WHERE key = $1`,
+		key, b.tokens, b.updated, b.fullAt(limit))
+	if err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+	if retryAfter > 0 {
+		return &LimitedError{RetryAfter: retryAfter}
+	}
+	return nil
+}
+
+// sweep не чаще раза в sweepInterval удаляет заполнившиеся корзины.
+// Ошибка только логируется: лишние строки не влияют на ограничения.
+func (l *PostgresLimiter) sweep() {
+	l.mu.Lock()
+	now := l.now()
+	due := now.Sub(l.lastSweep) >= sweepInterval
+	if due {
+		l.lastSweep = now
+	}
+	l.mu.Unlock()
+
+	if !due {
+		return
+	}
+	if _, err := l.db.Exec(`DELETE FROM rate_limit_buckets WHERE expires_at <= now()`); err != nil {
+		log.Printf("rate limiter: %v", err)
+	}
+}
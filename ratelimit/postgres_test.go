@@ -0,0 +1,63 @@
+package ratelimit
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+func TestPostgresLimiter(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	l := NewPostgresLimiter(db)
+	limit := Limit{Requests: 10, Per: time.Minute}
+	updated := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	now := updated.Add(6 * time.Second)
+
+	// За 6 секунд в пустой корзине появляется один токен, его и забираем
+	mock.ExpectBegin()
+	mock.ExpectExec("^INSERT INTO rate_limit_buckets (.+) ON CONFLICT \\(key\\) DO NOTHING$").WithArgs("createComment:user:alice", 10.0).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectQuery("^SELECT tokens, updated_at, now\\(\\) FROM rate_limit_buckets WHERE key = \\$1 FOR UPDATE$").WithArgs("createComment:user:alice").
+		WillReturnRows(sqlmock.NewRows([]string{"tokens", "updated_at", "now"}).AddRow(0.0, updated, now))
+	mock.ExpectExec("^UPDATE rate_limit_buckets SET tokens = \\$2, updated_at = \\$3, expires_at = \\$4 WHERE key = \\$1$").
+		WithArgs("createComment:user:alice", 0.0, now, now.Add(time.Minute)).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	if err := l.Take("createComment:user:alice", limit); err != nil {
+		t.Errorf("error was not expected while taking a token: %s", err)
+	}
+
+	// Без токенов запрос отклоняется, но состояние корзины всё равно сохраняется
+	mock.ExpectBegin()
+	mock.ExpectExec("^INSERT INTO rate_limit_buckets").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectQuery("^SELECT tokens, updated_at, now\\(\\) FROM rate_limit_buckets").
+		WillReturnRows(sqlmock.NewRows([]string{"tokens", "updated_at", "now"}).AddRow(0.5, now, now))
+	mock.ExpectExec("^UPDATE rate_limit_buckets").WithArgs("createComment:user:alice", 0.5, now, sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	var limited *LimitedError
+	if err := l.Take("createComment:user:alice", limit); !errors.As(err, &limited) || limited.RetryAfter != 3*time.Second {
+		t.Errorf("expected %v with 3s, got %v", ErrLimited, err)
+	}
+
+	// Раз в sweepInterval удаляются заполнившиеся корзины
+	l.now = func() time.Time { return l.lastSweep.Add(sweepInterval) }
+	mock.ExpectExec("^DELETE FROM rate_limit_buckets WHERE expires_at <= now\\(\\)$").WillReturnResult(sqlmock.NewResult(0, 3))
+	mock.ExpectBegin().WillReturnError(errors.New("connection refused"))
+
+	if err := l.Take("createComment:user:alice", limit); err == nil || errors.Is(err, ErrLimited) {
+		t.Errorf("expected a database error, got %v", err)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
@@ -0,0 +1,148 @@
+package ratelimit
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var ErrLimited = errors.New("rate limit exceeded")
+
+// LimitedError возвращается, когда в корзине не осталось токенов.
+// errors.Is(err, ErrLimited) для неё возвращает true.
+type LimitedError struct {
+	RetryAfter time.Duration
+}
+
+func (e *LimitedError) Error() string {
+	return fmt.Sprintf("%v, retry after %ds", ErrLimited, e.Seconds())
+}
+
+func (e *LimitedError) Unwrap() error {
+	return ErrLimited
+}
+
+// Seconds возвращает время ожидания в целых секундах с округлением вверх, не меньше 1
+func (e *LimitedError) Seconds() int {
+	return max(1, int(math.Ceil(e.RetryAfter.Seconds())))
+}
+
+// Limit разрешает Requests запросов за Per. Корзина вмещает Requests токенов
+// и пополняется равномерно, так что всплеск до Requests запросов допустим.
+type Limit struct {
+	Requests int
+	Per      time.Duration
+}
+
+func (l Limit) String() string {
+	return fmt.Sprintf("%d/%s", l.Requests, l.Per)
+}
+
+// rate - скорость пополнения корзины в токенах в секунду
+func (l Limit) rate() float64 {
+	return float64(l.Requests) / l.Per.Seconds()
+}
+
+// Limits - ограничения по именам мутаций
+type Limits map[string]Limit
+
+// String возвращает ограничения в формате ParseLimits
+func (ls Limits) String() string {
+	names := make([]string, 0, len(ls))
+	for name := range ls {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	parts := make([]string, len(names))
+	for i, name := range names {
+		parts[i] = name + "=" + ls[name].String()
+	}
+	return strings.Join(parts, ",")
+}
+
+// ParseLimits разбирает список вида "createComment=10/1m,createPost=5/1h".
+// Мутации, которых нет в списке, не ограничиваются.
+func ParseLimits(list string) (Limits, error) {
+	limits := Limits{}
+	for _, part := range strings.Split(list, ",") {
+		if part = strings.TrimSpace(part); part == "" {
+			continue
+		}
+
+		name, value, ok := strings.Cut(part, "=")
+		requests, per, ok2 := strings.Cut(value, "/")
+		if !ok || !ok2 || strings.TrimSpace(name) == "" {
+			return nil, fmt.Errorf("invalid rate limit %q, expected name=requests/duration", part)
+		}
+
+		n, err := strconv.Atoi(strings.TrimSpace(requests))
+		if err != nil || n <= 0 {
+			return nil, fmt.Errorf("invalid rate limit %q: requests must be a positive integer", part)
+		}
+		d, err := time.ParseDuration(strings.TrimSpace(per))
+		if err != nil || d <= 0 {
+			return nil, fmt.Errorf("invalid rate limit %q: duration must be positive", part)
+		}
+
+		limits[strings.TrimSpace(name)] = Limit{Requests: n, Per: d}
+	}
+	return limits, nil
+}
+
+// Ограничения по умолчанию. Для IP они мягче, так как за одним адресом
+// может быть несколько пользователей.
+var (
+	DefaultUserLimits = Limits{
+		"createPost":    {Requests: 5, Per: time.Minute},
+		"createComment": {Requests: 20, Per: time.Minute},
+		"updateComment": {Requests: 30, Per: time.Minute},
+	}
+	DefaultIPLimits = Limits{
+		"createPost":    {Requests: 20, Per: time.Minute},
+		"createComment": {Requests: 60, Per: time.Minute},
+		"updateComment": {Requests: 120, Per: time.Minute},
+	}
+)
+
+// Limiter хранит корзины токенов
+type Limiter interface {
+	// Take забирает токен из корзины key с ограничением limit.
+	// Если токенов нет, возвращает *LimitedError.
+	Take(key string, limit Limit) error
+}
+
+// bucket - состояние корзины токенов на момент updated
+type bucket struct {
+	tokens  float64
+	updated time.Time
+}
+
+func newBucket(limit Limit, now time.Time) bucket {
+	return bucket{tokens: float64(limit.Requests), updated: now}
+}
+
+// take пополняет корзину на время с последнего обновления и забирает токен.
+// Если токена нет, корзина не меняется, кроме пополнения, и возвращается время до появления токена.
+func (b *bucket) take(limit Limit, now time.Time) time.Duration {
+	if elapsed := now.Sub(b.updated); elapsed > 0 {
+		b.tokens = min(float64(limit.Requests), b.tokens+elapsed.Seconds()*limit.rate())
+		b.updated = now
+	}
+
+	if b.tokens >= 1 {
+		b.tokens--
+		return 0
+	}
+	return time.Duration((1 - b.tokens) / limit.rate() * float64(time.Second))
+}
+
+// fullAt - момент, когда корзина снова заполнится и её можно забыть
+func (b *bucket) fullAt(limit Limit) time.Time {
+	missing := float64(limit.Requests) - b.tokens
+	return b.updated.Add(time.Duration(missing / limit.rate() * float64(time.Second)))
+}
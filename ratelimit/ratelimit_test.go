@@ -0,0 +1,99 @@
+package ratelimit
+
+import (
+	"errors"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestParseLimits(t *testing.T) {
+	limits, err := ParseLimits(" createPost=5/1h, createComment = 10/1m ,")
+	if err != nil {
+		t.Fatalf("error was not expected while parsing limits: %s", err)
+	}
+	if limits["createPost"] != (Limit{Requests: 5, Per: time.Hour}) || limits["createComment"] != (Limit{Requests: 10, Per: time.Minute}) {
+		t.Errorf("unexpected limits: %v", limits)
+	}
+	if limits.String() != "createComment=10/1m0s,createPost=5/1h0m0s" {
+		t.Errorf("unexpected string: %s", limits)
+	}
+
+	parsed, err := ParseLimits(DefaultUserLimits.String())
+	if err != nil || len(parsed) != len(DefaultUserLimits) {
+		t.Errorf("default limits should round-trip, got %v, %v", parsed, err)
+	}
+
+	for _, list := range []string{"createPost", "createPost=5", "createPost=0/1m", "createPost=5/0s", "=5/1m"} {
+		if _, err := ParseLimits(list); err == nil {
+			t.Errorf("%q: expected an error", list)
+		}
+	}
+}
+
+func TestMemoryLimiter(t *testing.T) {
+	l := NewMemoryLimiter()
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	l.now = func() time.Time { return now }
+	l.lastSweep = now
+	limit := Limit{Requests: 2, Per: time.Minute}
+
+	for i := 0; i < 2; i++ {
+		if err := l.Take("alice", limit); err != nil {
+			t.Fatalf("request %d should be allowed: %s", i, err)
+		}
+	}
+
+	var limited *LimitedError
+	if err := l.Take("alice", limit); !errors.As(err, &limited) || !errors.Is(err, ErrLimited) {
+		t.Fatalf("expected %v, got %v", ErrLimited, err)
+	}
+	if limited.RetryAfter != 30*time.Second || limited.Seconds() != 30 {
+		t.Errorf("unexpected retry after: %s", limited.RetryAfter)
+	}
+
+	// Другой ключ считается отдельно
+	if err := l.Take("bob", limit); err != nil {
+		t.Errorf("bob should not be limited: %s", err)
+	}
+
+	// За 30 секунд появляется один токен
+	now = now.Add(30 * time.Second)
+	if err := l.Take("alice", limit); err != nil {
+		t.Errorf("token should be refilled: %s", err)
+	}
+	if err := l.Take("alice", limit); !errors.Is(err, ErrLimited) {
+		t.Errorf("expected %v, got %v", ErrLimited, err)
+	}
+
+	// Заполнившиеся корзины удаляются
+	now = now.Add(2 * time.Minute)
+	l.Take("carol", limit)
+	if _, ok := l.buckets["alice"]; ok || len(l.buckets) != 1 {
+		t.Errorf("full buckets should be swept, got %d buckets", len(l.buckets))
+	}
+}
+
+func TestClientIP(t *testing.T) {
+	tests := []struct {
+		forwarded  string
+		trustProxy bool
+		ip         string
+	}{
+		{"", false, "10.0.0.1"},
+		{"203.0.113.7", false, "10.0.0.1"},
+		{"198.51.100.1, 203.0.113.7", true, "203.0.113.7"},
+		{"garbage", true, "10.0.0.1"},
+	}
+
+	for _, tt := range tests {
+		r := httptest.NewRequest("POST", "/query", nil)
+		r.RemoteAddr = "10.0.0.1:5000"
+		if tt.forwarded != "" {
+			r.Header.Set("X-Forwarded-For", tt.forwarded)
+		}
+		if ip := clientIP(r, tt.trustProxy); ip != tt.ip {
+			t.Errorf("%q (trust %v): expected %s, got %s", tt.forwarded, tt.trustProxy, tt.ip, ip)
+		}
+	}
+}
@@ -0,0 +1,172 @@
+package server
+
+import (
+	"PostCommentService/db"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"time"
+
+	"github.com/99designs/gqlgen/graphql"
+	"github.com/99designs/gqlgen/graphql/errcode"
+	"github.com/99designs/gqlgen/graphql/handler/lru"
+	"github.com/vektah/gqlparser/v2/gqlerror"
+)
+
+const errOperationNotAllowed = "OPERATION_NOT_ALLOWED"
+
+// PersistedQueryStore хранит тексты запросов APQ, чтобы хеши переживали перезапуск
+type PersistedQueryStore interface {
+	GetPersistedQuery(hash string) (string, error)
+	SavePersistedQuery(hash, query string) error
+}
+
+// missTTL - сколько хеш, которого нет в хранилище, считается неизвестным
+// без повторного запроса к хранилищу
+const missTTL = time.Minute
+
+// persistedQueryCache - LRU-кэш APQ, за которым может стоять общее хранилище.
+// Ошибки хранилища только логируются: клиент в худшем случае получит
+// PersistedQueryNotFound и пришлёт запрос целиком.
+type persistedQueryCache struct {
+	local *lru.LRU
+	// misses хранит до какого времени хеш считается неизвестным, чтобы поток
+	// случайных хешей не превращался в поток запросов к хранилищу
+	misses *lru.LRU
+	store  PersistedQueryStore
+	now    func() time.Time
+}
+
+func newPersistedQueryCache(size int, store PersistedQueryStore) *persistedQueryCache {
+	return &persistedQueryCache{local: lru.New(size), misses: lru.New(size), store: store, now: time.Now}
+}
+
+func (c *persistedQueryCache) Get(ctx context.Context, hash string) (interface{}, bool) {
+	if query, ok := c.local.Get(ctx, hash); ok {
+		return query, true
+	}
+	if c.store == nil {
+		return nil, false
+	}
+	if until, ok := c.misses.Get(ctx, hash); ok && c.now().Before(until.(time.Time)) {
+		return nil, false
+	}
+
+	query, err := c.store.GetPersistedQuery(hash)
+	if err != nil {
+		if errors.Is(err, db.ErrNotFound) {
+			c.misses.Add(ctx, hash, c.now().Add(missTTL))
+		} else {
+			log.Printf("persisted queries: %v", err)
+		}
+		return nil, false
+	}
+
+	c.local.Add(ctx, hash, query)
+	return query, true
+}
+
+func (c *persistedQueryCache) Add(ctx context.Context, hash string, query interface{}) {
+	// Клиент присылает запрос целиком и тогда, когда хеш уже известен,
+	// в хранилище пишем только новые хеши
+	if _, ok := c.local.Get(ctx, hash); ok {
+		return
+	}
+	c.local.Add(ctx, hash, query)
+
+	if c.store != nil {
+		if err := c.store.SavePersistedQuery(hash, query.(string)); err != nil {
+			log.Printf("persisted queries: %v", err)
+		}
+	}
+}
+
+// Allowlist - операции из манифеста, которые разрешено выполнять в строгом режиме.
+// Служит кэшем APQ только для чтения, так что клиент может передать один хеш,
+// и отклоняет любой запрос, текста которого нет в манифесте.
+type Allowlist struct {
+	queries map[string]string
+}
+
+var _ interface {
+	graphql.Cache
+	graphql.HandlerExtension
+	graphql.OperationParameterMutator
+} = &Allowlist{}
+
+// manifest - манифест в формате apollo-persisted-query-manifest
+type manifest struct {
+	Format     string `json:"format"`
+	Version    int    `json:"version"`
+	Operations []struct {
+		ID   string `json:"id"`
+		Name string `json:"name"`
+		Body string `json:"body"`
+	} `json:"operations"`
+}
+
+// LoadAllowlist читает манифест. id операции, если указан, должен
+// совпадать с sha256 её текста.
+func LoadAllowlist(path string) (*Allowlist, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var m manifest
+	if err := json.Unmarshal(data, &m); err != nil {
+		return nil, fmt.Errorf("allowlist %s: %w", path, err)
+	}
+	if m.Format != "apollo-persisted-query-manifest" || m.Version != 1 {
+		return nil, fmt.Errorf("allowlist %s: unsupported manifest format %q version %d", path, m.Format, m.Version)
+	}
+	if len(m.Operations) == 0 {
+		return nil, fmt.Errorf("allowlist %s: manifest has no operations", path)
+	}
+
+	a := &Allowlist{queries: make(map[string]string, len(m.Operations))}
+	for _, op := range m.Operations {
+		hash := queryHash(op.Body)
+		if op.ID != "" && op.ID != hash {
+			return nil, fmt.Errorf("allowlist %s: id of operation %q does not match the sha256 of its body", path, op.Name)
+		}
+		a.queries[hash] = op.Body
+	}
+	return a, nil
+}
+
+func (a *Allowlist) Get(ctx context.Context, hash string) (interface{}, bool) {
+	query, ok := a.queries[hash]
+	return query, ok
+}
+
+// Add ничего не делает: в строгом режиме новые запросы не регистрируются
+func (a *Allowlist) Add(ctx context.Context, hash string, query interface{}) {}
+
+func (a *Allowlist) ExtensionName() string {
+	return "Allowlist"
+}
+
+func (a *Allowlist) Validate(schema graphql.ExecutableSchema) error {
+	return nil
+}
+
+// MutateOperationParameters выполняется после APQ, когда текст запроса уже известен
+func (a *Allowlist) MutateOperationParameters(ctx context.Context, rawParams *graphql.RawParams) *gqlerror.Error {
+	if _, ok := a.queries[queryHash(rawParams.Query)]; !ok {
+		err := gqlerror.Errorf("operation is not in the allowlist")
+		errcode.Set(err, errOperationNotAllowed)
+		return err
+	}
+	return nil
+}
+
+func queryHash(query string) string {
+	sum := sha256.Sum256([]byte(query))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,151 @@
+package server
+
+import (
+	"PostCommentService/db"
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/99designs/gqlgen/graphql"
+	"github.com/99designs/gqlgen/graphql/handler/extension"
+)
+
+type fakeQueryStore struct {
+	queries     map[string]string
+	gets, saves int
+}
+
+func (s *fakeQueryStore) GetPersistedQuery(hash string) (string, error) {
+	s.gets++
+	query, ok := s.queries[hash]
+	if !ok {
+		return "", fmt.Errorf("persisted query %s: %w", hash, db.ErrNotFound)
+	}
+	return query, nil
+}
+
+func (s *fakeQueryStore) SavePersistedQuery(hash, query string) error {
+	s.queries[hash] = query
+	s.saves++
+	return nil
+}
+
+func TestPersistedQueryCache(t *testing.T) {
+	ctx := context.Background()
+	store := &fakeQueryStore{queries: map[string]string{"stored": "{ posts { totalCount } }"}}
+	cache := newPersistedQueryCache(10, store)
+
+	// Хеш, сохранённый другой репликой или до перезапуска, берётся из хранилища
+	if query, ok := cache.Get(ctx, "stored"); !ok || query != "{ posts { totalCount } }" {
+		t.Errorf("expected the stored query, got %v, %v", query, ok)
+	}
+	if _, ok := cache.Get(ctx, "missing"); ok {
+		t.Error("unknown hash should not be found")
+	}
+
+	cache.Add(ctx, "new", "{ tags { name } }")
+	cache.Add(ctx, "new", "{ tags { name } }")
+	if store.saves != 1 || store.queries["new"] != "{ tags { name } }" {
+		t.Errorf("new query should be saved once, saves: %d", store.saves)
+	}
+
+	// Неизвестный хеш какое-то время не запрашивается из хранилища повторно
+	now := time.Now()
+	cache.now = func() time.Time { return now }
+	store.gets = 0
+	cache.Get(ctx, "unknown")
+	cache.Get(ctx, "unknown")
+	if store.gets != 1 {
+		t.Errorf("expected 1 store lookup for a repeated unknown hash, got %d", store.gets)
+	}
+	now = now.Add(missTTL)
+	cache.Get(ctx, "unknown")
+	if store.gets != 2 {
+		t.Errorf("expected the store to be asked again after %s, got %d lookups", missTTL, store.gets)
+	}
+
+	// Хеш, зарегистрированный после промаха, находится сразу
+	cache.Add(ctx, "unknown", "{ posts { totalCount } }")
+	if query, ok := cache.Get(ctx, "unknown"); !ok || query != "{ posts { totalCount } }" {
+		t.Errorf("expected the added query, got %v, %v", query, ok)
+	}
+}
+
+func writeManifest(t *testing.T, content string) string {
+	path := filepath.Join(t.TempDir(), "manifest.json")
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestAllowlist(t *testing.T) {
+	query := "query Posts { posts { totalCount } }"
+	allowlist, err := LoadAllowlist(writeManifest(t, fmt.Sprintf(`{
+		"format": "apollo-persisted-query-manifest",
+		"version": 1,
+		"operations": [{"id": %q, "name": "Posts", "type": "query", "body": %q}]
+	}`, queryHash(query), query)))
+	if err != nil {
+		t.Fatalf("error was not expected while loading manifest: %s", err)
+	}
+
+	ctx := graphql.WithOperationContext(context.Background(), &graphql.OperationContext{})
+	apq := extension.AutomaticPersistedQuery{Cache: allowlist}
+	persisted := func(hash string) map[string]interface{} {
+		return map[string]interface{}{"persistedQuery": map[string]interface{}{"version": 1, "sha256Hash": hash}}
+	}
+
+	tests := []struct {
+		name    string
+		params  graphql.RawParams
+		allowed bool
+	}{
+		{"hash from manifest", graphql.RawParams{Extensions: persisted(queryHash(query))}, true},
+		{"query from manifest", graphql.RawParams{Query: query}, true},
+		{"arbitrary query", graphql.RawParams{Query: "{ posts { totalCount } }"}, false},
+		{"arbitrary query with its hash", graphql.RawParams{Query: "{ tags { name } }", Extensions: persisted(queryHash("{ tags { name } }"))}, false},
+	}
+
+	for _, tt := range tests {
+		params := tt.params
+		err := apq.MutateOperationParameters(ctx, &params)
+		if err == nil {
+			err = allowlist.MutateOperationParameters(ctx, &params)
+		}
+		if tt.allowed && err != nil {
+			t.Errorf("%s: unexpected error %v", tt.name, err)
+		}
+		if !tt.allowed && (err == nil || err.Extensions["code"] != errOperationNotAllowed) {
+			t.Errorf("%s: expected %s, got %v", tt.name, errOperationNotAllowed, err)
+		}
+	}
+
+	// Запрос, зарегистрированный через APQ, в строгом режиме не запоминается
+	if _, ok := allowlist.Get(ctx, queryHash("{ tags { name } }")); ok {
+		t.Error("allowlist should not accept new queries")
+	}
+}
+
+func TestLoadAllowlistErrors(t *testing.T) {
+	tests := []struct {
+		manifest string
+		err      string
+	}{
+		{`{"operations": []}`, "unsupported manifest format"},
+		{`{"format": "apollo-persisted-query-manifest", "version": 1, "operations": []}`, "no operations"},
+		{`{"format": "apollo-persisted-query-manifest", "version": 1, "operations": [{"id": "abc", "name": "Posts", "body": "{ posts { totalCount } }"}]}`, "does not match"},
+		{`not json`, "invalid character"},
+	}
+
+	for _, tt := range tests {
+		_, err := LoadAllowlist(writeManifest(t, tt.manifest))
+		if err == nil || !strings.Contains(err.Error(), tt.err) {
+			t.Errorf("%s: expected %q, got %v", tt.manifest, tt.err, err)
+		}
+	}
+}
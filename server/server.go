@@ -0,0 +1,85 @@
+package server
+
+import (
+	"context"
+	"time"
+
+	"github.com/99designs/gqlgen/graphql"
+	"github.com/99designs/gqlgen/graphql/handler"
+	"github.com/99designs/gqlgen/graphql/handler/extension"
+	"github.com/99designs/gqlgen/graphql/handler/lru"
+	"github.com/99designs/gqlgen/graphql/handler/transport"
+)
+
+type Config struct {
+	// Интервал keepalive-сообщений для протокола graphql-ws
+	KeepAliveInterval time.Duration
+	// Интервал ping/pong для протокола graphql-transport-ws
+	PingPongInterval time.Duration
+	// Сколько ждать connection_init после открытия websocket
+	InitTimeout time.Duration
+	// Разрешённые значения заголовка Origin для websocket. "*" разрешает любой,
+	// пустой список - только тот же хост, что и у сервера
+	AllowedOrigins []string
+	// Максимальное число одновременных websocket-соединений, 0 - без ограничений
+	MaxConnections int
+	// Проверяет токен из connection_init и возвращает контекст соединения
+	// с пользователем. nil - без аутентификации
+	Authenticate func(ctx context.Context, token string) (context.Context, error)
+
+	MaxUploadSize   int64
+	MaxUploadMemory int64
+	QueryCacheSize  int
+
+	// Размер LRU-кэша APQ
+	APQCacheSize int
+	// Хранилище APQ за LRU-кэшем, nil - хеши хранятся только в памяти
+	PersistedQueries PersistedQueryStore
+	// Строгий режим: выполняются только операции из манифеста, nil - любые
+	Allowlist *Allowlist
+}
+
+func DefaultConfig() Config {
+	return Config{
+		KeepAliveInterval: 10 * time.Second,
+		PingPongInterval:  10 * time.Second,
+		InitTimeout:       10 * time.Second,
+		MaxUploadSize:     32 << 20,
+		MaxUploadMemory:   32 << 20,
+		QueryCacheSize:    1000,
+		APQCacheSize:      1000,
+	}
+}
+
+// New собирает GraphQL-сервер с явно настроенными транспортами вместо
+// handler.NewDefaultServer
+func New(es graphql.ExecutableSchema, cfg Config) *handler.Server {
+	srv := handler.New(es)
+
+	// Один транспорт обслуживает оба протокола: устаревший graphql-ws
+	// и graphql-transport-ws, протокол выбирается по Sec-WebSocket-Protocol
+	srv.AddTransport(newWebsocket(cfg))
+	srv.AddTransport(transport.Options{})
+	srv.AddTransport(transport.GET{})
+	srv.AddTransport(transport.POST{})
+	srv.AddTransport(transport.MultipartForm{
+		MaxUploadSize: cfg.MaxUploadSize,
+		MaxMemory:     cfg.MaxUploadMemory,
+	})
+
+	srv.SetQueryCache(lru.New(cfg.QueryCacheSize))
+
+	srv.Use(extension.Introspection{})
+	// В строгом режиме APQ отдаёт запросы только из манифеста,
+	// а Allowlist после него отклоняет всё остальное
+	if cfg.Allowlist != nil {
+		srv.Use(extension.AutomaticPersistedQuery{Cache: cfg.Allowlist})
+		srv.Use(cfg.Allowlist)
+	} else {
+		srv.Use(extension.AutomaticPersistedQuery{
+			Cache: newPersistedQueryCache(cfg.APQCacheSize, cfg.PersistedQueries),
+		})
+	}
+
+	return srv
+}
@@ -0,0 +1,166 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+
+	"github.com/99designs/gqlgen/graphql/handler/transport"
+	"github.com/gorilla/websocket"
+)
+
+var ErrTooManyConnections = errors.New("too many connections")
+
+// ConnectionParams - проверенное содержимое сообщения connection_init
+type ConnectionParams struct {
+	AuthToken  string
+	ClientName string
+}
+
+type contextKey struct {
+	name string
+}
+
+var (
+	connectionParamsKey = &contextKey{"connectionParams"}
+	connectionSlotKey   = &contextKey{"connectionSlot"}
+)
+
+// ConnectionParamsFromContext возвращает параметры websocket-соединения,
+// в рамках которого выполняется запрос (например, подписка)
+func ConnectionParamsFromContext(ctx context.Context) (*ConnectionParams, bool) {
+	params, ok := ctx.Value(connectionParamsKey).(*ConnectionParams)
+	return params, ok
+}
+
+func WithConnectionParams(ctx context.Context, params *ConnectionParams) context.Context {
+	return context.WithValue(ctx, connectionParamsKey, params)
+}
+
+func parseInitPayload(payload transport.InitPayload) (*ConnectionParams, error) {
+	params := &ConnectionParams{}
+
+	token, err := stringField(payload, "authToken")
+	if err != nil {
+		return nil, err
+	}
+	params.AuthToken = token
+
+	// Клиенты на graphql-ws часто передают токен в виде HTTP-заголовка
+	if params.AuthToken == "" {
+		params.AuthToken = payload.Authorization()
+	}
+
+	params.ClientName, err = stringField(payload, "clientName")
+	if err != nil {
+		return nil, err
+	}
+
+	return params, nil
+}
+
+func stringField(payload transport.InitPayload, name string) (string, error) {
+	value, ok := payload[name]
+	if !ok || value == nil {
+		return "", nil
+	}
+
+	s, ok := value.(string)
+	if !ok {
+		return "", fmt.Errorf("invalid connection_init payload: %s must be a string", name)
+	}
+
+	return s, nil
+}
+
+type connectionLimiter struct {
+	max    int
+	active int
+	mu     sync.Mutex
+
+	authenticate func(ctx context.Context, token string) (context.Context, error)
+}
+
+func (l *connectionLimiter) acquire() bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.max > 0 && l.active >= l.max {
+		return false
+	}
+	l.active++
+
+	return true
+}
+
+func (l *connectionLimiter) release() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.active--
+}
+
+func (l *connectionLimiter) initFunc(ctx context.Context, payload transport.InitPayload) (context.Context, *transport.InitPayload, error) {
+	params, err := parseInitPayload(payload)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if l.authenticate != nil {
+		ctx, err = l.authenticate(ctx, params.AuthToken)
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+
+	if !l.acquire() {
+		return nil, nil, ErrTooManyConnections
+	}
+
+	ctx = context.WithValue(ctx, connectionSlotKey, true)
+	return WithConnectionParams(ctx, params), nil, nil
+}
+
+func (l *connectionLimiter) closeFunc(ctx context.Context, closeCode int) {
+	// Соединения, не прошедшие connection_init, слот не занимали
+	if acquired, _ := ctx.Value(connectionSlotKey).(bool); acquired {
+		l.release()
+	}
+}
+
+func newWebsocket(cfg Config) transport.Websocket {
+	limiter := &connectionLimiter{max: cfg.MaxConnections, authenticate: cfg.Authenticate}
+
+	return transport.Websocket{
+		Upgrader: websocket.Upgrader{
+			CheckOrigin: checkOrigin(cfg.AllowedOrigins),
+		},
+		InitFunc:              limiter.initFunc,
+		CloseFunc:             limiter.closeFunc,
+		InitTimeout:           cfg.InitTimeout,
+		KeepAlivePingInterval: cfg.KeepAliveInterval,
+		PingPongInterval:      cfg.PingPongInterval,
+	}
+}
+
+func checkOrigin(allowed []string) func(r *http.Request) bool {
+	// nil означает проверку gorilla/websocket по умолчанию: Origin совпадает с Host
+	if len(allowed) == 0 {
+		return nil
+	}
+
+	origins := make(map[string]struct{}, len(allowed))
+	for _, origin := range allowed {
+		if origin == "*" {
+			return func(r *http.Request) bool { return true }
+		}
+		origins[origin] = struct{}{}
+	}
+
+	return func(r *http.Request) bool {
+		_, ok := origins[r.Header.Get("Origin")]
+		return ok
+	}
+}
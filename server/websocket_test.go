@@ -0,0 +1,135 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/99designs/gqlgen/graphql/handler/transport"
+)
+
+func TestInitFuncStoresConnectionParams(t *testing.T) {
+	limiter := &connectionLimiter{}
+
+	ctx, _, err := limiter.initFunc(context.Background(), transport.InitPayload{
+		"authToken":  "token",
+		"clientName": "web",
+	})
+	if err != nil {
+		t.Fatalf("error was not expected during init: %s", err)
+	}
+
+	params, ok := ConnectionParamsFromContext(ctx)
+	if !ok {
+		t.Fatal("connection params should be stored in the context")
+	}
+
+	if params.AuthToken != "token" || params.ClientName != "web" {
+		t.Errorf("unexpected connection params: %+v", params)
+	}
+}
+
+func TestInitFuncAuthorizationFallback(t *testing.T) {
+	limiter := &connectionLimiter{}
+
+	ctx, _, err := limiter.initFunc(context.Background(), transport.InitPayload{
+		"Authorization": "Bearer token",
+	})
+	if err != nil {
+		t.Fatalf("error was not expected during init: %s", err)
+	}
+
+	params, _ := ConnectionParamsFromContext(ctx)
+	if params.AuthToken != "Bearer token" {
+		t.Errorf("expected auth token to be taken from Authorization, got %q", params.AuthToken)
+	}
+}
+
+func TestInitFuncRejectsInvalidPayload(t *testing.T) {
+	limiter := &connectionLimiter{}
+
+	_, _, err := limiter.initFunc(context.Background(), transport.InitPayload{"authToken": 42})
+	if err == nil {
+		t.Fatal("expected an error for a non-string authToken")
+	}
+
+	if limiter.active != 0 {
+		t.Errorf("rejected connection should not take a slot, active: %d", limiter.active)
+	}
+}
+
+func TestInitFuncAuthenticates(t *testing.T) {
+	errDenied := errors.New("denied")
+	limiter := &connectionLimiter{
+		authenticate: func(ctx context.Context, token string) (context.Context, error) {
+			if token != "good" {
+				return nil, errDenied
+			}
+			return context.WithValue(ctx, contextKey{"user"}, token), nil
+		},
+	}
+
+	ctx, _, err := limiter.initFunc(context.Background(), transport.InitPayload{"authToken": "good"})
+	if err != nil {
+		t.Fatalf("error was not expected during init: %s", err)
+	}
+	if ctx.Value(contextKey{"user"}) != "good" {
+		t.Error("context returned by authenticate should be used for the connection")
+	}
+
+	if _, _, err := limiter.initFunc(context.Background(), transport.InitPayload{"authToken": "bad"}); err != errDenied {
+		t.Errorf("expected authentication error, got %v", err)
+	}
+	if limiter.active != 1 {
+		t.Errorf("rejected connection should not take a slot, active: %d", limiter.active)
+	}
+}
+
+func TestConnectionLimit(t *testing.T) {
+	limiter := &connectionLimiter{max: 1}
+
+	ctx, _, err := limiter.initFunc(context.Background(), transport.InitPayload{})
+	if err != nil {
+		t.Fatalf("error was not expected during init: %s", err)
+	}
+
+	if _, _, err := limiter.initFunc(context.Background(), transport.InitPayload{}); err != ErrTooManyConnections {
+		t.Errorf("expected ErrTooManyConnections, got %v", err)
+	}
+
+	// Закрытие соединения, не прошедшего init, не должно освобождать слот
+	limiter.closeFunc(context.Background(), 1000)
+	if limiter.active != 1 {
+		t.Errorf("expected 1 active connection, got %d", limiter.active)
+	}
+
+	limiter.closeFunc(ctx, 1000)
+	if _, _, err := limiter.initFunc(context.Background(), transport.InitPayload{}); err != nil {
+		t.Errorf("slot should be released after close, got %v", err)
+	}
+}
+
+func TestCheckOrigin(t *testing.T) {
+	if checkOrigin(nil) != nil {
+		t.Error("empty list should fall back to the same-origin check")
+	}
+
+	check := checkOrigin([]string{"https://example.com"})
+
+	r := httptest.NewRequest("GET", "/query", nil)
+	r.Header.Set("Origin", "https://example.com")
+	if !check(r) {
+		t.Error("allowed origin was rejected")
+	}
+
+	r.Header.Set("Origin", "https://evil.com")
+	if check(r) {
+		t.Error("unknown origin was accepted")
+	}
+
+	r.Header.Set("Origin", "https://evil.com")
+	if !checkOrigin([]string{"*"})(r) {
+		t.Error("wildcard should accept any origin")
+	}
+}